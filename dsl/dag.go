@@ -0,0 +1,185 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExpressionDAG hash-conses the expressions added to it, so the subtrees that are
+// equal on different expressions (or on the same expression) become the same node
+// of a shared directed acyclic graph. Solving the shared expressions with the same
+// SolveCache solves each node once per document.
+// Eg: on `("a" and "b") or "c"` and `("a" and "b") and "d"` the AND of "a" and "b" is shared.
+type ExpressionDAG struct {
+	// nodes are the nodes of the graph by their keys (see nodeKey)
+	nodes map[string]*dagNode
+	// byExpr are the nodes of the graph by their shared expressions.
+	byExpr map[*Expression]*dagNode
+	nextID int
+	// treeNodes is the number of nodes of the added expressions as trees.
+	treeNodes int
+}
+
+// dagNode is a shared expression with the number of references to it,
+// that are the parents on the graph and the added expressions.
+type dagNode struct {
+	id   int
+	key  string
+	exp  *Expression
+	refs int
+}
+
+// DAGStats holds the number of nodes of the expressions of an ExpressionDAG.
+type DAGStats struct {
+	// Nodes is the number of nodes of the expressions as trees.
+	Nodes int
+	// UniqueNodes is the number of nodes of the shared graph.
+	UniqueNodes int
+}
+
+// DedupRatio returns the fraction of the nodes that are not solved
+// because they are shared. It is 0 if no node is shared.
+func (stats DAGStats) DedupRatio() float64 {
+	if stats.Nodes == 0 {
+		return 0
+	}
+	return 1 - float64(stats.UniqueNodes)/float64(stats.Nodes)
+}
+
+// NewExpressionDAG returns a new empty ExpressionDAG.
+func NewExpressionDAG() *ExpressionDAG {
+	return &ExpressionDAG{
+		nodes:  make(map[string]*dagNode),
+		byExpr: make(map[*Expression]*dagNode),
+	}
+}
+
+// Add adds the expression to the graph and returns its shared version, where
+// every subtree is the node of the graph equal to it. The expression is not
+// changed and the shared version must not be changed, since its nodes
+// may be part of other expressions.
+func (dag *ExpressionDAG) Add(exp *Expression) *Expression {
+	if exp == nil {
+		return nil
+	}
+
+	shared := *exp
+	shared.LExpr = dag.Add(exp.LExpr)
+	shared.RExpr = dag.Add(exp.RExpr)
+	if exp.Operands != nil {
+		shared.Operands = make([]*Expression, len(exp.Operands))
+		for i, operand := range exp.Operands {
+			shared.Operands[i] = dag.Add(operand)
+		}
+	}
+	dag.treeNodes++
+
+	key := dag.nodeKey(&shared)
+	if node, ok := dag.nodes[key]; ok {
+		// the node already references the same children
+		node.refs++
+		dag.releaseChildren(&shared)
+		return node.exp
+	}
+
+	node := &dagNode{id: dag.nextID, key: key, exp: &shared, refs: 1}
+	dag.nextID++
+	dag.nodes[key] = node
+	dag.byExpr[node.exp] = node
+	return node.exp
+}
+
+// Remove removes an expression returned by Add from the graph. The nodes
+// that are no longer referenced by any expression are removed as well.
+// Expressions that are not on the graph are ignored.
+func (dag *ExpressionDAG) Remove(exp *Expression) {
+	if _, ok := dag.byExpr[exp]; !ok {
+		return
+	}
+	dag.treeNodes -= exp.treeSize()
+	dag.release(exp)
+}
+
+// Stats returns the number of nodes of the expressions as trees and of the graph.
+func (dag *ExpressionDAG) Stats() DAGStats {
+	return DAGStats{Nodes: dag.treeNodes, UniqueNodes: len(dag.nodes)}
+}
+
+// release removes a reference to the shared expression and
+// removes its node if it is no longer referenced.
+func (dag *ExpressionDAG) release(exp *Expression) {
+	node, ok := dag.byExpr[exp]
+	if !ok {
+		return
+	}
+	node.refs--
+	if node.refs > 0 {
+		return
+	}
+	delete(dag.nodes, node.key)
+	delete(dag.byExpr, exp)
+	dag.releaseChildren(exp)
+}
+
+// releaseChildren removes a reference to each child of the shared expression.
+func (dag *ExpressionDAG) releaseChildren(exp *Expression) {
+	if exp.LExpr != nil {
+		dag.release(exp.LExpr)
+	}
+	if exp.RExpr != nil {
+		dag.release(exp.RExpr)
+	}
+	for _, operand := range exp.Operands {
+		dag.release(operand)
+	}
+}
+
+// nodeKey returns the key of an expression whose children are already shared.
+// Two expressions have the same key if they have the same fields and children.
+func (dag *ExpressionDAG) nodeKey(exp *Expression) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d|%q|%d|%t|%d|%d|%d|%q|%d|%d",
+		exp.Type, exp.Literal, exp.TermType, exp.Inord, exp.Distance, exp.DistanceUnit,
+		exp.Threshold, exp.Comparator, dag.nodeID(exp.LExpr), dag.nodeID(exp.RExpr))
+	for _, operand := range exp.Operands {
+		fmt.Fprintf(&sb, "|%d", dag.nodeID(operand))
+	}
+	return sb.String()
+}
+
+// nodeID returns the id of the node of the shared expression, or -1 if exp is nil.
+func (dag *ExpressionDAG) nodeID(exp *Expression) int {
+	if exp == nil {
+		return -1
+	}
+	return dag.byExpr[exp].id
+}
+
+// treeSize returns the number of nodes of the expression as a tree.
+func (exp *Expression) treeSize() int {
+	if exp == nil {
+		return 0
+	}
+	size := 1 + exp.LExpr.treeSize() + exp.RExpr.treeSize()
+	for _, operand := range exp.Operands {
+		size += operand.treeSize()
+	}
+	return size
+}
+
+// SolveCache holds the results of the expression nodes solved on a document
+// (see SolveWithCache). It must not be used from multiple goroutines at once.
+type SolveCache struct {
+	results map[*Expression]solveResult
+}
+
+// solveResult is the result of a solved expression node.
+type solveResult struct {
+	eval      bool
+	positions []int
+}
+
+// NewSolveCache returns a new empty SolveCache.
+func NewSolveCache() *SolveCache {
+	return &SolveCache{results: make(map[*Expression]solveResult)}
+}
@@ -0,0 +1,81 @@
+package dsl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpressionDAG(t *testing.T) {
+	assert := assert.New(t)
+	dag := NewExpressionDAG()
+	parse := func(expStr string) *Expression {
+		exp, err := NewParser(strings.NewReader(expStr), true).Parse()
+		assert.Nil(err, expStr)
+		return exp
+	}
+
+	exp1 := parse(`("a" and "b") or "c"`)
+	exp2 := parse(`("a" and "b") and "d"`)
+	shared1 := dag.Add(exp1)
+	shared2 := dag.Add(exp2)
+	assert.Equal(exp1, shared1)
+	assert.Equal(exp2, shared2)
+	assert.True(shared1.LExpr == shared2.LExpr, "shared subtree")
+	assert.False(exp1.LExpr == shared1.LExpr, "the added expression is not changed")
+	assert.Equal(DAGStats{Nodes: 10, UniqueNodes: 7}, dag.Stats())
+	assert.InDelta(0.3, dag.Stats().DedupRatio(), 0.0001)
+
+	shared3 := dag.Add(parse(`("a" and "b") or "c"`))
+	assert.True(shared1 == shared3, "shared expression")
+	assert.Equal(DAGStats{Nodes: 15, UniqueNodes: 7}, dag.Stats())
+
+	exp4 := parse(`atleast(2, "a", "b", "c") and inord("a" and "b")`)
+	shared4 := dag.Add(exp4)
+	assert.Equal(exp4, shared4)
+	assert.True(shared4.LExpr.Operands[2] == shared1.RExpr, "shared operand")
+	assert.False(shared4.RExpr.RExpr == shared1.LExpr, "inord subtree is not equal")
+	assert.Equal(DAGStats{Nodes: 24, UniqueNodes: 13}, dag.Stats())
+
+	dag.Remove(shared1)
+	dag.Remove(shared4)
+	assert.Equal(DAGStats{Nodes: 10, UniqueNodes: 7}, dag.Stats())
+	dag.Remove(shared3)
+	assert.Equal(DAGStats{Nodes: 5, UniqueNodes: 5}, dag.Stats())
+	dag.Remove(exp2)
+	assert.Equal(DAGStats{Nodes: 5, UniqueNodes: 5}, dag.Stats(), "expression not on the graph")
+	dag.Remove(shared2)
+	assert.Equal(DAGStats{}, dag.Stats())
+	assert.Equal(0.0, dag.Stats().DedupRatio())
+}
+
+func TestSolveWithCache(t *testing.T) {
+	assert := assert.New(t)
+	dag := NewExpressionDAG()
+	shared := make([]*Expression, len(solverTestCases))
+	for i, tc := range solverTestCases {
+		exp, err := NewParser(strings.NewReader(tc.expStr), true).Parse()
+		assert.Nil(err, tc.message)
+		shared[i] = dag.Add(exp)
+	}
+
+	for i, tc := range solverTestCases {
+		cache := NewSolveCache()
+		// the expression is solved twice to use the cached results
+		for j := 0; j < 2; j++ {
+			resp, err := shared[i].SolveWithCache(tc.sortedMatchesByKeyword, nil, cache)
+			assert.Nil(err, tc.message)
+			assert.Equal(tc.expectedResp, resp, tc.message)
+		}
+	}
+
+	exp, err := NewParser(strings.NewReader(`("a" and "b") or ("a" and "b" and "c")`), true).Parse()
+	assert.Nil(err)
+	cache := NewSolveCache()
+	resp, err := dag.Add(exp).SolveWithCache(map[string][]int{"a": {0}}, nil, cache)
+	assert.Nil(err)
+	assert.False(resp)
+	// the shared AND of "a" and "b" is solved once
+	assert.Len(cache.results, 3)
+}
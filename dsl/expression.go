@@ -1,225 +1,582 @@
-package dsl
-
-import (
-	"fmt"
-	"strings"
-)
-
-// ExprType are Special tokens used to define the expression type
-type ExprType int
-
-const (
-	UNSET_EXPR ExprType = iota
-	AND_EXPR
-	OR_EXPR
-	NOT_EXPR
-	UNIT_EXPR
-	INORD_EXPR
-)
-
-// GetName returns a readable name for the ExprType value
-func (exprType ExprType) GetName() string {
-	switch exprType {
-	case UNSET_EXPR:
-		return "UNSET"
-	case AND_EXPR:
-		return "AND"
-	case OR_EXPR:
-		return "OR"
-	case NOT_EXPR:
-		return "NOT"
-	case UNIT_EXPR:
-		return "UNIT"
-	case INORD_EXPR:
-		return "INORD"
-	default:
-		return "UNEXPECTED"
-	}
-}
-
-// Expression can be a literal (UNIT) or a function composed by
-// one or two other expressions (NOT, AND, OR).
-type Expression struct {
-	LExpr   *Expression
-	RExpr   *Expression
-	Type    ExprType
-	Literal string
-	Inord   bool
-}
-
-// GetTypeName returns the type of the expression with a readable name
-func (exp *Expression) GetTypeName() string {
-	return exp.Type.GetName()
-}
-
-// Solve solves the expresion recursively. It has the option to use a complete map of
-// PatternResult or a incomplete map. If the complete map option is used the map must have
-// all the terms needed to solve de expression or it will return an error.
-// If the incomplete map is used, missing keys will be considered as a no match on the
-// document.
-func (exp *Expression) Solve(sortedMatchesByKeyword map[string][]int) (bool, error) {
-	eval, _, err := exp.solve(sortedMatchesByKeyword)
-	return eval, err
-}
-
-//solve implements Solve
-func (exp *Expression) solve(sortedMatchesByKeyword map[string][]int) (bool, []int, error) {
-	switch exp.Type {
-	case UNIT_EXPR:
-		if sortedMatches, ok := sortedMatchesByKeyword[exp.Literal]; ok {
-			return true, sortedMatches, nil
-		}
-		return false, nil, nil
-
-	case AND_EXPR:
-		if exp.LExpr == nil || exp.RExpr == nil {
-			return false, nil, fmt.Errorf("AND statment do not have rigth or left expression: %v", exp)
-		}
-		lval, lpos, err := exp.LExpr.solve(sortedMatchesByKeyword)
-		if err != nil {
-			return false, nil, err
-		}
-		rval, rpos, err := exp.RExpr.solve(sortedMatchesByKeyword)
-		if err != nil {
-			return false, nil, err
-		}
-
-		var pos []int
-		if exp.Inord && len(lpos) > 0 && len(rpos) > 0 {
-			idx := getLowestIdxGTVal(rpos, lpos[0])
-			if idx >= 0 {
-				pos = rpos[idx:]
-			}
-		}
-
-		return lval && rval, pos, nil
-
-	case OR_EXPR:
-
-		if exp.LExpr == nil || exp.RExpr == nil {
-			return false, nil, fmt.Errorf("OR statment do not have rigth or left expression: %v", exp)
-		}
-		lval, lpos, err := exp.LExpr.solve(sortedMatchesByKeyword)
-		if err != nil {
-			return false, nil, err
-		}
-		rval, rpos, err := exp.RExpr.solve(sortedMatchesByKeyword)
-		if err != nil {
-			return false, nil, err
-		}
-
-		var pos []int
-		if exp.Inord {
-			pos = mergeArraysSorted(lpos, rpos)
-		}
-
-		return lval || rval, pos, nil
-
-	case NOT_EXPR:
-		if exp.RExpr == nil {
-			return false, nil, fmt.Errorf("NOT statement do not have expression: %v", exp)
-		}
-		rval, _, err := exp.RExpr.solve(sortedMatchesByKeyword)
-		if err != nil {
-			return false, nil, err
-		}
-
-		return !rval, nil, nil
-
-	case INORD_EXPR:
-		if exp.RExpr == nil {
-			return false, nil, fmt.Errorf("INORD statement do not have expression: %v", exp)
-		}
-		rval, rpos, err := exp.RExpr.solve(sortedMatchesByKeyword)
-		if err != nil {
-			return false, nil, err
-		}
-		return rval && len(rpos) > 0, nil, nil
-
-	default:
-		return false, nil, fmt.Errorf("unable to process expression type %d", exp.Type)
-	}
-}
-
-// PrettyPrint returns the expression formated on a tabbed structure
-// Eg: for the expression ("a" and "b") or "c"
-//    OR
-//        AND
-//            a
-//            b
-//        c
-func (exp *Expression) PrettyFormat() string {
-	return exp.prettyFormat(0)
-}
-
-func (exp *Expression) prettyFormat(lvl int) (pprint string) {
-	tabs := "    "
-	onLVL := strings.Repeat(tabs, lvl)
-	if exp.Type == UNIT_EXPR {
-		return fmt.Sprintf("%s%s\n", onLVL, exp.Literal)
-	}
-	pprint = fmt.Sprintf("%s%s\n", onLVL, exp.GetTypeName())
-	if exp.LExpr != nil {
-		pprint += exp.LExpr.prettyFormat(lvl + 1)
-	}
-
-	if exp.RExpr != nil {
-		pprint += exp.RExpr.prettyFormat(lvl + 1)
-	}
-
-	return
-}
-
-// getLowestIdxGTVal uses binary search to find the
-// index of the lowest element that is greater than 'value'
-func getLowestIdxGTVal(positions []int, value int) int {
-	left := 0
-	right := len(positions) - 1
-	lwGrtI := -1
-	for left <= right {
-		half := (left + right) >> 1 // divide by 2
-		if positions[half] > value {
-			lwGrtI = half
-			right = half - 1
-		} else {
-			left = half + 1
-		}
-	}
-	return lwGrtI
-}
-
-// mergeArraysSorted merges two sorted arrays into a new sorted array
-func mergeArraysSorted(lArr []int, rArr []int) []int {
-	leftIdx := 0
-	rightIdx := 0
-	if len(lArr) == 0 {
-		return rArr
-	}
-	if len(rArr) == 0 {
-		return lArr
-	}
-	lSize := len(lArr)
-	rSize := len(rArr)
-	sumSize := lSize + rSize
-	outArr := make([]int, sumSize)
-	count := 0
-
-	for count < sumSize {
-		switch {
-		case leftIdx == lSize:
-			outArr[count] = rArr[rightIdx]
-			rightIdx++
-		case rightIdx == rSize:
-			outArr[count] = lArr[leftIdx]
-			leftIdx++
-		case lArr[leftIdx] < rArr[rightIdx]:
-			outArr[count] = lArr[leftIdx]
-			leftIdx++
-		default:
-			outArr[count] = rArr[rightIdx]
-			rightIdx++
-		}
-		count++
-	}
-	return outArr
-}
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExprType are Special tokens used to define the expression type
+type ExprType int
+
+const (
+	UNSET_EXPR ExprType = iota
+	AND_EXPR
+	OR_EXPR
+	NOT_EXPR
+	UNIT_EXPR
+	INORD_EXPR
+	NEAR_EXPR
+	ONEAR_EXPR
+	ATLEAST_EXPR
+	EXACTLY_EXPR
+	ATMOST_EXPR
+	COUNT_EXPR
+)
+
+// DistanceUnit defines how the distance of the NEAR operators is measured
+type DistanceUnit int
+
+const (
+	WORD_DISTANCE DistanceUnit = iota
+	CHAR_DISTANCE
+)
+
+// suffix returns the suffix used by the DSL for the DistanceUnit value
+func (unit DistanceUnit) suffix() string {
+	if unit == CHAR_DISTANCE {
+		return "c"
+	}
+	return "w"
+}
+
+// TermType defines the kind of term of the UNIT expressions, that tells
+// how the Literal was written on the DSL
+type TermType int
+
+const (
+	KEYWORD_TERM TermType = iota
+	REGEX_TERM
+	WORD_TERM
+	WILDCARD_TERM
+	FUZZY_TERM
+	CASE_TERM
+)
+
+// GetName returns a readable name for the ExprType value
+func (exprType ExprType) GetName() string {
+	switch exprType {
+	case UNSET_EXPR:
+		return "UNSET"
+	case AND_EXPR:
+		return "AND"
+	case OR_EXPR:
+		return "OR"
+	case NOT_EXPR:
+		return "NOT"
+	case UNIT_EXPR:
+		return "UNIT"
+	case INORD_EXPR:
+		return "INORD"
+	case NEAR_EXPR:
+		return "NEAR"
+	case ONEAR_EXPR:
+		return "ONEAR"
+	case ATLEAST_EXPR:
+		return "ATLEAST"
+	case EXACTLY_EXPR:
+		return "EXACTLY"
+	case ATMOST_EXPR:
+		return "ATMOST"
+	case COUNT_EXPR:
+		return "COUNT"
+	default:
+		return "UNEXPECTED"
+	}
+}
+
+// Expression can be a literal (UNIT), a function composed by
+// one or two other expressions (NOT, AND, OR, NEAR, ONEAR), a
+// threshold function of a list of terms (ATLEAST, EXACTLY, ATMOST)
+// or a comparison of the number of matches of a term (COUNT).
+// Distance and DistanceUnit are only used by the NEAR and ONEAR expressions,
+// Threshold and Operands by the threshold expressions and Threshold and
+// Comparator by the COUNT expression, that has the term as RExpr.
+// TermType is only used by the UNIT expressions.
+type Expression struct {
+	LExpr        *Expression
+	RExpr        *Expression
+	Type         ExprType
+	Literal      string
+	TermType     TermType
+	Inord        bool
+	Distance     int
+	DistanceUnit DistanceUnit
+	Threshold    int
+	Operands     []*Expression
+	Comparator   string
+}
+
+// GetTypeName returns the type of the expression with a readable name
+func (exp *Expression) GetTypeName() string {
+	return exp.Type.GetName()
+}
+
+// Solve solves the expresion recursively. It has the option to use a complete map of
+// PatternResult or a incomplete map. If the complete map option is used the map must have
+// all the terms needed to solve de expression or it will return an error.
+// If the incomplete map is used, missing keys will be considered as a no match on the
+// document.
+// The positions are used as they are to compute the distances of the NEAR operators,
+// for both word and character distances. Use SolveWithWordIndex to solve expressions
+// with word distances when the positions are offsets of a text.
+func (exp *Expression) Solve(sortedMatchesByKeyword map[string][]int) (bool, error) {
+	eval, _, err := exp.solve(sortedMatchesByKeyword, nil, nil)
+	return eval, err
+}
+
+// SolveWithWordIndex works as Solve but uses the word index to convert the positions
+// to word positions when computing the word distances of the NEAR operators.
+// If wordIndex is nil the positions are used as they are.
+func (exp *Expression) SolveWithWordIndex(sortedMatchesByKeyword map[string][]int, wordIndex *WordIndex) (bool, error) {
+	eval, _, err := exp.solve(sortedMatchesByKeyword, wordIndex, nil)
+	return eval, err
+}
+
+// SolveWithMatchedTerms solves the expression and returns the set of terms that
+// contributed to the expression being evaluated as true. Terms enclosed by a NOT
+// operator and terms on the branches of an OR that were evaluated as false are
+// not considered as contributors. If the expression is false the set is nil.
+// The wordIndex is used as on SolveWithWordIndex and can be nil.
+func (exp *Expression) SolveWithMatchedTerms(
+	sortedMatchesByKeyword map[string][]int,
+	wordIndex *WordIndex,
+) (bool, map[string]struct{}, error) {
+	eval, _, err := exp.solve(sortedMatchesByKeyword, wordIndex, nil)
+	if err != nil || !eval {
+		return eval, nil, err
+	}
+
+	terms := make(map[string]struct{})
+	err = exp.collectMatchedTerms(sortedMatchesByKeyword, wordIndex, terms)
+	if err != nil {
+		return false, nil, err
+	}
+	return eval, terms, nil
+}
+
+// collectMatchedTerms adds to terms all the literals that made the
+// expression true. It expects that the expression was evaluated as true.
+func (exp *Expression) collectMatchedTerms(
+	sortedMatchesByKeyword map[string][]int,
+	wordIndex *WordIndex,
+	terms map[string]struct{},
+) error {
+	switch exp.Type {
+	case UNIT_EXPR:
+		if _, ok := sortedMatchesByKeyword[exp.Literal]; ok {
+			terms[exp.Literal] = struct{}{}
+		}
+
+	case AND_EXPR, INORD_EXPR, NEAR_EXPR, ONEAR_EXPR, ATLEAST_EXPR, EXACTLY_EXPR, ATMOST_EXPR, COUNT_EXPR:
+		children := append([]*Expression{exp.LExpr, exp.RExpr}, exp.Operands...)
+		for _, child := range children {
+			if child == nil {
+				continue
+			}
+			if err := child.collectMatchedTerms(sortedMatchesByKeyword, wordIndex, terms); err != nil {
+				return err
+			}
+		}
+
+	case OR_EXPR:
+		for _, child := range []*Expression{exp.LExpr, exp.RExpr} {
+			if child == nil {
+				continue
+			}
+			eval, _, err := child.solve(sortedMatchesByKeyword, wordIndex, nil)
+			if err != nil {
+				return err
+			}
+			if !eval {
+				continue
+			}
+			if err := child.collectMatchedTerms(sortedMatchesByKeyword, wordIndex, terms); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SolveWithCache works as SolveWithWordIndex but keeps the results of the solved nodes on
+// the cache, so the nodes that are shared by many expressions (see ExpressionDAG) are solved
+// once per document. The same cache must only be used with the same matches and wordIndex.
+func (exp *Expression) SolveWithCache(
+	sortedMatchesByKeyword map[string][]int,
+	wordIndex *WordIndex,
+	cache *SolveCache,
+) (bool, error) {
+	eval, _, err := exp.solve(sortedMatchesByKeyword, wordIndex, cache)
+	return eval, err
+}
+
+//solve implements Solve. If cache is not nil the results of the nodes are kept on it.
+func (exp *Expression) solve(sortedMatchesByKeyword map[string][]int, wordIndex *WordIndex, cache *SolveCache) (bool, []int, error) {
+	// the terms are looked up as fast as the cache
+	if cache == nil || exp.Type == UNIT_EXPR {
+		return exp.solveNode(sortedMatchesByKeyword, wordIndex, cache)
+	}
+	if res, ok := cache.results[exp]; ok {
+		return res.eval, res.positions, nil
+	}
+	eval, positions, err := exp.solveNode(sortedMatchesByKeyword, wordIndex, cache)
+	if err != nil {
+		return false, nil, err
+	}
+	cache.results[exp] = solveResult{eval: eval, positions: positions}
+	return eval, positions, nil
+}
+
+// solveNode solves the expression node, solving its children with solve.
+func (exp *Expression) solveNode(sortedMatchesByKeyword map[string][]int, wordIndex *WordIndex, cache *SolveCache) (bool, []int, error) {
+	switch exp.Type {
+	case UNIT_EXPR:
+		if sortedMatches, ok := sortedMatchesByKeyword[exp.Literal]; ok {
+			return true, sortedMatches, nil
+		}
+		return false, nil, nil
+
+	case AND_EXPR:
+		if exp.LExpr == nil || exp.RExpr == nil {
+			return false, nil, fmt.Errorf("AND statment do not have rigth or left expression: %v", exp)
+		}
+		lval, _, err := exp.LExpr.solve(sortedMatchesByKeyword, wordIndex, cache)
+		if err != nil {
+			return false, nil, err
+		}
+		// a false expression has no positions, so the right side does not matter
+		if !lval {
+			return false, nil, nil
+		}
+		rval, _, err := exp.RExpr.solve(sortedMatchesByKeyword, wordIndex, cache)
+		if err != nil {
+			return false, nil, err
+		}
+
+		return lval && rval, nil, nil
+
+	case OR_EXPR:
+
+		if exp.LExpr == nil || exp.RExpr == nil {
+			return false, nil, fmt.Errorf("OR statment do not have rigth or left expression: %v", exp)
+		}
+		lval, _, err := exp.LExpr.solve(sortedMatchesByKeyword, wordIndex, cache)
+		if err != nil {
+			return false, nil, err
+		}
+		if lval {
+			return true, nil, nil
+		}
+		rval, _, err := exp.RExpr.solve(sortedMatchesByKeyword, wordIndex, cache)
+		if err != nil {
+			return false, nil, err
+		}
+
+		return rval, nil, nil
+
+	case NOT_EXPR:
+		if exp.RExpr == nil {
+			return false, nil, fmt.Errorf("NOT statement do not have expression: %v", exp)
+		}
+		rval, _, err := exp.RExpr.solve(sortedMatchesByKeyword, wordIndex, cache)
+		if err != nil {
+			return false, nil, err
+		}
+
+		return !rval, nil, nil
+
+	case INORD_EXPR:
+		if exp.RExpr == nil {
+			return false, nil, fmt.Errorf("INORD statement do not have expression: %v", exp)
+		}
+		pattern, err := compileInord(exp)
+		if err != nil {
+			return false, nil, err
+		}
+		return pattern.match(sortedMatchesByKeyword, wordIndex), nil, nil
+
+	case NEAR_EXPR, ONEAR_EXPR:
+		if exp.LExpr == nil || exp.RExpr == nil {
+			return false, nil, fmt.Errorf("%s statment do not have rigth or left expression: %v", exp.GetTypeName(), exp)
+		}
+		lpos, err := exp.LExpr.termPositions(sortedMatchesByKeyword)
+		if err != nil {
+			return false, nil, err
+		}
+		if len(lpos) == 0 {
+			return false, nil, nil
+		}
+		rpos, err := exp.RExpr.termPositions(sortedMatchesByKeyword)
+		if err != nil {
+			return false, nil, err
+		}
+		if len(rpos) == 0 {
+			return false, nil, nil
+		}
+
+		// the distances are measured on lDist and rDist, that are the
+		// positions converted to words when it is possible.
+		lDist, rDist := lpos, rpos
+		if exp.DistanceUnit == WORD_DISTANCE && wordIndex != nil {
+			lDist = wordIndex.convertPositions(lpos)
+			rDist = wordIndex.convertPositions(rpos)
+		}
+		if exp.Type == ONEAR_EXPR {
+			return isOrderedNear(lpos, rpos, lDist, rDist, exp.Distance), nil, nil
+		}
+		return isNear(lDist, rDist, exp.Distance), nil, nil
+
+	case ATLEAST_EXPR, EXACTLY_EXPR, ATMOST_EXPR:
+		if len(exp.Operands) == 0 {
+			return false, nil, fmt.Errorf("%s statement do not have operands: %v", exp.GetTypeName(), exp)
+		}
+		found := 0
+		for i, operand := range exp.Operands {
+			val, _, err := operand.solve(sortedMatchesByKeyword, wordIndex, cache)
+			if err != nil {
+				return false, nil, err
+			}
+			if val {
+				found++
+			}
+			// stop as soon as the remaining operands can not change the result
+			if decided, eval := exp.thresholdDecided(found, len(exp.Operands)-i-1); decided {
+				return eval, nil, nil
+			}
+		}
+		_, eval := exp.thresholdDecided(found, 0)
+		return eval, nil, nil
+
+	case COUNT_EXPR:
+		if exp.RExpr == nil {
+			return false, nil, fmt.Errorf("COUNT statement do not have expression: %v", exp)
+		}
+		count := len(sortedMatchesByKeyword[exp.RExpr.Literal])
+		switch exp.Comparator {
+		case ">":
+			return count > exp.Threshold, nil, nil
+		case ">=":
+			return count >= exp.Threshold, nil, nil
+		case "<":
+			return count < exp.Threshold, nil, nil
+		case "<=":
+			return count <= exp.Threshold, nil, nil
+		case "==":
+			return count == exp.Threshold, nil, nil
+		case "!=":
+			return count != exp.Threshold, nil, nil
+		default:
+			return false, nil, fmt.Errorf("COUNT statement has an invalid comparator '%s': %v", exp.Comparator, exp)
+		}
+
+	default:
+		return false, nil, fmt.Errorf("unable to process expression type %d", exp.Type)
+	}
+}
+
+// thresholdDecided returns true and the result of a threshold expression if it is
+// already decided with found operands evaluated as true and pending operands left.
+func (exp *Expression) thresholdDecided(found int, pending int) (decided bool, eval bool) {
+	switch exp.Type {
+	case ATLEAST_EXPR:
+		if found >= exp.Threshold {
+			return true, true
+		}
+		return found+pending < exp.Threshold, false
+	case EXACTLY_EXPR:
+		if found > exp.Threshold || found+pending < exp.Threshold {
+			return true, false
+		}
+		return pending == 0, true
+	default:
+		if found > exp.Threshold {
+			return true, false
+		}
+		return found+pending <= exp.Threshold, true
+	}
+}
+
+// termPositions returns the sorted positions of the terms of an expression
+// composed only by terms and OR operators, that are the operands of NEAR.
+func (exp *Expression) termPositions(sortedMatchesByKeyword map[string][]int) ([]int, error) {
+	switch exp.Type {
+	case UNIT_EXPR:
+		return sortedMatchesByKeyword[exp.Literal], nil
+	case OR_EXPR:
+		if exp.LExpr == nil || exp.RExpr == nil {
+			return nil, fmt.Errorf("OR statment do not have rigth or left expression: %v", exp)
+		}
+		lpos, err := exp.LExpr.termPositions(sortedMatchesByKeyword)
+		if err != nil {
+			return nil, err
+		}
+		rpos, err := exp.RExpr.termPositions(sortedMatchesByKeyword)
+		if err != nil {
+			return nil, err
+		}
+		return mergeArraysSorted(lpos, rpos), nil
+	default:
+		return nil, fmt.Errorf("NEAR operand must be a term or terms joined by OR but found %s", exp.GetTypeName())
+	}
+}
+
+// UsesWordDistance returns true if the expression has a NEAR operator
+// or an INORD operator with a gap that measures the distance in words.
+func (exp *Expression) UsesWordDistance() bool {
+	if exp == nil {
+		return false
+	}
+	if exp.DistanceUnit == WORD_DISTANCE {
+		switch exp.Type {
+		case NEAR_EXPR, ONEAR_EXPR:
+			return true
+		case INORD_EXPR:
+			if exp.Distance > 0 {
+				return true
+			}
+		}
+	}
+	for _, operand := range exp.Operands {
+		if operand.UsesWordDistance() {
+			return true
+		}
+	}
+	return exp.LExpr.UsesWordDistance() || exp.RExpr.UsesWordDistance()
+}
+
+// PrettyPrint returns the expression formated on a tabbed structure
+// Eg: for the expression ("a" and "b") or "c"
+//    OR
+//        AND
+//            a
+//            b
+//        c
+func (exp *Expression) PrettyFormat() string {
+	return exp.prettyFormat(0)
+}
+
+func (exp *Expression) prettyFormat(lvl int) (pprint string) {
+	tabs := "    "
+	onLVL := strings.Repeat(tabs, lvl)
+	if exp.Type == UNIT_EXPR {
+		return fmt.Sprintf("%s%s\n", onLVL, exp.Literal)
+	}
+	pprint = fmt.Sprintf("%s%s\n", onLVL, exp.GetTypeName())
+	switch exp.Type {
+	case NEAR_EXPR, ONEAR_EXPR:
+		pprint = fmt.Sprintf("%s%s/%d%s\n", onLVL, exp.GetTypeName(), exp.Distance, exp.DistanceUnit.suffix())
+	case INORD_EXPR:
+		if exp.Distance > 0 {
+			pprint = fmt.Sprintf("%s%s/%d%s\n", onLVL, exp.GetTypeName(), exp.Distance, exp.DistanceUnit.suffix())
+		}
+	case ATLEAST_EXPR, EXACTLY_EXPR, ATMOST_EXPR:
+		pprint = fmt.Sprintf("%s%s %d\n", onLVL, exp.GetTypeName(), exp.Threshold)
+	case COUNT_EXPR:
+		pprint = fmt.Sprintf("%s%s %s %d\n", onLVL, exp.GetTypeName(), exp.Comparator, exp.Threshold)
+	}
+	if exp.LExpr != nil {
+		pprint += exp.LExpr.prettyFormat(lvl + 1)
+	}
+
+	if exp.RExpr != nil {
+		pprint += exp.RExpr.prettyFormat(lvl + 1)
+	}
+
+	for _, operand := range exp.Operands {
+		pprint += operand.prettyFormat(lvl + 1)
+	}
+
+	return
+}
+
+// getLowestIdxGTVal uses binary search to find the
+// index of the lowest element that is greater than 'value'
+func getLowestIdxGTVal(positions []int, value int) int {
+	left := 0
+	right := len(positions) - 1
+	lwGrtI := -1
+	for left <= right {
+		half := (left + right) >> 1 // divide by 2
+		if positions[half] > value {
+			lwGrtI = half
+			right = half - 1
+		} else {
+			left = half + 1
+		}
+	}
+	return lwGrtI
+}
+
+// isNear returns true if there is a pair of positions, one of each
+// sorted array, whose distance is at most maxDistance.
+func isNear(lpos []int, rpos []int, maxDistance int) bool {
+	lIdx := 0
+	rIdx := 0
+	for lIdx < len(lpos) && rIdx < len(rpos) {
+		diff := rpos[rIdx] - lpos[lIdx]
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= maxDistance {
+			return true
+		}
+		if lpos[lIdx] < rpos[rIdx] {
+			lIdx++
+		} else {
+			rIdx++
+		}
+	}
+	return false
+}
+
+// isOrderedNear returns true if there is a position on rpos that comes after
+// a position on lpos with a distance of at most maxDistance. lDist and rDist
+// are the positions of lpos and rpos on the unit used to measure the distance.
+func isOrderedNear(lpos []int, rpos []int, lDist []int, rDist []int, maxDistance int) bool {
+	for i, lp := range lpos {
+		idx := getLowestIdxGTVal(rpos, lp)
+		if idx < 0 {
+			return false
+		}
+		if rDist[idx]-lDist[i] <= maxDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeArraysSorted merges two sorted arrays into a new sorted array
+func mergeArraysSorted(lArr []int, rArr []int) []int {
+	leftIdx := 0
+	rightIdx := 0
+	if len(lArr) == 0 {
+		return rArr
+	}
+	if len(rArr) == 0 {
+		return lArr
+	}
+	lSize := len(lArr)
+	rSize := len(rArr)
+	sumSize := lSize + rSize
+	outArr := make([]int, sumSize)
+	count := 0
+
+	for count < sumSize {
+		switch {
+		case leftIdx == lSize:
+			outArr[count] = rArr[rightIdx]
+			rightIdx++
+		case rightIdx == rSize:
+			outArr[count] = lArr[leftIdx]
+			leftIdx++
+		case lArr[leftIdx] < rArr[rightIdx]:
+			outArr[count] = lArr[leftIdx]
+			leftIdx++
+		default:
+			outArr[count] = rArr[rightIdx]
+			rightIdx++
+		}
+		count++
+	}
+	return outArr
+}
@@ -310,4 +310,384 @@ var solverTestCases = []struct {
 		expectedResp: true,
 		message:      "multiple inord with regex",
 	},
+	{
+		expStr: `INORD(("a" or "b") and "c" and ("d" and "e"))`,
+		// dbcae
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {3},
+			"b": {1},
+			"c": {2},
+			"d": {0},
+			"e": {4},
+		},
+		expectedResp: false,
+		message:      "inord nested sequence out of order",
+	},
+	{
+		expStr: `inord("a" and not "x" and "b")`,
+		// axbab
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0, 3},
+			"b": {2, 4},
+			"x": {1},
+		},
+		expectedResp: true,
+		message:      "inord not between the neighbours",
+	},
+	{
+		expStr: `inord("a" and not "x" and "b")`,
+		// axb
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0},
+			"b": {2},
+			"x": {1},
+		},
+		expectedResp: false,
+		message:      "inord not between the neighbours false",
+	},
+	{
+		expStr: `inord(not "x" and "a") and inord("a" and not "x")`,
+		// xax
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {1},
+			"x": {0, 2},
+		},
+		expectedResp: false,
+		message:      "inord not before the first and after the last operand",
+	},
+	{
+		expStr: `inord/2("a" and "b")`,
+		// aXXXbXab
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0, 6},
+			"b": {4, 7},
+		},
+		expectedResp: true,
+		message:      "inord gap true",
+	},
+	{
+		expStr: `inord/2("a" and "b")`,
+		// aXXXb
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0},
+			"b": {4},
+		},
+		expectedResp: false,
+		message:      "inord gap false",
+	},
+	{
+		expStr: `inord("a" and inord/1("b" and "c"))`,
+		// abXXcbc
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0},
+			"b": {1, 5},
+			"c": {4, 6},
+		},
+		expectedResp: true,
+		message:      "nested inord with gap",
+	},
+	// near tests
+	{
+		expStr: `"a" near/2 "b"`,
+		// bXXa
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {3},
+			"b": {0},
+		},
+		expectedResp: false,
+		message:      "near false",
+	},
+	{
+		expStr: `"a" near/3 "b"`,
+		// bXXa
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {3},
+			"b": {0},
+		},
+		expectedResp: true,
+		message:      "near true unordered",
+	},
+	{
+		expStr: `"a" onear/3 "b"`,
+		// bXXa
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {3},
+			"b": {0},
+		},
+		expectedResp: false,
+		message:      "ordered near false",
+	},
+	{
+		expStr: `"a" onear/3 ("b" or "c")`,
+		// bXXaXXXXcXb
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {3},
+			"b": {0, 10},
+			"c": {8},
+		},
+		expectedResp: false,
+		message:      "ordered near with or false",
+	},
+	{
+		expStr: `"a" onear/5 ("b" or "c")`,
+		// bXXaXXXXcXb
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {3},
+			"b": {0, 10},
+			"c": {8},
+		},
+		expectedResp: true,
+		message:      "ordered near with or true",
+	},
+	{
+		expStr: `"a" near/5 "b" and not "c"`,
+		// aXXXXb
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0},
+			"b": {5},
+		},
+		expectedResp: true,
+		message:      "near with and not",
+	},
+	{
+		expStr: `"a" near/5 "b"`,
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0},
+		},
+		expectedResp: false,
+		message:      "near missing term",
+	},
+	// threshold tests
+	{
+		expStr: `ATLEAST(2, "a", "b", r"c")`,
+		sortedMatchesByKeyword: map[string][]int{
+			"a": nil,
+			"c": nil,
+		},
+		expectedResp: true,
+		message:      "atleast true",
+	},
+	{
+		expStr: `ATLEAST(2, "a", "b", r"c")`,
+		sortedMatchesByKeyword: map[string][]int{
+			"b": nil,
+		},
+		expectedResp: false,
+		message:      "atleast false",
+	},
+	{
+		expStr: `EXACTLY(2, "a", "b", "c")`,
+		sortedMatchesByKeyword: map[string][]int{
+			"a": nil,
+			"b": nil,
+			"c": nil,
+		},
+		expectedResp: false,
+		message:      "exactly false",
+	},
+	{
+		expStr: `EXACTLY(2, "a", "b", "c") and "d"`,
+		sortedMatchesByKeyword: map[string][]int{
+			"b": nil,
+			"c": nil,
+			"d": nil,
+		},
+		expectedResp: true,
+		message:      "exactly true",
+	},
+	{
+		expStr: `ATMOST(1, "a", "b", "c")`,
+		sortedMatchesByKeyword: map[string][]int{
+			"a": nil,
+			"c": nil,
+		},
+		expectedResp: false,
+		message:      "atmost false",
+	},
+	{
+		expStr:                 `ATMOST(1, "a", "b", "c")`,
+		sortedMatchesByKeyword: map[string][]int{},
+		expectedResp:           true,
+		message:                "atmost true",
+	},
+	// count tests
+	{
+		expStr: `COUNT("a") >= 3`,
+		// aaaa with overlapping matches of "aa"
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0, 1, 2},
+		},
+		expectedResp: true,
+		message:      "count greater or equal true",
+	},
+	{
+		expStr: `COUNT("a") > 3`,
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0, 1, 2},
+		},
+		expectedResp: false,
+		message:      "count greater false",
+	},
+	{
+		expStr:                 `COUNT("a") < 1`,
+		sortedMatchesByKeyword: map[string][]int{},
+		expectedResp:           true,
+		message:                "count less missing term",
+	},
+	{
+		expStr: `COUNT("a") <= 1 and COUNT("b") == 2 and COUNT("c") != 2`,
+		sortedMatchesByKeyword: map[string][]int{
+			"a": {0},
+			"b": {1, 4},
+			"c": {6},
+		},
+		expectedResp: true,
+		message:      "count multiple comparisons",
+	},
+}
+
+func TestPrettyFormat(t *testing.T) {
+	assert := assert.New(t)
+	exp, err := NewParser(strings.NewReader(
+		`("a" NEAR/5 "b" or ATLEAST(2, "c", "d", "e")) and COUNT("f") >= 3`,
+	), true).Parse()
+	assert.Nil(err)
+	expected := `AND
+    OR
+        NEAR/5w
+            a
+            b
+        ATLEAST 2
+            c
+            d
+            e
+    COUNT >= 3
+        f
+`
+	assert.Equal(expected, exp.PrettyFormat())
+}
+
+func TestSolveWithWordIndex(t *testing.T) {
+	assert := assert.New(t)
+	text := "the account, that was created yesterday, is suspended"
+	matches := map[string][]int{
+		"account":   {strings.Index(text, "account")},
+		"suspended": {strings.Index(text, "suspended")},
+	}
+	wordIndex := NewWordIndex(text)
+
+	tests := []struct {
+		expStr       string
+		expectedResp bool
+		message      string
+	}{
+		{`"account" NEAR/6 "suspended"`, true, "word distance true"},
+		{`"account" NEAR/5 "suspended"`, false, "word distance false"},
+		{`"suspended" NEAR/6 "account"`, true, "word distance unordered"},
+		{`"suspended" ONEAR/6 "account"`, false, "word distance ordered"},
+		{`"account" NEAR/40c "suspended"`, true, "char distance true"},
+		{`"account" NEAR/39c "suspended"`, false, "char distance false"},
+	}
+
+	for _, tc := range tests {
+		exp, err := NewParser(strings.NewReader(tc.expStr), true).Parse()
+		assert.Nil(err, tc.message)
+		resp, err := exp.SolveWithWordIndex(matches, wordIndex)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, resp, tc.message)
+	}
+}
+
+func TestWordIndex(t *testing.T) {
+	assert := assert.New(t)
+	wordIndex := NewWordIndex("  foo, bar_1")
+	wordIndex.Append("baz ção")
+	wordIndex.Append(" x")
+	assert.Equal(-1, wordIndex.WordPosition(0))
+	assert.Equal(0, wordIndex.WordPosition(2))
+	assert.Equal(0, wordIndex.WordPosition(6))
+	assert.Equal(1, wordIndex.WordPosition(7))
+	assert.Equal(1, wordIndex.WordPosition(14), "word continues on the next chunk")
+	assert.Equal(2, wordIndex.WordPosition(16))
+	assert.Equal(3, wordIndex.WordPosition(23))
+}
+
+func TestSolveWithMatchedTerms(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		expStr                 string
+		sortedMatchesByKeyword map[string][]int
+		expectedResp           bool
+		expectedTerms          map[string]struct{}
+		message                string
+	}{
+		{
+			expStr: `"1" and "2"`,
+			sortedMatchesByKeyword: map[string][]int{
+				"1": {0},
+				"2": {3},
+			},
+			expectedResp:  true,
+			expectedTerms: map[string]struct{}{"1": {}, "2": {}},
+			message:       "and with both terms",
+		},
+		{
+			expStr: `"1" and "2"`,
+			sortedMatchesByKeyword: map[string][]int{
+				"1": {0},
+			},
+			expectedResp:  false,
+			expectedTerms: nil,
+			message:       "false expression",
+		},
+		{
+			expStr: `"1" or ("2" and "3")`,
+			sortedMatchesByKeyword: map[string][]int{
+				"1": {0},
+				"2": {3},
+			},
+			expectedResp:  true,
+			expectedTerms: map[string]struct{}{"1": {}},
+			message:       "or ignores the false branch",
+		},
+		{
+			expStr: `"1" and not ("2" or "3")`,
+			sortedMatchesByKeyword: map[string][]int{
+				"1": {0},
+			},
+			expectedResp:  true,
+			expectedTerms: map[string]struct{}{"1": {}},
+			message:       "not does not contribute",
+		},
+		{
+			expStr: `INORD("1" and ("2" or "3"))`,
+			sortedMatchesByKeyword: map[string][]int{
+				"1": {0},
+				"2": {3},
+				"3": {5},
+			},
+			expectedResp:  true,
+			expectedTerms: map[string]struct{}{"1": {}, "2": {}, "3": {}},
+			message:       "inord with or",
+		},
+		{
+			expStr: `ATLEAST(1, "1", "2", "3")`,
+			sortedMatchesByKeyword: map[string][]int{
+				"1": {0},
+				"3": {5},
+			},
+			expectedResp:  true,
+			expectedTerms: map[string]struct{}{"1": {}, "3": {}},
+			message:       "atleast with matched terms",
+		},
+	}
+
+	for _, tc := range tests {
+		exp, err := NewParser(strings.NewReader(tc.expStr), true).Parse()
+		assert.Nil(err, tc.message)
+		resp, terms, err := exp.SolveWithMatchedTerms(tc.sortedMatchesByKeyword, nil)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, resp, tc.message)
+		assert.Equal(tc.expectedTerms, terms, tc.message)
+	}
 }
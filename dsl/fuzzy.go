@@ -0,0 +1,53 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FuzzyTerm is a term that is found with up to Distance edits
+// (insertions, deletions or substitutions of runes). Eg: F1"password"
+type FuzzyTerm struct {
+	Term     string
+	Distance int
+}
+
+// NewFuzzyTerm returns a new FuzzyTerm. Returns an error if the term has
+// no word characters or if the distance is not lower than the length of the
+// term, since any word would be found with it.
+func NewFuzzyTerm(term string, distance int) (FuzzyTerm, error) {
+	if strings.IndexFunc(term, IsWordRune) < 0 {
+		return FuzzyTerm{}, fmt.Errorf("invalid fuzzy term '%s': the term must have a word character", term)
+	}
+	if distance < 0 || distance >= utf8.RuneCountInString(term) {
+		return FuzzyTerm{}, fmt.Errorf(
+			"invalid fuzzy term '%s': the distance %d must be lower than the length of the term",
+			term,
+			distance,
+		)
+	}
+	return FuzzyTerm{Term: term, Distance: distance}, nil
+}
+
+// parseFuzzyLiteral parses the literal of a FUZZY token, that is
+// the distance followed by the quoted term. Eg: 1"password"
+func parseFuzzyLiteral(lit string) (FuzzyTerm, error) {
+	quote := strings.IndexByte(lit, '"')
+	if quote <= 0 || len(lit) < quote+2 || lit[len(lit)-1] != '"' {
+		return FuzzyTerm{}, fmt.Errorf("invalid fuzzy term '%s'", lit)
+	}
+	distance, err := strconv.Atoi(lit[:quote])
+	if err != nil {
+		return FuzzyTerm{}, fmt.Errorf("invalid fuzzy term '%s': %v", lit, err)
+	}
+	return NewFuzzyTerm(lit[quote+1:len(lit)-1], distance)
+}
+
+// Key returns the key of the fuzzy term on the map of matches used to solve
+// the expressions, that is f1"password" after the termKeyMark (see WordTermKey).
+// The prefix is lowercase so the key is kept when the terms are changed to lowercase.
+func (ft FuzzyTerm) Key() string {
+	return termKeyMark + "f" + strconv.Itoa(ft.Distance) + `"` + ft.Term + `"`
+}
@@ -0,0 +1,362 @@
+package dsl
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The expression enclosed by INORD is a pattern of terms that must be found in order:
+//   - AND is a sequence, where each operand must be found after the previous one;
+//   - OR is an alternation, where one of the operands must be found;
+//   - NOT is a gap constraint of a sequence, where its terms must not be found between
+//     the neighbour operands (or before the first or after the last operand);
+//   - a nested INORD groups a sequence with its own gap.
+// The gap of an INORD (eg: INORD/3) is the maximum distance between the consecutive
+// operands of its sequences, that is measured from the last term of an operand to the
+// first term of the next one. An INORD without gap has no distance limit.
+// Eg: INORD/5("a" and not "x" and INORD/1("b" and "c")) is true if there is an "a" followed
+// by "b" and "c", with at most 5 words between "a" and "b", no "x" between "a" and "b" and
+// at most 1 word between "b" and "c".
+//
+// The pattern is compiled to an automaton whose states are the terms of the pattern
+// (see inordPattern), that is run over the matches of the terms sorted by position.
+
+// checkInordPattern returns an error if the expression enclosed
+// by an INORD operator is not a valid pattern.
+func checkInordPattern(exp *Expression) error {
+	switch exp.Type {
+	case UNIT_EXPR:
+		return nil
+	case INORD_EXPR:
+		return checkInordPattern(exp.RExpr)
+	case OR_EXPR:
+		for _, child := range []*Expression{exp.LExpr, exp.RExpr} {
+			if child.Type == NOT_EXPR {
+				return fmt.Errorf("invalid expression: NOT operator inside INORD must be an operand of AND")
+			}
+			if err := checkInordPattern(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case AND_EXPR:
+		positives := 0
+		for _, item := range sequenceItems(exp) {
+			if item.Type != NOT_EXPR {
+				positives++
+				if err := checkInordPattern(item); err != nil {
+					return err
+				}
+				continue
+			}
+			if !isTermGroup(item.RExpr) {
+				return fmt.Errorf("invalid expression: NOT operand inside INORD must be a term or terms joined by OR")
+			}
+		}
+		if positives == 0 {
+			return fmt.Errorf("invalid expression: INORD sequence must have an operand that is not negated")
+		}
+		return nil
+	case NOT_EXPR:
+		return fmt.Errorf("invalid expression: INORD sequence must have an operand that is not negated")
+	default:
+		return fmt.Errorf("invalid expression: INORD operator must not contain %s operator", exp.GetTypeName())
+	}
+}
+
+// sequenceItems returns the operands of a sequence of AND operators, that
+// are the operands of the nested AND operators that are not enclosed by INORD.
+// Eg: ("a" and "b") and not "c" has the items "a", "b" and not "c".
+func sequenceItems(exp *Expression) []*Expression {
+	if exp.Type != AND_EXPR {
+		return []*Expression{exp}
+	}
+	return append(sequenceItems(exp.LExpr), sequenceItems(exp.RExpr)...)
+}
+
+// inordPattern is the automaton of an INORD pattern. Each term of the pattern is a state, that
+// holds the position of the last match of the term that ended a partial match of the pattern.
+// A transition from a state to the next one happens on a match of the term of the next state that
+// is after the position of the state and satisfies the gap and the NOT constraints between them.
+// Since a later position satisfies the constraints of any next match at least as well as an earlier
+// one, each state only needs to keep its latest position.
+type inordPattern struct {
+	// terms are the terms of the states.
+	terms []string
+	// transitions are the transitions by the term of their destination state.
+	transitions map[string][]inordTransition
+	// accepts are the states that end a match of the pattern
+	// with the NOT constraints after them.
+	accepts []inordEnd
+}
+
+// inordStart is the state before the first term of the pattern.
+const inordStart = -1
+
+// inordEndPosition is a position after the end of any text.
+const inordEndPosition = int(^uint(0) >> 1)
+
+// inordTransition is a transition between two states of an inordPattern.
+type inordTransition struct {
+	from int
+	to   int
+	// gap is the maximum distance between the positions of the states, 0 if there is no limit.
+	gap  int
+	unit DistanceUnit
+	// nots are the terms that must not be found between the positions of the states.
+	nots []string
+}
+
+// inordEnd is a state at the start or at the end of a subpattern with the
+// NOT constraints that are checked before or after it, respectively.
+type inordEnd struct {
+	state int
+	nots  []string
+}
+
+// inordFragment holds the first and the last states of a subpattern.
+type inordFragment struct {
+	first []inordEnd
+	last  []inordEnd
+}
+
+// compileInord compiles the pattern of the INORD expression.
+func compileInord(exp *Expression) (*inordPattern, error) {
+	pattern := &inordPattern{transitions: make(map[string][]inordTransition)}
+	frag, err := pattern.compile(exp, 0, WORD_DISTANCE)
+	if err != nil {
+		return nil, err
+	}
+	for _, first := range frag.first {
+		pattern.addTransition(inordTransition{from: inordStart, to: first.state, nots: first.nots})
+	}
+	pattern.accepts = frag.last
+	return pattern, nil
+}
+
+// compile adds the states and the transitions of the subpattern to the pattern. The gap and
+// unit are the ones of the closest INORD operator, that are used between the operands of AND.
+func (pattern *inordPattern) compile(exp *Expression, gap int, unit DistanceUnit) (inordFragment, error) {
+	switch exp.Type {
+	case UNIT_EXPR:
+		state := len(pattern.terms)
+		pattern.terms = append(pattern.terms, exp.Literal)
+		end := []inordEnd{{state: state}}
+		return inordFragment{first: end, last: end}, nil
+
+	case INORD_EXPR:
+		if exp.RExpr == nil {
+			return inordFragment{}, fmt.Errorf("INORD statement do not have expression: %v", exp)
+		}
+		return pattern.compile(exp.RExpr, exp.Distance, exp.DistanceUnit)
+
+	case OR_EXPR:
+		if exp.LExpr == nil || exp.RExpr == nil {
+			return inordFragment{}, fmt.Errorf("OR statment do not have rigth or left expression: %v", exp)
+		}
+		lfrag, err := pattern.compile(exp.LExpr, gap, unit)
+		if err != nil {
+			return inordFragment{}, err
+		}
+		rfrag, err := pattern.compile(exp.RExpr, gap, unit)
+		if err != nil {
+			return inordFragment{}, err
+		}
+		return inordFragment{
+			first: append(lfrag.first, rfrag.first...),
+			last:  append(lfrag.last, rfrag.last...),
+		}, nil
+
+	case AND_EXPR:
+		if exp.LExpr == nil || exp.RExpr == nil {
+			return inordFragment{}, fmt.Errorf("AND statment do not have rigth or left expression: %v", exp)
+		}
+		var seq *inordFragment
+		// nots are the NOT constraints since the previous operand
+		var nots []string
+		for _, item := range sequenceItems(exp) {
+			if item.Type == NOT_EXPR {
+				if item.RExpr == nil {
+					return inordFragment{}, fmt.Errorf("NOT statement do not have expression: %v", item)
+				}
+				for term := range item.RExpr.Terms() {
+					nots = append(nots, term)
+				}
+				continue
+			}
+
+			frag, err := pattern.compile(item, gap, unit)
+			if err != nil {
+				return inordFragment{}, err
+			}
+			if seq == nil {
+				seq = &inordFragment{first: withNots(frag.first, nots)}
+			} else {
+				for _, last := range seq.last {
+					for _, first := range frag.first {
+						pattern.addTransition(inordTransition{
+							from: last.state,
+							to:   first.state,
+							gap:  gap,
+							unit: unit,
+							nots: append(append(append([]string{}, last.nots...), nots...), first.nots...),
+						})
+					}
+				}
+			}
+			seq.last = frag.last
+			nots = nil
+		}
+		if seq == nil {
+			return inordFragment{}, fmt.Errorf("INORD sequence do not have an operand that is not negated: %v", exp)
+		}
+		seq.last = withNots(seq.last, nots)
+		return *seq, nil
+
+	default:
+		return inordFragment{}, fmt.Errorf("INORD statement must not contain %s statement: %v", exp.GetTypeName(), exp)
+	}
+}
+
+// withNots returns the ends with the NOT constraints added to them.
+func withNots(ends []inordEnd, nots []string) []inordEnd {
+	if len(nots) == 0 {
+		return ends
+	}
+	withNots := make([]inordEnd, len(ends))
+	for i, end := range ends {
+		withNots[i] = inordEnd{state: end.state, nots: append(append([]string{}, end.nots...), nots...)}
+	}
+	return withNots
+}
+
+// addTransition adds the transition to the pattern.
+func (pattern *inordPattern) addTransition(tr inordTransition) {
+	term := pattern.terms[tr.to]
+	pattern.transitions[term] = append(pattern.transitions[term], tr)
+}
+
+// inordEvent is a match of a term of the pattern.
+type inordEvent struct {
+	position int
+	term     string
+}
+
+// match returns true if the terms are found on the order of the pattern.
+func (pattern *inordPattern) match(sortedMatchesByKeyword map[string][]int, wordIndex *WordIndex) bool {
+	var events []inordEvent
+	for term := range pattern.transitions {
+		for _, pos := range sortedMatchesByKeyword[term] {
+			events = append(events, inordEvent{position: pos, term: term})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].position < events[j].position
+	})
+
+	// positions holds the position of each state, -1 if the state was not reached
+	positions := make([]int, len(pattern.terms))
+	for i := range positions {
+		positions[i] = -1
+	}
+	type update struct{ state, position int }
+	var updates []update
+	for i := 0; i < len(events); {
+		// the matches at the same position are applied together,
+		// since a match must be after the position of its previous state
+		pos := events[i].position
+		updates = updates[:0]
+		for ; i < len(events) && events[i].position == pos; i++ {
+			for _, tr := range pattern.transitions[events[i].term] {
+				from := inordStart
+				if tr.from != inordStart {
+					from = positions[tr.from]
+					if from < 0 {
+						continue
+					}
+				}
+				if tr.gap > 0 && from != inordStart && distance(from, pos, tr.unit, wordIndex) > tr.gap {
+					continue
+				}
+				if anyMatchBetween(sortedMatchesByKeyword, tr.nots, from, pos) {
+					continue
+				}
+				updates = append(updates, update{state: tr.to, position: pos})
+			}
+		}
+		for _, up := range updates {
+			positions[up.state] = up.position
+		}
+	}
+
+	for _, accept := range pattern.accepts {
+		pos := positions[accept.state]
+		if pos >= 0 && !anyMatchBetween(sortedMatchesByKeyword, accept.nots, pos, inordEndPosition) {
+			return true
+		}
+	}
+	return false
+}
+
+// distance returns the distance between the positions on the unit. If the unit is
+// words and the wordIndex is nil the positions are used as they are.
+func distance(from int, to int, unit DistanceUnit, wordIndex *WordIndex) int {
+	if unit == WORD_DISTANCE && wordIndex != nil {
+		return wordIndex.WordPosition(to) - wordIndex.WordPosition(from)
+	}
+	return to - from
+}
+
+// anyMatchBetween returns true if any of the terms has a match after start and before end.
+func anyMatchBetween(sortedMatchesByKeyword map[string][]int, terms []string, start int, end int) bool {
+	for _, term := range terms {
+		positions := sortedMatchesByKeyword[term]
+		idx := getLowestIdxGTVal(positions, start)
+		if idx >= 0 && positions[idx] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// inordPresence returns false if a term that is needed by every match of the INORD
+// pattern was not found, unknown if it depends on the unknown terms and true otherwise.
+// It does not check the order of the terms nor the NOT constraints.
+func (exp *Expression) inordPresence(sortedMatchesByKeyword map[string][]int, unknownTerms map[string]struct{}) truth {
+	switch exp.Type {
+	case UNIT_EXPR:
+		if _, ok := unknownTerms[exp.Literal]; ok {
+			return unknownTruth
+		}
+		_, ok := sortedMatchesByKeyword[exp.Literal]
+		return newTruth(ok)
+	case INORD_EXPR:
+		return exp.RExpr.inordPresence(sortedMatchesByKeyword, unknownTerms)
+	case OR_EXPR:
+		lval := exp.LExpr.inordPresence(sortedMatchesByKeyword, unknownTerms)
+		rval := exp.RExpr.inordPresence(sortedMatchesByKeyword, unknownTerms)
+		if lval == trueTruth || rval == trueTruth {
+			return trueTruth
+		}
+		if lval == unknownTruth || rval == unknownTruth {
+			return unknownTruth
+		}
+		return falseTruth
+	case AND_EXPR:
+		val := trueTruth
+		for _, item := range sequenceItems(exp) {
+			if item.Type == NOT_EXPR {
+				continue
+			}
+			switch item.inordPresence(sortedMatchesByKeyword, unknownTerms) {
+			case falseTruth:
+				return falseTruth
+			case unknownTruth:
+				val = unknownTruth
+			}
+		}
+		return val
+	default:
+		return unknownTruth
+	}
+}
@@ -0,0 +1,240 @@
+package dsl
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// oracleNode is a pattern of an INORD expression that is solved by brute force.
+// It is either a term, an alternation of nodes, a sequence of items or a nested INORD.
+type oracleNode struct {
+	term     string
+	or       []*oracleNode
+	sequence []oracleItem
+	inord    *oracleNode
+	gap      int
+}
+
+// oracleItem is an operand of a sequence. If nots is not empty the item is
+// a NOT constraint of the terms, otherwise it is the node.
+type oracleItem struct {
+	node *oracleNode
+	nots []string
+}
+
+// oracleSpan is a match of a pattern from the position of its first term to the position of its
+// last term, with the NOT constraints that must be checked before and after it.
+type oracleSpan struct {
+	start int
+	end   int
+	pre   []string
+	post  []string
+}
+
+func (node *oracleNode) String() string {
+	switch {
+	case node.term != "":
+		return fmt.Sprintf(`"%s"`, node.term)
+	case node.or != nil:
+		return fmt.Sprintf("(%s or %s)", node.or[0], node.or[1])
+	case node.inord != nil:
+		if node.gap > 0 {
+			return fmt.Sprintf("inord/%d(%s)", node.gap, node.inord)
+		}
+		return fmt.Sprintf("inord(%s)", node.inord)
+	}
+	var items []string
+	for _, item := range node.sequence {
+		if len(item.nots) == 0 {
+			items = append(items, item.node.String())
+			continue
+		}
+		nots := make([]string, len(item.nots))
+		for i, term := range item.nots {
+			nots[i] = fmt.Sprintf(`"%s"`, term)
+		}
+		items = append(items, fmt.Sprintf("not (%s)", strings.Join(nots, " or ")))
+	}
+	return fmt.Sprintf("(%s)", strings.Join(items, " and "))
+}
+
+// spans returns all the matches of the node, using gap between the operands of its sequences.
+func (node *oracleNode) spans(matches map[string][]int, gap int) []oracleSpan {
+	switch {
+	case node.term != "":
+		var spans []oracleSpan
+		for _, pos := range matches[node.term] {
+			spans = append(spans, oracleSpan{start: pos, end: pos})
+		}
+		return spans
+	case node.or != nil:
+		return append(node.or[0].spans(matches, gap), node.or[1].spans(matches, gap)...)
+	case node.inord != nil:
+		return node.inord.spans(matches, node.gap)
+	}
+
+	var seq []oracleSpan
+	started := false
+	var nots []string
+	for _, item := range node.sequence {
+		if len(item.nots) > 0 {
+			nots = append(nots, item.nots...)
+			continue
+		}
+		spans := item.node.spans(matches, gap)
+		if !started {
+			for _, span := range spans {
+				span.pre = append(append([]string{}, span.pre...), nots...)
+				seq = append(seq, span)
+			}
+			started = true
+			nots = nil
+			continue
+		}
+		var next []oracleSpan
+		for _, prev := range seq {
+			for _, span := range spans {
+				if prev.end >= span.start || (gap > 0 && span.start-prev.end > gap) {
+					continue
+				}
+				between := append(append(append([]string{}, prev.post...), nots...), span.pre...)
+				if oracleAnyBetween(matches, between, prev.end, span.start) {
+					continue
+				}
+				next = append(next, oracleSpan{start: prev.start, end: span.end, pre: prev.pre, post: span.post})
+			}
+		}
+		seq = next
+		nots = nil
+	}
+	for i := range seq {
+		seq[i].post = append(append([]string{}, seq[i].post...), nots...)
+	}
+	return seq
+}
+
+// oracleAnyBetween returns true if any of the terms has a match after start and before end.
+func oracleAnyBetween(matches map[string][]int, terms []string, start int, end int) bool {
+	for _, term := range terms {
+		for _, pos := range matches[term] {
+			if pos > start && pos < end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// oracleSolve solves the INORD node by brute force.
+func oracleSolve(node *oracleNode, matches map[string][]int) bool {
+	for _, span := range node.spans(matches, 0) {
+		if !oracleAnyBetween(matches, span.pre, -1, span.start) &&
+			!oracleAnyBetween(matches, span.post, span.end, inordEndPosition) {
+			return true
+		}
+	}
+	return false
+}
+
+var oracleTerms = []string{"a", "b", "c", "d"}
+
+// randomPattern returns a random pattern with at most depth levels of operators.
+func randomPattern(rnd *rand.Rand, depth int) *oracleNode {
+	if depth == 0 || rnd.Intn(4) == 0 {
+		return &oracleNode{term: oracleTerms[rnd.Intn(len(oracleTerms))]}
+	}
+	switch rnd.Intn(4) {
+	case 0:
+		return &oracleNode{or: []*oracleNode{randomPattern(rnd, depth-1), randomPattern(rnd, depth-1)}}
+	case 1:
+		return randomInord(rnd, depth-1)
+	default:
+		node := &oracleNode{}
+		positives := 0
+		for i := 1 + rnd.Intn(3); i >= 0; i-- {
+			if rnd.Intn(3) == 0 {
+				nots := []string{oracleTerms[rnd.Intn(len(oracleTerms))]}
+				if rnd.Intn(2) == 0 {
+					nots = append(nots, oracleTerms[rnd.Intn(len(oracleTerms))])
+				}
+				node.sequence = append(node.sequence, oracleItem{nots: nots})
+				continue
+			}
+			node.sequence = append(node.sequence, oracleItem{node: randomPattern(rnd, depth-1)})
+			positives++
+		}
+		if positives == 0 {
+			node.sequence = append(node.sequence, oracleItem{node: randomPattern(rnd, depth-1)})
+		}
+		return node
+	}
+}
+
+// randomInord returns a random INORD node with an optional gap.
+func randomInord(rnd *rand.Rand, depth int) *oracleNode {
+	node := &oracleNode{inord: randomPattern(rnd, depth)}
+	if rnd.Intn(2) == 0 {
+		node.gap = 1 + rnd.Intn(4)
+	}
+	return node
+}
+
+// randomMatches returns random sorted matches of the terms.
+func randomMatches(rnd *rand.Rand) map[string][]int {
+	matches := make(map[string][]int)
+	for _, term := range oracleTerms {
+		var positions []int
+		for pos := 0; pos < 12; pos++ {
+			if rnd.Intn(4) == 0 {
+				positions = append(positions, pos)
+			}
+		}
+		if len(positions) > 0 {
+			sort.Ints(positions)
+			matches[term] = positions
+		}
+	}
+	return matches
+}
+
+func TestInordMatchesOracle(t *testing.T) {
+	assert := assert.New(t)
+	rnd := rand.New(rand.NewSource(42))
+	for i := 0; i < 2000; i++ {
+		pattern := randomInord(rnd, 3)
+		expStr := pattern.String()
+		exp, err := NewParser(strings.NewReader(expStr), true).Parse()
+		if !assert.Nil(err, expStr) {
+			continue
+		}
+		for j := 0; j < 10; j++ {
+			matches := randomMatches(rnd)
+			val, err := exp.Solve(matches)
+			assert.Nil(err, expStr)
+			assert.Equal(oracleSolve(pattern, matches), val, "%s %v", expStr, matches)
+		}
+	}
+}
+
+func TestInordPresence(t *testing.T) {
+	assert := assert.New(t)
+	rnd := rand.New(rand.NewSource(7))
+	for i := 0; i < 500; i++ {
+		pattern := randomInord(rnd, 3)
+		expStr := pattern.String()
+		exp, err := NewParser(strings.NewReader(expStr), true).Parse()
+		if !assert.Nil(err, expStr) {
+			continue
+		}
+		// a pattern whose needed terms are missing can not match
+		matches := randomMatches(rnd)
+		if exp.inordPresence(matches, nil) == falseTruth {
+			assert.False(oracleSolve(pattern, matches), "%s %v", expStr, matches)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package dsl
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// Normalization is a set of normalization forms that are applied to the terms and texts.
+type Normalization uint
+
+const (
+	// CaseFolding applies the Unicode full case folding. Eg: "İ" to "i̇" and "ß" to "ss"
+	CaseFolding Normalization = 1 << iota
+	// NFKC applies the Unicode compatibility composition. Eg: "ﬁ" to "fi"
+	NFKC
+	// DiacriticStripping removes the nonspacing marks. Eg: "café" to "cafe"
+	DiacriticStripping
+	// WidthFolding maps the fullwidth and halfwidth runes to their canonical width. Eg: "Ａ" to "A"
+	WidthFolding
+)
+
+// Normalizer normalizes the terms and the texts with the pipeline of the
+// enabled normalization forms, that are applied on the following order:
+// WidthFolding, CaseFolding, NFKC and DiacriticStripping.
+// The text is normalized one segment at a time, where a segment is a rune
+// followed by the runes that may combine with it, so the positions on the
+// normalized text can be mapped to the original text by a PositionMap.
+type Normalizer struct {
+	forms Normalization
+}
+
+// NewNormalizer returns a new Normalizer with the given normalization forms.
+func NewNormalizer(forms Normalization) *Normalizer {
+	return &Normalizer{forms: forms}
+}
+
+// Forms returns the normalization forms of the normalizer.
+func (n *Normalizer) Forms() Normalization {
+	return n.forms
+}
+
+// NormalizeString returns the normalized string.
+func (n *Normalizer) NormalizeString(s string) string {
+	normalized, _ := n.Normalize(s)
+	return normalized
+}
+
+// Normalize returns the normalized text and the PositionMap
+// from the normalized text to the original one.
+func (n *Normalizer) Normalize(text string) (normalized string, positions *PositionMap) {
+	positions = &PositionMap{}
+	normalized = n.normalize(text, n.newTransformer(), positions, 0, 0)
+	return
+}
+
+// NewStream returns a new NormalizerStream that normalizes a text split in chunks.
+func (n *Normalizer) NewStream() *NormalizerStream {
+	return &NormalizerStream{
+		normalizer:  n,
+		transformer: n.newTransformer(),
+		positions:   &PositionMap{},
+	}
+}
+
+// newTransformer returns the chain of transformers of the enabled normalization forms.
+// The transformers are not safe for concurrent use, so a new chain is created for
+// each normalization.
+func (n *Normalizer) newTransformer() transform.Transformer {
+	steps := make([]transform.Transformer, 0, 6)
+	if n.forms&WidthFolding != 0 {
+		steps = append(steps, width.Fold)
+	}
+	if n.forms&CaseFolding != 0 {
+		steps = append(steps, cases.Fold())
+	}
+	if n.forms&NFKC != 0 {
+		steps = append(steps, norm.NFKC)
+	}
+	if n.forms&DiacriticStripping != 0 {
+		steps = append(steps, norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	}
+	return transform.Chain(steps...)
+}
+
+// normalize normalizes the text segment by segment with the transformer, adding the
+// segments that are changed to positions. origOffset and normOffset are the
+// positions of the first byte of the text on the original and normalized texts.
+func (n *Normalizer) normalize(
+	text string,
+	transformer transform.Transformer,
+	positions *PositionMap,
+	origOffset int,
+	normOffset int,
+) string {
+	if n.forms == 0 {
+		return text
+	}
+
+	buf := make([]byte, 0, len(text))
+	for start := 0; start < len(text); {
+		end := nextSegmentStart(text, start)
+		segment := text[start:end]
+
+		if ch := segment[0]; len(segment) == 1 && ch < utf8.RuneSelf {
+			// only the case folding changes the ASCII runes
+			if n.forms&CaseFolding != 0 && 'A' <= ch && ch <= 'Z' {
+				ch += 'a' - 'A'
+			}
+			buf = append(buf, ch)
+			start = end
+			continue
+		}
+
+		normSegment, _, err := transform.String(transformer, segment)
+		if err != nil {
+			normSegment = segment
+		}
+		if normSegment != segment {
+			positions.add(origOffset+start, origOffset+end, normOffset+len(buf), normOffset+len(buf)+len(normSegment))
+		}
+		buf = append(buf, normSegment...)
+		start = end
+	}
+	return string(buf)
+}
+
+// nextSegmentStart returns the start of the segment after the one that starts at start.
+func nextSegmentStart(text string, start int) int {
+	_, size := utf8.DecodeRuneInString(text[start:])
+	end := start + size
+	for end < len(text) && !norm.NFKC.PropertiesString(text[end:]).BoundaryBefore() {
+		_, size = utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	return end
+}
+
+// lastSegmentStart returns the start of the last segment of the text.
+func lastSegmentStart(text string) int {
+	start := len(text)
+	for start > 0 {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+		if norm.NFKC.PropertiesString(text[start:]).BoundaryBefore() {
+			break
+		}
+	}
+	return start
+}
+
+// NormalizerStream normalizes the chunks of a text. The last segment of each chunk
+// is kept to be normalized with the next chunk, since it may continue on it.
+type NormalizerStream struct {
+	normalizer  *Normalizer
+	transformer transform.Transformer
+	positions   *PositionMap
+	pending     string
+	origOffset  int
+	normOffset  int
+}
+
+// Next receives the next chunk of the text and returns its normalized
+// segments. If eof is set the kept segment is normalized as well.
+func (ns *NormalizerStream) Next(chunk string, eof bool) string {
+	text := ns.pending + chunk
+	end := len(text)
+	if !eof {
+		end = lastSegmentStart(text)
+	}
+	ns.pending = text[end:]
+
+	normalized := ns.normalizer.normalize(text[:end], ns.transformer, ns.positions, ns.origOffset, ns.normOffset)
+	ns.origOffset += end
+	ns.normOffset += len(normalized)
+	return normalized
+}
+
+// Positions returns the PositionMap from the normalized text to the original one.
+func (ns *NormalizerStream) Positions() *PositionMap {
+	return ns.positions
+}
+
+// PositionMap maps the positions on a normalized text to the original text.
+// It only holds the segments that were changed by the normalization, except for the
+// ASCII runes, since a match can not start or end inside them.
+type PositionMap struct {
+	normStarts []int
+	normEnds   []int
+	origStarts []int
+	origEnds   []int
+}
+
+// add adds a segment that was changed.
+func (pm *PositionMap) add(origStart, origEnd, normStart, normEnd int) {
+	pm.origStarts = append(pm.origStarts, origStart)
+	pm.origEnds = append(pm.origEnds, origEnd)
+	pm.normStarts = append(pm.normStarts, normStart)
+	pm.normEnds = append(pm.normEnds, normEnd)
+}
+
+// Original returns the range on the original text of the range from start to end
+// on the normalized text. A position inside a normalized segment is mapped to the
+// start of the original segment, or to its end if it is the end of the range.
+func (pm *PositionMap) Original(start int, end int) (origStart int, origEnd int) {
+	return pm.original(start, false), pm.original(end, true)
+}
+
+// original returns the position on the original text of the
+// position on the normalized text.
+func (pm *PositionMap) original(pos int, isEnd bool) int {
+	// i is the last segment that starts before or at pos
+	i := sort.Search(len(pm.normStarts), func(i int) bool { return pm.normStarts[i] > pos }) - 1
+	if isEnd {
+		// the segment that starts at the end of the range is not part of it
+		for i >= 0 && pm.normStarts[i] == pos && pm.normEnds[i] > pos {
+			i--
+		}
+	}
+	if i < 0 {
+		return pos
+	}
+	if pos < pm.normEnds[i] {
+		if isEnd {
+			return pm.origEnds[i]
+		}
+		return pm.origStarts[i]
+	}
+	return pos + pm.origEnds[i] - pm.normEnds[i]
+}
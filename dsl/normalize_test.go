@@ -0,0 +1,108 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizer(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		forms    Normalization
+		text     string
+		expected string
+		message  string
+	}{
+		{
+			forms:    CaseFolding,
+			text:     "Straße İSTANBUL",
+			expected: "strasse i̇stanbul",
+			message:  "case folding",
+		},
+		{
+			forms:    NFKC,
+			text:     "ﬁnal x²",
+			expected: "final x2",
+			message:  "compatibility composition",
+		},
+		{
+			forms:    DiacriticStripping,
+			text:     "café café Ação",
+			expected: "cafe cafe Acao",
+			message:  "diacritic stripping",
+		},
+		{
+			forms:    WidthFolding,
+			text:     "ＡＢＣ ｶ",
+			expected: "ABC カ",
+			message:  "width folding",
+		},
+		{
+			forms:    CaseFolding | NFKC | DiacriticStripping | WidthFolding,
+			text:     "Ｃafé İstanbul",
+			expected: "cafe istanbul",
+			message:  "all forms",
+		},
+		{
+			forms:    0,
+			text:     "Café",
+			expected: "Café",
+			message:  "no forms",
+		},
+	}
+
+	for _, tc := range tests {
+		normalizer := NewNormalizer(tc.forms)
+		assert.Equal(tc.expected, normalizer.NormalizeString(tc.text), tc.message)
+	}
+}
+
+func TestPositionMap(t *testing.T) {
+	assert := assert.New(t)
+	normalizer := NewNormalizer(CaseFolding | DiacriticStripping | WidthFolding)
+	text := "Ａ café Straße x"
+	normalized, positions := normalizer.Normalize(text)
+	assert.Equal("a cafe strasse x", normalized)
+
+	tests := []struct {
+		start        int
+		end          int
+		expectedText string
+		message      string
+	}{
+		{start: 0, end: 1, expectedText: "Ａ", message: "width folded rune"},
+		{start: 2, end: 6, expectedText: "café", message: "stripped diacritic"},
+		{start: 7, end: 14, expectedText: "Straße", message: "case folded rune"},
+		{start: 7, end: 12, expectedText: "Straß", message: "end inside a segment"},
+		{start: 12, end: 13, expectedText: "ß", message: "start inside a segment"},
+		{start: 15, end: 16, expectedText: "x", message: "after the segments"},
+	}
+
+	for _, tc := range tests {
+		start, end := positions.Original(tc.start, tc.end)
+		assert.Equal(tc.expectedText, text[start:end], tc.message)
+	}
+}
+
+func TestNormalizerStream(t *testing.T) {
+	assert := assert.New(t)
+	normalizer := NewNormalizer(CaseFolding | NFKC | DiacriticStripping)
+	text := "Café́ ﬁ Straße AÇÃO"
+	expected, expectedPositions := normalizer.Normalize(text)
+
+	runes := []rune(text)
+	for chunkSize := 1; chunkSize <= len(runes); chunkSize++ {
+		stream := normalizer.NewStream()
+		normalized := ""
+		for start := 0; start < len(runes); start += chunkSize {
+			end := start + chunkSize
+			if end > len(runes) {
+				end = len(runes)
+			}
+			normalized += stream.Next(string(runes[start:end]), end == len(runes))
+		}
+		assert.Equal(expected, normalized, chunkSize)
+		assert.Equal(expectedPositions, stream.Positions(), chunkSize)
+	}
+}
@@ -1,315 +1,786 @@
-package dsl
-
-import (
-	"fmt"
-	"io"
-	"strings"
-)
-
-// Parser parser struct that holds needed information to
-// parse the expression.
-type Parser struct {
-	s   *Scanner
-	buf struct {
-		tok       Token  // last read token
-		lit       string // last read literal
-		unscanned bool   // if it was unscanned
-	}
-	keywords     map[string]struct{}
-	regexes      map[string]struct{}
-	parCount     int
-	casesesitive bool
-	inord        bool
-	regex        bool
-}
-
-// NewParser returns a new instance of Parser.
-// If casesessitive is not set all terms are changed to lowercase
-func NewParser(r io.Reader, casesesitive bool) *Parser {
-	return &Parser{
-		s:            NewScanner(r),
-		keywords:     make(map[string]struct{}),
-		regexes:      make(map[string]struct{}),
-		parCount:     0,
-		casesesitive: casesesitive,
-	}
-}
-
-// GetKeywords returns the set of UNIT terms (Keywords) that where
-// found on the parser
-func (p *Parser) GetKeywords() map[string]struct{} {
-	return p.keywords
-}
-
-// GetRegexes returns the set of UNIT terms (Regex) that where
-// found on the parser
-func (p *Parser) GetRegexes() map[string]struct{} {
-	return p.regexes
-}
-
-// Parse parses the expression and returns the root node
-// of the parsed expression.
-func (p *Parser) Parse() (expr *Expression, err error) {
-	return p.parse()
-}
-
-// parse parses the expression and returns the root node
-// of the parsed expression.
-func (p *Parser) parse() (*Expression, error) {
-	exp := &Expression{Inord: p.inord}
-	for {
-		tok, lit, err := p.scanIgnoreWhitespace()
-		if err != nil {
-			return exp, err
-		}
-		switch tok {
-		case OPPAR:
-			newExp, err := p.handleOpenPar()
-			if err != nil {
-				return exp, err
-			}
-
-			if exp.LExpr == nil {
-				exp.LExpr = newExp
-			} else {
-				exp.RExpr = newExp
-			}
-
-		case KEYWORD, REGEX:
-			if !p.casesesitive {
-				lit = strings.ToLower(lit)
-			}
-			keyExp := &Expression{
-				Type:    UNIT_EXPR,
-				Literal: lit,
-				Inord:   p.inord,
-			}
-			if exp.LExpr == nil {
-				exp.LExpr = keyExp
-			} else {
-				exp.RExpr = keyExp
-			}
-
-			err = p.addLiteralToSet(tok, lit)
-			if err != nil {
-				return exp, err
-			}
-
-		case AND:
-			exp, err = p.handleDualOp(exp, AND_EXPR)
-			if err != nil {
-				return exp, err
-			}
-
-		case OR:
-			exp, err = p.handleDualOp(exp, OR_EXPR)
-			if err != nil {
-				return exp, err
-			}
-
-		case NOT:
-			if p.inord {
-				return exp, fmt.Errorf("invalid expression: INORD operator must not contain NOT operator")
-			}
-			nextTok, nextLit, err := p.scanIgnoreWhitespace()
-			if err != nil {
-				return exp, err
-			}
-
-			notExp := &Expression{
-				Type: NOT_EXPR,
-			}
-
-			switch nextTok {
-			case KEYWORD, REGEX:
-				if !p.casesesitive {
-					nextLit = strings.ToLower(nextLit)
-				}
-				notExp.RExpr = &Expression{
-					Type:    UNIT_EXPR,
-					Literal: nextLit,
-				}
-				err = p.addLiteralToSet(nextTok, nextLit)
-				if err != nil {
-					return exp, err
-				}
-
-			case OPPAR:
-				newExp, err := p.handleOpenPar()
-				if err != nil {
-					return exp, err
-				}
-				notExp.RExpr = newExp
-			default:
-				return exp, fmt.Errorf("invalid expression: Unexpected token '%s' after NOT", nextTok.getName())
-			}
-
-			if exp.LExpr == nil {
-				exp.LExpr = notExp
-			} else {
-				exp.RExpr = notExp
-			}
-
-		case INORD:
-			if p.inord {
-				return exp, fmt.Errorf("invalid expression: INORD operator must not contain INORD operator")
-			}
-
-			nextTok, _, err := p.scanIgnoreWhitespace()
-			if err != nil {
-				return exp, err
-			}
-
-			inordExp := &Expression{
-				Type: INORD_EXPR,
-			}
-
-			if nextTok != OPPAR {
-				return exp, fmt.Errorf("invalid expression: Unexpected token '%s' after INORD", nextTok.getName())
-			}
-
-			p.inord = true
-			newExp, err := p.handleOpenPar()
-			if err != nil {
-				return exp, err
-			}
-
-			p.inord = false
-			inordExp.RExpr = newExp
-
-			if exp.LExpr == nil {
-				exp.LExpr = inordExp
-			} else {
-				exp.RExpr = inordExp
-			}
-
-		case CLPAR:
-			p.parCount--
-			fallthrough
-		case EOF:
-			if p.parCount < 0 {
-				return exp, fmt.Errorf("invalid expression: unexpected EOF found. Extra closing parentheses: %d", p.parCount*-1)
-			}
-
-			finalExp := exp
-			if exp.Type == UNSET_EXPR {
-				if exp.RExpr != nil {
-					finalExp = exp.RExpr
-				} else if exp.LExpr != nil {
-					finalExp = exp.LExpr
-				} else {
-					return nil, fmt.Errorf("invalid expression: unexpected EOF found")
-				}
-			}
-			switch finalExp.Type {
-			case AND_EXPR, OR_EXPR:
-				if finalExp.RExpr == nil {
-					return nil, fmt.Errorf("invalid expression: incomplete expression %s", finalExp.Type.GetName())
-				}
-			}
-			return finalExp, nil
-
-		default:
-			return exp, fmt.Errorf("invalid expression: Unexpected operator was found (%d = '%s')", tok, lit)
-		}
-	}
-}
-
-// handleDualOp adds the needed information to the current expression and returns the next
-// expression, that can be the same or another expression.
-func (p *Parser) handleDualOp(exp *Expression, expType ExprType) (*Expression, error) {
-	if exp.LExpr == nil {
-		return exp, fmt.Errorf("invalid expression: no left expression was found for %s", expType.GetName())
-	}
-	if exp.RExpr == nil {
-		exp.Type = expType
-		return exp, nil
-	}
-
-	exp = &Expression{
-		Type:  expType,
-		LExpr: exp,
-		Inord: p.inord,
-	}
-
-	nextTok, _, err := p.scanIgnoreWhitespace()
-	if err != nil {
-		return exp, err
-	}
-
-	if nextTok == OPPAR {
-		newExp, err := p.handleOpenPar()
-		if err != nil {
-			return exp, err
-		}
-		exp.RExpr = newExp
-	} else {
-		p.unscan()
-	}
-
-	return exp, nil
-}
-
-// scan scans the next token and stores it on a buffer to
-// make unscanning on token possible
-func (p *Parser) scan() (tok Token, lit string, err error) {
-	// If we have a token on the buffer, then return it.
-	if p.buf.unscanned {
-		p.buf.unscanned = false
-		return p.buf.tok, p.buf.lit, nil
-	}
-
-	// Otherwise read the next token from the scanner.
-	tok, lit, err = p.s.Scan()
-	if err != nil {
-		return
-	}
-
-	// Save it to the buffer in case we unscan later.
-	p.buf.tok, p.buf.lit = tok, lit
-
-	return
-}
-
-// unscan sets the unscanned flag to assign the scan to
-// use the buffered information.
-func (p *Parser) unscan() { p.buf.unscanned = true }
-
-// scanIgnoreWhitespace scans the next non-whitespace token.
-func (p *Parser) scanIgnoreWhitespace() (tok Token, lit string, err error) {
-	tok, lit, err = p.scan()
-	if err != nil {
-		return
-	}
-	if tok == WS {
-		tok, lit, err = p.scan()
-	}
-	return
-}
-
-// handleOpenPar gets the expression that is inside the parentheses
-func (p *Parser) handleOpenPar() (*Expression, error) {
-	parlvl := p.parCount
-	p.parCount++
-	newExp, err := p.parse()
-	if err != nil {
-		return newExp, err
-	}
-	if p.parCount != parlvl {
-		return newExp, fmt.Errorf("invalid expression: Unexpected '('")
-	}
-	return newExp, nil
-}
-
-// addLiteralToMap adds the literal to the correct set of terms
-func (p *Parser) addLiteralToSet(tok Token, lit string) error {
-	switch tok {
-	case REGEX:
-		p.regexes[lit] = struct{}{}
-	case KEYWORD:
-		p.keywords[lit] = struct{}{}
-	default:
-		return fmt.Errorf("expected REGEX or KEYWORD tokens type to add literal to set but received: %s", tok.getName())
-	}
-	return nil
-}
+package dsl
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Parser parser struct that holds needed information to
+// parse the expression.
+type Parser struct {
+	s   *Scanner
+	buf struct {
+		tok       Token  // last read token
+		lit       string // last read literal
+		unscanned bool   // if it was unscanned
+	}
+	keywords     map[string]struct{}
+	regexes      map[string]struct{}
+	regexTerms   map[string]RegexTerm
+	wordKeywords map[string]struct{}
+	wildcards    map[string]struct{}
+	fuzzyTerms   map[string]FuzzyTerm
+	caseKeywords map[string]string
+	parCount     int
+	casesesitive bool
+	normalizer   *Normalizer
+	inord        bool
+	regex        bool
+}
+
+// NewParser returns a new instance of Parser.
+// If casesessitive is not set all terms are changed to lowercase,
+// except for the regexes that are kept as they were written.
+func NewParser(r io.Reader, casesesitive bool) *Parser {
+	return &Parser{
+		s:            NewScanner(r),
+		keywords:     make(map[string]struct{}),
+		regexes:      make(map[string]struct{}),
+		regexTerms:   make(map[string]RegexTerm),
+		wordKeywords: make(map[string]struct{}),
+		wildcards:    make(map[string]struct{}),
+		fuzzyTerms:   make(map[string]FuzzyTerm),
+		caseKeywords: make(map[string]string),
+		parCount:     0,
+		casesesitive: casesesitive,
+	}
+}
+
+// SetNormalizer sets the normalizer that is applied to the terms, except for the
+// regexes, so they are found on the texts normalized by the same normalizer.
+// It must be set before the expression is parsed.
+func (p *Parser) SetNormalizer(normalizer *Normalizer) {
+	p.normalizer = normalizer
+}
+
+// GetKeywords returns the set of UNIT terms (Keywords) that where
+// found on the parser
+func (p *Parser) GetKeywords() map[string]struct{} {
+	return p.keywords
+}
+
+// GetRegexes returns the set of UNIT terms (Regex) that where
+// found on the parser by their keys (see RegexTerm.Key)
+func (p *Parser) GetRegexes() map[string]struct{} {
+	return p.regexes
+}
+
+// GetRegexTerms returns the regex terms that where found on the
+// parser with their flags by their keys (see RegexTerm.Key)
+func (p *Parser) GetRegexTerms() map[string]RegexTerm {
+	return p.regexTerms
+}
+
+// GetWordKeywords returns the set of keywords of the whole word terms (W"term")
+// that where found on the parser. These keywords are also returned by GetKeywords,
+// since they are searched as keywords and filtered later.
+func (p *Parser) GetWordKeywords() map[string]struct{} {
+	return p.wordKeywords
+}
+
+// GetWildcards returns the set of patterns of the wildcard terms that where found
+// on the parser. The anchors of the patterns (see Wildcard) are returned by GetKeywords.
+func (p *Parser) GetWildcards() map[string]struct{} {
+	return p.wildcards
+}
+
+// GetFuzzyTerms returns the fuzzy terms that where found on the parser
+// by their keys (see FuzzyTerm.Key).
+func (p *Parser) GetFuzzyTerms() map[string]FuzzyTerm {
+	return p.fuzzyTerms
+}
+
+// GetCaseKeywords returns the case-sensitive terms (C"term") that where found on the parser
+// mapped to the keywords that are searched for them, that are also returned by GetKeywords.
+// If the parser is not case-sensitive the keywords are in lowercase and the matches of
+// the keywords must be compared with the case-sensitive terms.
+func (p *Parser) GetCaseKeywords() map[string]string {
+	return p.caseKeywords
+}
+
+// Parse parses the expression and returns the root node
+// of the parsed expression.
+func (p *Parser) Parse() (expr *Expression, err error) {
+	return p.parse()
+}
+
+// parse parses the expression and returns the root node
+// of the parsed expression.
+func (p *Parser) parse() (*Expression, error) {
+	exp := &Expression{Inord: p.inord}
+	for {
+		tok, lit, err := p.scanIgnoreWhitespace()
+		if err != nil {
+			return exp, err
+		}
+		switch tok {
+		case OPPAR:
+			newExp, err := p.handleOpenPar()
+			if err != nil {
+				return exp, err
+			}
+
+			if err := addOperand(exp, newExp); err != nil {
+				return exp, err
+			}
+
+		case KEYWORD, REGEX, WORD, WILDCARD, FUZZY, CASE:
+			keyExp, err := p.newTermExpr(tok, lit)
+			if err != nil {
+				return exp, err
+			}
+			if err := addOperand(exp, keyExp); err != nil {
+				return exp, err
+			}
+
+		case AND:
+			exp, err = p.handleDualOp(exp, AND_EXPR)
+			if err != nil {
+				return exp, err
+			}
+
+		case OR:
+			exp, err = p.handleDualOp(exp, OR_EXPR)
+			if err != nil {
+				return exp, err
+			}
+
+		case NOT:
+			nextTok, nextLit, err := p.scanIgnoreWhitespace()
+			if err != nil {
+				return exp, err
+			}
+
+			notExp := &Expression{
+				Type:  NOT_EXPR,
+				Inord: p.inord,
+			}
+
+			switch nextTok {
+			case KEYWORD, REGEX, WORD, WILDCARD, FUZZY, CASE:
+				notExp.RExpr, err = p.newTermExpr(nextTok, nextLit)
+				if err != nil {
+					return exp, err
+				}
+
+			case OPPAR:
+				newExp, err := p.handleOpenPar()
+				if err != nil {
+					return exp, err
+				}
+				notExp.RExpr = newExp
+			default:
+				return exp, fmt.Errorf("invalid expression: Unexpected token '%s' after NOT", nextTok.getName())
+			}
+
+			if err := addOperand(exp, notExp); err != nil {
+				return exp, err
+			}
+
+		case INORD:
+			inordExp := &Expression{
+				Type:  INORD_EXPR,
+				Inord: p.inord,
+			}
+			if strings.Contains(lit, "/") {
+				inordExp.Distance, inordExp.DistanceUnit, err = parseDistance(lit)
+				if err != nil {
+					return exp, err
+				}
+				if inordExp.Distance <= 0 {
+					return exp, fmt.Errorf("invalid expression: the gap of INORD must be greater than 0 on '%s'", lit)
+				}
+			}
+
+			nextTok, _, err := p.scanIgnoreWhitespace()
+			if err != nil {
+				return exp, err
+			}
+
+			if nextTok != OPPAR {
+				return exp, fmt.Errorf("invalid expression: Unexpected token '%s' after INORD", nextTok.getName())
+			}
+
+			outerInord := p.inord
+			p.inord = true
+			newExp, err := p.handleOpenPar()
+			if err != nil {
+				return exp, err
+			}
+
+			p.inord = outerInord
+			if err := checkInordPattern(newExp); err != nil {
+				return exp, err
+			}
+			inordExp.RExpr = newExp
+
+			if err := addOperand(exp, inordExp); err != nil {
+				return exp, err
+			}
+
+		case ATLEAST, EXACTLY, ATMOST:
+			thresholdExp, err := p.handleThresholdOp(tok)
+			if err != nil {
+				return exp, err
+			}
+
+			if err := addOperand(exp, thresholdExp); err != nil {
+				return exp, err
+			}
+
+		case COUNT:
+			countExp, err := p.handleCountOp()
+			if err != nil {
+				return exp, err
+			}
+
+			if err := addOperand(exp, countExp); err != nil {
+				return exp, err
+			}
+
+		case NEAR, ONEAR:
+			exp, err = p.handleNearOp(exp, tok, lit)
+			if err != nil {
+				return exp, err
+			}
+
+		case CLPAR:
+			p.parCount--
+			fallthrough
+		case EOF:
+			if p.parCount < 0 {
+				return exp, fmt.Errorf("invalid expression: unexpected EOF found. Extra closing parentheses: %d", p.parCount*-1)
+			}
+
+			finalExp := exp
+			if exp.Type == UNSET_EXPR {
+				if exp.RExpr != nil {
+					finalExp = exp.RExpr
+				} else if exp.LExpr != nil {
+					finalExp = exp.LExpr
+				} else {
+					return nil, fmt.Errorf("invalid expression: unexpected EOF found")
+				}
+			}
+			switch finalExp.Type {
+			case AND_EXPR, OR_EXPR:
+				if finalExp.RExpr == nil {
+					return nil, fmt.Errorf("invalid expression: incomplete expression %s", finalExp.Type.GetName())
+				}
+			}
+			return finalExp, nil
+
+		default:
+			return exp, fmt.Errorf("invalid expression: Unexpected operator was found (%d = '%s')", tok, lit)
+		}
+	}
+}
+
+// addOperand sets the operand as the next operand of the expression. Returns an error if
+// the expression already has its operands, since the operands must be joined by an operator.
+func addOperand(exp *Expression, operand *Expression) error {
+	switch {
+	case exp.LExpr == nil:
+		exp.LExpr = operand
+	case exp.RExpr == nil && exp.Type != UNSET_EXPR:
+		exp.RExpr = operand
+	default:
+		return fmt.Errorf("invalid expression: expected an operator before '%s'", operand)
+	}
+	return nil
+}
+
+// handleDualOp adds the needed information to the current expression and returns the next
+// expression, that can be the same or another expression.
+func (p *Parser) handleDualOp(exp *Expression, expType ExprType) (*Expression, error) {
+	if exp.LExpr == nil {
+		return exp, fmt.Errorf("invalid expression: no left expression was found for %s", expType.GetName())
+	}
+	if exp.RExpr == nil {
+		exp.Type = expType
+		return exp, nil
+	}
+
+	exp = &Expression{
+		Type:  expType,
+		LExpr: exp,
+		Inord: p.inord,
+	}
+
+	nextTok, _, err := p.scanIgnoreWhitespace()
+	if err != nil {
+		return exp, err
+	}
+
+	if nextTok == OPPAR {
+		newExp, err := p.handleOpenPar()
+		if err != nil {
+			return exp, err
+		}
+		exp.RExpr = newExp
+	} else {
+		p.unscan()
+	}
+
+	return exp, nil
+}
+
+// handleThresholdOp parses the threshold and the list of terms of the ATLEAST, EXACTLY
+// and ATMOST operators. Eg: ATLEAST(2, "a", r"b", "c")
+func (p *Parser) handleThresholdOp(tok Token) (*Expression, error) {
+	if p.inord {
+		return nil, fmt.Errorf("invalid expression: INORD operator must not contain %s operator", tok.getName())
+	}
+
+	thresholdExp := &Expression{}
+	switch tok {
+	case ATLEAST:
+		thresholdExp.Type = ATLEAST_EXPR
+	case EXACTLY:
+		thresholdExp.Type = EXACTLY_EXPR
+	default:
+		thresholdExp.Type = ATMOST_EXPR
+	}
+
+	nextTok, _, err := p.scanIgnoreWhitespace()
+	if err != nil {
+		return nil, err
+	}
+	if nextTok != OPPAR {
+		return nil, fmt.Errorf("invalid expression: Unexpected token '%s' after %s", nextTok.getName(), tok.getName())
+	}
+
+	nextTok, nextLit, err := p.scanIgnoreWhitespace()
+	if err != nil {
+		return nil, err
+	}
+	if nextTok != NUMBER {
+		return nil, fmt.Errorf("invalid expression: expected the threshold of %s but found '%s'", tok.getName(), nextTok.getName())
+	}
+	thresholdExp.Threshold, err = strconv.Atoi(nextLit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: invalid threshold '%s' on %s: %v", nextLit, tok.getName(), err)
+	}
+
+	literals := make(map[string]struct{})
+	for {
+		nextTok, _, err = p.scanIgnoreWhitespace()
+		if err != nil {
+			return nil, err
+		}
+		if nextTok == CLPAR {
+			break
+		}
+		if nextTok != COMMA {
+			return nil, fmt.Errorf("invalid expression: Unexpected token '%s' on %s", nextTok.getName(), tok.getName())
+		}
+
+		nextTok, nextLit, err = p.scanIgnoreWhitespace()
+		if err != nil {
+			return nil, err
+		}
+		if !isTerm(nextTok) {
+			return nil, fmt.Errorf("invalid expression: %s operands must be terms but found '%s'", tok.getName(), nextTok.getName())
+		}
+		termExp, err := p.newTermExpr(nextTok, nextLit)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := literals[termExp.Literal]; ok {
+			return nil, fmt.Errorf("invalid expression: duplicated term '%s' on %s", termExp.Literal, tok.getName())
+		}
+		literals[termExp.Literal] = struct{}{}
+		thresholdExp.Operands = append(thresholdExp.Operands, termExp)
+	}
+
+	if len(thresholdExp.Operands) == 0 {
+		return nil, fmt.Errorf("invalid expression: %s must have at least one term", tok.getName())
+	}
+	if tok != ATMOST && thresholdExp.Threshold > len(thresholdExp.Operands) {
+		return nil, fmt.Errorf(
+			"invalid expression: %s threshold %d is greater than the number of terms %d",
+			tok.getName(),
+			thresholdExp.Threshold,
+			len(thresholdExp.Operands),
+		)
+	}
+	return thresholdExp, nil
+}
+
+// handleCountOp parses the term and the comparison of the COUNT operator. Eg: COUNT("a") >= 3
+func (p *Parser) handleCountOp() (*Expression, error) {
+	if p.inord {
+		return nil, fmt.Errorf("invalid expression: INORD operator must not contain COUNT operator")
+	}
+
+	nextTok, _, err := p.scanIgnoreWhitespace()
+	if err != nil {
+		return nil, err
+	}
+	if nextTok != OPPAR {
+		return nil, fmt.Errorf("invalid expression: Unexpected token '%s' after COUNT", nextTok.getName())
+	}
+
+	nextTok, nextLit, err := p.scanIgnoreWhitespace()
+	if err != nil {
+		return nil, err
+	}
+	if !isTerm(nextTok) {
+		return nil, fmt.Errorf("invalid expression: COUNT operand must be a term but found '%s'", nextTok.getName())
+	}
+	termExp, err := p.newTermExpr(nextTok, nextLit)
+	if err != nil {
+		return nil, err
+	}
+	countExp := &Expression{
+		Type:  COUNT_EXPR,
+		RExpr: termExp,
+	}
+
+	nextTok, _, err = p.scanIgnoreWhitespace()
+	if err != nil {
+		return nil, err
+	}
+	if nextTok != CLPAR {
+		return nil, fmt.Errorf("invalid expression: Unexpected token '%s' on COUNT", nextTok.getName())
+	}
+
+	nextTok, nextLit, err = p.scanIgnoreWhitespace()
+	if err != nil {
+		return nil, err
+	}
+	switch nextTok {
+	case GT, GTE, LT, LTE, EQ, NEQ:
+		countExp.Comparator = nextLit
+	default:
+		return nil, fmt.Errorf("invalid expression: expected a comparison operator after COUNT but found '%s'", nextTok.getName())
+	}
+
+	nextTok, nextLit, err = p.scanIgnoreWhitespace()
+	if err != nil {
+		return nil, err
+	}
+	if nextTok != NUMBER {
+		return nil, fmt.Errorf("invalid expression: expected a number after COUNT comparison but found '%s'", nextTok.getName())
+	}
+	countExp.Threshold, err = strconv.Atoi(nextLit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: invalid number '%s' on COUNT: %v", nextLit, err)
+	}
+	return countExp, nil
+}
+
+// handleNearOp creates the NEAR or ONEAR expression using the last expression found as the left
+// operand and the next term or expression enclosed by parentheses as the right operand.
+// The NEAR operators have precedence over AND and OR and its operands must be terms or terms
+// joined by OR.
+func (p *Parser) handleNearOp(exp *Expression, tok Token, lit string) (*Expression, error) {
+	if p.inord {
+		return exp, fmt.Errorf("invalid expression: INORD operator must not contain %s operator", tok.getName())
+	}
+
+	var left *Expression
+	switch {
+	case exp.RExpr != nil:
+		left = exp.RExpr
+	case exp.LExpr != nil && exp.Type == UNSET_EXPR:
+		left = exp.LExpr
+	default:
+		return exp, fmt.Errorf("invalid expression: no left expression was found for %s", tok.getName())
+	}
+
+	distance, unit, err := parseDistance(lit)
+	if err != nil {
+		return exp, err
+	}
+
+	var right *Expression
+	nextTok, nextLit, err := p.scanIgnoreWhitespace()
+	if err != nil {
+		return exp, err
+	}
+	switch nextTok {
+	case KEYWORD, REGEX, WORD, WILDCARD, FUZZY, CASE:
+		right, err = p.newTermExpr(nextTok, nextLit)
+		if err != nil {
+			return exp, err
+		}
+	case OPPAR:
+		right, err = p.handleOpenPar()
+		if err != nil {
+			return exp, err
+		}
+	default:
+		return exp, fmt.Errorf("invalid expression: Unexpected token '%s' after %s", nextTok.getName(), tok.getName())
+	}
+
+	if !isTermGroup(left) || !isTermGroup(right) {
+		return exp, fmt.Errorf("invalid expression: %s operands must be terms or terms joined by OR", tok.getName())
+	}
+
+	nearExp := &Expression{
+		Type:         NEAR_EXPR,
+		LExpr:        left,
+		RExpr:        right,
+		Distance:     distance,
+		DistanceUnit: unit,
+	}
+	if tok == ONEAR {
+		nearExp.Type = ONEAR_EXPR
+	}
+
+	if exp.RExpr != nil {
+		exp.RExpr = nearExp
+	} else {
+		exp.LExpr = nearExp
+	}
+	return exp, nil
+}
+
+// parseDistance extracts the distance and its unit from a NEAR operator literal. Eg: "NEAR/5c"
+func parseDistance(lit string) (distance int, unit DistanceUnit, err error) {
+	idx := strings.Index(lit, "/")
+	if idx < 0 {
+		return 0, WORD_DISTANCE, fmt.Errorf("invalid expression: no distance was found on '%s'", lit)
+	}
+	value := strings.ToLower(lit[idx+1:])
+	unit = WORD_DISTANCE
+	switch {
+	case strings.HasSuffix(value, "c"):
+		unit = CHAR_DISTANCE
+		value = strings.TrimSuffix(value, "c")
+	case strings.HasSuffix(value, "w"):
+		value = strings.TrimSuffix(value, "w")
+	}
+	distance, err = strconv.Atoi(value)
+	if err != nil {
+		return 0, WORD_DISTANCE, fmt.Errorf("invalid expression: invalid distance on '%s': %v", lit, err)
+	}
+	return distance, unit, nil
+}
+
+// isTermGroup returns true if the expression is a term or terms joined by OR
+func isTermGroup(exp *Expression) bool {
+	switch exp.Type {
+	case UNIT_EXPR:
+		return true
+	case OR_EXPR:
+		return exp.LExpr != nil && exp.RExpr != nil && isTermGroup(exp.LExpr) && isTermGroup(exp.RExpr)
+	default:
+		return false
+	}
+}
+
+// scan scans the next token and stores it on a buffer to
+// make unscanning on token possible
+func (p *Parser) scan() (tok Token, lit string, err error) {
+	// If we have a token on the buffer, then return it.
+	if p.buf.unscanned {
+		p.buf.unscanned = false
+		return p.buf.tok, p.buf.lit, nil
+	}
+
+	// Otherwise read the next token from the scanner.
+	tok, lit, err = p.s.Scan()
+	if err != nil {
+		return
+	}
+
+	// Save it to the buffer in case we unscan later.
+	p.buf.tok, p.buf.lit = tok, lit
+
+	return
+}
+
+// unscan sets the unscanned flag to assign the scan to
+// use the buffered information.
+func (p *Parser) unscan() { p.buf.unscanned = true }
+
+// scanIgnoreWhitespace scans the next non-whitespace token.
+func (p *Parser) scanIgnoreWhitespace() (tok Token, lit string, err error) {
+	tok, lit, err = p.scan()
+	if err != nil {
+		return
+	}
+	if tok == WS {
+		tok, lit, err = p.scan()
+	}
+	return
+}
+
+// handleOpenPar gets the expression that is inside the parentheses
+func (p *Parser) handleOpenPar() (*Expression, error) {
+	parlvl := p.parCount
+	p.parCount++
+	newExp, err := p.parse()
+	if err != nil {
+		return newExp, err
+	}
+	if p.parCount != parlvl {
+		return newExp, fmt.Errorf("invalid expression: Unexpected '('")
+	}
+	return newExp, nil
+}
+
+// newTermExpr returns the UNIT expression of the term and adds its literal to the
+// correct set of terms. The Literal of a whole word term is its WordTermKey, of a
+// wildcard term is its WildcardTermKey, of a fuzzy term is its FuzzyTerm.Key and
+// of a case-sensitive term is its CaseTermKey and of a regex is its RegexTerm.Key.
+// The TermType of the expression keeps the kind of the term.
+func (p *Parser) newTermExpr(tok Token, lit string) (*Expression, error) {
+	switch tok {
+	case CASE:
+		return p.newCaseTermExpr(lit), nil
+	case REGEX:
+		return p.newRegexTermExpr(lit)
+	}
+	if !p.casesesitive {
+		lit = strings.ToLower(lit)
+	}
+	if p.normalizer != nil && tok != FUZZY {
+		lit = p.normalizer.NormalizeString(lit)
+	}
+	if tok == FUZZY {
+		ft, err := parseFuzzyLiteral(lit)
+		if err == nil && p.normalizer != nil {
+			ft, err = NewFuzzyTerm(p.normalizer.NormalizeString(ft.Term), ft.Distance)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression: %v", err)
+		}
+		p.fuzzyTerms[ft.Key()] = ft
+		return &Expression{
+			Type:     UNIT_EXPR,
+			Literal:  ft.Key(),
+			TermType: FUZZY_TERM,
+			Inord:    p.inord,
+		}, nil
+	}
+
+	err := p.addLiteralToSet(tok, lit)
+	if err != nil {
+		return nil, err
+	}
+
+	termType := KEYWORD_TERM
+	switch tok {
+	case WORD:
+		lit = WordTermKey(lit)
+		termType = WORD_TERM
+	case WILDCARD:
+		lit = WildcardTermKey(lit)
+		termType = WILDCARD_TERM
+	}
+	return &Expression{
+		Type:     UNIT_EXPR,
+		Literal:  lit,
+		TermType: termType,
+		Inord:    p.inord,
+	}, nil
+}
+
+// newCaseTermExpr returns the UNIT expression of the case-sensitive term, that keeps its
+// case even if the parser is not case-sensitive, and adds its keyword to the set of keywords.
+// The normalizer is applied to the term without the case folding.
+func (p *Parser) newCaseTermExpr(lit string) *Expression {
+	keyword := lit
+	if !p.casesesitive {
+		keyword = strings.ToLower(keyword)
+	}
+	if p.normalizer != nil {
+		keyword = p.normalizer.NormalizeString(keyword)
+		lit = NewNormalizer(p.normalizer.Forms() &^ CaseFolding).NormalizeString(lit)
+	}
+	p.keywords[keyword] = struct{}{}
+	p.caseKeywords[lit] = keyword
+	return &Expression{
+		Type:     UNIT_EXPR,
+		Literal:  CaseTermKey(lit),
+		TermType: CASE_TERM,
+		Inord:    p.inord,
+	}
+}
+
+// newRegexTermExpr returns the UNIT expression of the regex and adds it to the set of
+// regexes. The FLAGS that follow the regex are parsed as its flags. The pattern is not
+// changed to lowercase nor normalized, since that would change its meaning.
+func (p *Parser) newRegexTermExpr(pattern string) (*Expression, error) {
+	rt := RegexTerm{Pattern: pattern}
+	tok, lit, err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	if tok == FLAGS {
+		rt.Flags, err = ParseRegexFlags(lit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression: %v", err)
+		}
+	} else {
+		p.unscan()
+	}
+
+	p.regexes[rt.Key()] = struct{}{}
+	p.regexTerms[rt.Key()] = rt
+	return &Expression{
+		Type:     UNIT_EXPR,
+		Literal:  rt.Key(),
+		TermType: REGEX_TERM,
+		Inord:    p.inord,
+	}, nil
+}
+
+// addLiteralToMap adds the literal to the correct set of terms
+func (p *Parser) addLiteralToSet(tok Token, lit string) error {
+	switch tok {
+	case KEYWORD:
+		p.keywords[lit] = struct{}{}
+	case WORD:
+		p.keywords[lit] = struct{}{}
+		p.wordKeywords[lit] = struct{}{}
+	case WILDCARD:
+		wc, err := NewWildcard(lit)
+		if err != nil {
+			return fmt.Errorf("invalid expression: %v", err)
+		}
+		p.keywords[wc.Anchor()] = struct{}{}
+		p.wildcards[lit] = struct{}{}
+	default:
+		return fmt.Errorf("expected KEYWORD, WORD or WILDCARD tokens type to add literal to set but received: %s", tok.getName())
+	}
+	return nil
+}
+
+// isTerm returns true if the token is a term
+func isTerm(tok Token) bool {
+	return tok == KEYWORD || tok == REGEX || tok == WORD || tok == WILDCARD || tok == FUZZY || tok == CASE
+}
+
+// termKeyMark starts the keys of the whole word, case-sensitive, wildcard and fuzzy terms.
+// The scanner reads the rune 0 as the end of the expression, so the terms never contain it
+// and their keys never collide with the keywords and the regexes. Eg: the key of W"cat" is
+// not the key of the keyword "w\"cat\"" nor of the regex R"w\"cat\"".
+const termKeyMark = "\x00"
+
+// WordTermKey returns the key of the whole word term on the map of matches
+// used to solve the expressions, that is w"term" after the termKeyMark.
+// The prefix is lowercase so the key is kept when the terms are changed to lowercase.
+func WordTermKey(literal string) string {
+	return termKeyMark + `w"` + literal + `"`
+}
+
+// CaseTermKey returns the key of the case-sensitive term on the map of matches
+// used to solve the expressions, that is c"US" after the termKeyMark.
+func CaseTermKey(literal string) string {
+	return termKeyMark + `c"` + literal + `"`
+}
+
+// CaseTermOfKey returns the term of the CaseTermKey, or false if the key is not a CaseTermKey.
+func CaseTermOfKey(key string) (string, bool) {
+	prefix := termKeyMark + `c"`
+	if len(key) < len(prefix)+1 || !strings.HasPrefix(key, prefix) || key[len(key)-1] != '"' {
+		return "", false
+	}
+	return key[len(prefix) : len(key)-1], true
+}
@@ -50,8 +50,9 @@ func TestParser(t *testing.T) {
 		{
 			expStr: `(r"1")`,
 			expectedExp: Expression{
-				Type:    UNIT_EXPR,
-				Literal: "1",
+				Type:     UNIT_EXPR,
+				Literal:  "1",
+				TermType: REGEX_TERM,
 			},
 			expectedKeywords: map[string]struct{}{},
 			expectedRegexes: map[string]struct{}{
@@ -70,8 +71,9 @@ func TestParser(t *testing.T) {
 					Literal: "1",
 				},
 				RExpr: &Expression{
-					Type:    UNIT_EXPR,
-					Literal: "2",
+					Type:     UNIT_EXPR,
+					Literal:  "2",
+					TermType: REGEX_TERM,
 				},
 			},
 			expectedKeywords: map[string]struct{}{
@@ -150,8 +152,9 @@ func TestParser(t *testing.T) {
 			expectedExp: Expression{
 				Type: NOT_EXPR,
 				RExpr: &Expression{
-					Type:    UNIT_EXPR,
-					Literal: "1",
+					Type:     UNIT_EXPR,
+					Literal:  "1",
+					TermType: REGEX_TERM,
 				},
 			},
 			expectedKeywords: map[string]struct{}{},
@@ -237,8 +240,9 @@ func TestParser(t *testing.T) {
 					RExpr: &Expression{
 						Type: OR_EXPR,
 						LExpr: &Expression{
-							Type:    UNIT_EXPR,
-							Literal: "2",
+							Type:     UNIT_EXPR,
+							Literal:  "2",
+							TermType: REGEX_TERM,
 						},
 						RExpr: &Expression{
 							Type:    UNIT_EXPR,
@@ -339,8 +343,9 @@ func TestParser(t *testing.T) {
 			expectedExp: Expression{
 				Type: AND_EXPR,
 				LExpr: &Expression{
-					Type:    UNIT_EXPR,
-					Literal: "case in sensitive",
+					Type:     UNIT_EXPR,
+					Literal:  "CaSe In sensItIVe",
+					TermType: REGEX_TERM,
 				},
 				RExpr: &Expression{
 					Type:    UNIT_EXPR,
@@ -351,7 +356,7 @@ func TestParser(t *testing.T) {
 				"something": {},
 			},
 			expectedRegexes: map[string]struct{}{
-				"case in sensitive": {},
+				"CaSe In sensItIVe": {},
 			},
 			expectedErr: nil,
 			caseSense:   false,
@@ -367,9 +372,10 @@ func TestParser(t *testing.T) {
 						Type:  OR_EXPR,
 						Inord: true,
 						LExpr: &Expression{
-							Type:    UNIT_EXPR,
-							Inord:   true,
-							Literal: "2",
+							Type:     UNIT_EXPR,
+							Inord:    true,
+							Literal:  "2",
+							TermType: REGEX_TERM,
 						},
 						RExpr: &Expression{
 							Type:    UNIT_EXPR,
@@ -399,18 +405,103 @@ func TestParser(t *testing.T) {
 			expectedExp:      Expression{},
 			expectedKeywords: map[string]struct{}{},
 			expectedRegexes:  map[string]struct{}{},
-			expectedErr:      fmt.Errorf("invalid expression: INORD operator must not contain NOT operator"),
+			expectedErr:      fmt.Errorf("invalid expression: NOT operator inside INORD must be an operand of AND"),
 			caseSense:        true,
-			message:          "inord operator fail not",
+			message:          "inord operator fail not on or",
 		},
 		{
 			expStr:           `"1" and INORD( inord("2" or not "3") )`,
 			expectedExp:      Expression{},
 			expectedKeywords: map[string]struct{}{},
 			expectedRegexes:  map[string]struct{}{},
-			expectedErr:      fmt.Errorf("invalid expression: INORD operator must not contain INORD operator"),
+			expectedErr:      fmt.Errorf("invalid expression: NOT operator inside INORD must be an operand of AND"),
+			caseSense:        true,
+			message:          "inord operator fail not on or of a nested inord",
+		},
+		{
+			expStr: `INORD/3("1" and not "2" and inord("3" and "4"))`,
+			expectedExp: Expression{
+				Type:         INORD_EXPR,
+				Distance:     3,
+				DistanceUnit: WORD_DISTANCE,
+				RExpr: &Expression{
+					Type:  AND_EXPR,
+					Inord: true,
+					LExpr: &Expression{
+						Type:  AND_EXPR,
+						Inord: true,
+						LExpr: &Expression{
+							Type:    UNIT_EXPR,
+							Inord:   true,
+							Literal: "1",
+						},
+						RExpr: &Expression{
+							Type:  NOT_EXPR,
+							Inord: true,
+							RExpr: &Expression{
+								Type:    UNIT_EXPR,
+								Inord:   true,
+								Literal: "2",
+							},
+						},
+					},
+					RExpr: &Expression{
+						Type:  INORD_EXPR,
+						Inord: true,
+						RExpr: &Expression{
+							Type:  AND_EXPR,
+							Inord: true,
+							LExpr: &Expression{
+								Type:    UNIT_EXPR,
+								Inord:   true,
+								Literal: "3",
+							},
+							RExpr: &Expression{
+								Type:    UNIT_EXPR,
+								Inord:   true,
+								Literal: "4",
+							},
+						},
+					},
+				},
+			},
+			expectedKeywords: map[string]struct{}{
+				"1": {},
+				"2": {},
+				"3": {},
+				"4": {},
+			},
+			expectedRegexes: map[string]struct{}{},
+			expectedErr:     nil,
+			caseSense:       true,
+			message:         "inord operator with gap, not and nested inord",
+		},
+		{
+			expStr:           `INORD(not "1" and not "2")`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedRegexes:  map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: INORD sequence must have an operand that is not negated"),
 			caseSense:        true,
-			message:          "inord operator fail inord on a inord",
+			message:          "inord operator fail only negated operands",
+		},
+		{
+			expStr:           `INORD("1" and not ("2" and "3"))`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedRegexes:  map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: NOT operand inside INORD must be a term or terms joined by OR"),
+			caseSense:        true,
+			message:          "inord operator fail not of a sequence",
+		},
+		{
+			expStr:           `INORD/0("1" and "2")`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedRegexes:  map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: the gap of INORD must be greater than 0 on 'INORD/0'"),
+			caseSense:        true,
+			message:          "inord operator fail zero gap",
 		},
 		{
 			expStr:           `"1" and INORD "2" or not "3"`,
@@ -420,6 +511,309 @@ func TestParser(t *testing.T) {
 			caseSense:        true,
 			message:          "inord operator fail inord without parentheses",
 		},
+		{
+			expStr: `"a" and "b" NEAR/5 "c"`,
+			expectedExp: Expression{
+				Type: AND_EXPR,
+				LExpr: &Expression{
+					Type:    UNIT_EXPR,
+					Literal: "a",
+				},
+				RExpr: &Expression{
+					Type:     NEAR_EXPR,
+					Distance: 5,
+					LExpr: &Expression{
+						Type:    UNIT_EXPR,
+						Literal: "b",
+					},
+					RExpr: &Expression{
+						Type:    UNIT_EXPR,
+						Literal: "c",
+					},
+				},
+			},
+			expectedKeywords: map[string]struct{}{
+				"a": {},
+				"b": {},
+				"c": {},
+			},
+			expectedRegexes: map[string]struct{}{},
+			expectedErr:     nil,
+			caseSense:       true,
+			message:         "near operator precedence",
+		},
+		{
+			expStr: `("A" or r"B") onear/10c "C" or "d"`,
+			expectedExp: Expression{
+				Type: OR_EXPR,
+				LExpr: &Expression{
+					Type:         ONEAR_EXPR,
+					Distance:     10,
+					DistanceUnit: CHAR_DISTANCE,
+					LExpr: &Expression{
+						Type: OR_EXPR,
+						LExpr: &Expression{
+							Type:    UNIT_EXPR,
+							Literal: "a",
+						},
+						RExpr: &Expression{
+							Type:     UNIT_EXPR,
+							Literal:  "B",
+							TermType: REGEX_TERM,
+						},
+					},
+					RExpr: &Expression{
+						Type:    UNIT_EXPR,
+						Literal: "c",
+					},
+				},
+				RExpr: &Expression{
+					Type:    UNIT_EXPR,
+					Literal: "d",
+				},
+			},
+			expectedKeywords: map[string]struct{}{
+				"a": {},
+				"c": {},
+				"d": {},
+			},
+			expectedRegexes: map[string]struct{}{
+				"B": {},
+			},
+			expectedErr: nil,
+			caseSense:   false,
+			message:     "ordered near operator with or",
+		},
+		{
+			expStr:           `("1" and "2") NEAR/3 "3"`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: NEAR operands must be terms or terms joined by OR"),
+			caseSense:        true,
+			message:          "near operator fail and operand",
+		},
+		{
+			expStr:           `"1" NEAR/3 "2" NEAR/3 "3"`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: NEAR operands must be terms or terms joined by OR"),
+			caseSense:        true,
+			message:          "near operator fail chained near",
+		},
+		{
+			expStr:           `NEAR/3 "3"`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: no left expression was found for NEAR"),
+			caseSense:        true,
+			message:          "near operator fail no left expression",
+		},
+		{
+			expStr:           `INORD("1" and "2" ONEAR/3 "3")`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: INORD operator must not contain ONEAR operator"),
+			caseSense:        true,
+			message:          "near operator fail inside inord",
+		},
+		{
+			expStr: `ATLEAST(2, "A", r"B", "c") and not "d"`,
+			expectedExp: Expression{
+				Type: AND_EXPR,
+				LExpr: &Expression{
+					Type:      ATLEAST_EXPR,
+					Threshold: 2,
+					Operands: []*Expression{
+						{Type: UNIT_EXPR, Literal: "a"},
+						{Type: UNIT_EXPR, Literal: "B", TermType: REGEX_TERM},
+						{Type: UNIT_EXPR, Literal: "c"},
+					},
+				},
+				RExpr: &Expression{
+					Type: NOT_EXPR,
+					RExpr: &Expression{
+						Type:    UNIT_EXPR,
+						Literal: "d",
+					},
+				},
+			},
+			expectedKeywords: map[string]struct{}{
+				"a": {},
+				"c": {},
+				"d": {},
+			},
+			expectedRegexes: map[string]struct{}{
+				"B": {},
+			},
+			expectedErr: nil,
+			caseSense:   false,
+			message:     "atleast operator",
+		},
+		{
+			expStr: `"a" or atmost(0, "b")`,
+			expectedExp: Expression{
+				Type: OR_EXPR,
+				LExpr: &Expression{
+					Type:    UNIT_EXPR,
+					Literal: "a",
+				},
+				RExpr: &Expression{
+					Type:      ATMOST_EXPR,
+					Threshold: 0,
+					Operands: []*Expression{
+						{Type: UNIT_EXPR, Literal: "b"},
+					},
+				},
+			},
+			expectedKeywords: map[string]struct{}{
+				"a": {},
+				"b": {},
+			},
+			expectedRegexes: map[string]struct{}{},
+			expectedErr:     nil,
+			caseSense:       true,
+			message:         "atmost operator",
+		},
+		{
+			expStr:           `EXACTLY(3, "a", "b")`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: EXACTLY threshold 3 is greater than the number of terms 2"),
+			caseSense:        true,
+			message:          "exactly operator fail threshold",
+		},
+		{
+			expStr:           `ATLEAST(1, "a", "a")`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: duplicated term 'a' on ATLEAST"),
+			caseSense:        true,
+			message:          "atleast operator fail duplicated term",
+		},
+		{
+			expStr:           `ATLEAST(1, "a" and "b")`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: Unexpected token 'AND' on ATLEAST"),
+			caseSense:        true,
+			message:          "atleast operator fail expression operand",
+		},
+		{
+			expStr:           `ATLEAST("a", "b")`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: expected the threshold of ATLEAST but found 'KEYWORD'"),
+			caseSense:        true,
+			message:          "atleast operator fail no threshold",
+		},
+		{
+			expStr:           `ATMOST(1)`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: ATMOST must have at least one term"),
+			caseSense:        true,
+			message:          "atmost operator fail no terms",
+		},
+		{
+			expStr: `COUNT("Refund") >= 3 or count(r"a+") != 0`,
+			expectedExp: Expression{
+				Type: OR_EXPR,
+				LExpr: &Expression{
+					Type:       COUNT_EXPR,
+					Comparator: ">=",
+					Threshold:  3,
+					RExpr: &Expression{
+						Type:    UNIT_EXPR,
+						Literal: "refund",
+					},
+				},
+				RExpr: &Expression{
+					Type:       COUNT_EXPR,
+					Comparator: "!=",
+					Threshold:  0,
+					RExpr: &Expression{
+						Type:     UNIT_EXPR,
+						Literal:  "a+",
+						TermType: REGEX_TERM,
+					},
+				},
+			},
+			expectedKeywords: map[string]struct{}{
+				"refund": {},
+			},
+			expectedRegexes: map[string]struct{}{
+				"a+": {},
+			},
+			expectedErr: nil,
+			caseSense:   false,
+			message:     "count operator",
+		},
+		{
+			expStr:           `COUNT("a") 3`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: expected a comparison operator after COUNT but found 'NUMBER'"),
+			caseSense:        true,
+			message:          "count operator fail no comparison",
+		},
+		{
+			expStr:           `COUNT("a" or "b") > 3`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: Unexpected token 'OR' on COUNT"),
+			caseSense:        true,
+			message:          "count operator fail expression operand",
+		},
+		{
+			expStr:           `COUNT("a") > "b"`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: expected a number after COUNT comparison but found 'KEYWORD'"),
+			caseSense:        true,
+			message:          "count operator fail no number",
+		},
+		{
+			expStr:           `INORD("a" and COUNT("b") > 1)`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf("invalid expression: INORD operator must not contain COUNT operator"),
+			caseSense:        true,
+			message:          "count operator fail inside inord",
+		},
+		{
+			expStr: `W"Cat" and not w"dog" or "cat"`,
+			expectedExp: Expression{
+				Type: OR_EXPR,
+				LExpr: &Expression{
+					Type: AND_EXPR,
+					LExpr: &Expression{
+						Type:     UNIT_EXPR,
+						Literal:  WordTermKey("cat"),
+						TermType: WORD_TERM,
+					},
+					RExpr: &Expression{
+						Type: NOT_EXPR,
+						RExpr: &Expression{
+							Type:     UNIT_EXPR,
+							Literal:  WordTermKey("dog"),
+							TermType: WORD_TERM,
+						},
+					},
+				},
+				RExpr: &Expression{
+					Type:    UNIT_EXPR,
+					Literal: "cat",
+				},
+			},
+			expectedKeywords: map[string]struct{}{
+				"cat": {},
+				"dog": {},
+			},
+			expectedRegexes: map[string]struct{}{},
+			expectedErr:     nil,
+			caseSense:       false,
+			message:         "whole word terms",
+		},
 		{
 			expStr:           `r"regex" and`,
 			expectedExp:      Expression{},
@@ -428,6 +822,22 @@ func TestParser(t *testing.T) {
 			caseSense:        true,
 			message:          "regex operator fail invalid next token",
 		},
+		{
+			expStr:           `"a" and "b" "c"`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf(`invalid expression: expected an operator before '"c"'`),
+			caseSense:        true,
+			message:          "fail operands without operator",
+		},
+		{
+			expStr:           `"a" not ("b" or r"c"/i)`,
+			expectedExp:      Expression{},
+			expectedKeywords: map[string]struct{}{},
+			expectedErr:      fmt.Errorf(`invalid expression: expected an operator before 'NOT ("b" OR R"c"/i)'`),
+			caseSense:        true,
+			message:          "fail operands without operator after the first term",
+		},
 	}
 
 	for _, tc := range tests {
@@ -441,3 +851,95 @@ func TestParser(t *testing.T) {
 		}
 	}
 }
+
+func TestParserWordKeywords(t *testing.T) {
+	assert := assert.New(t)
+	p := NewParser(strings.NewReader(`W"Cat" and "Dog" or ATLEAST(1, W"bird", "cat")`), true)
+	_, err := p.Parse()
+	assert.Nil(err)
+	assert.Equal(map[string]struct{}{"Cat": {}, "bird": {}}, p.GetWordKeywords())
+	assert.Equal(map[string]struct{}{"Cat": {}, "Dog": {}, "bird": {}, "cat": {}}, p.GetKeywords())
+}
+
+func TestParserWildcards(t *testing.T) {
+	assert := assert.New(t)
+	p := NewParser(strings.NewReader(`"Refund*" and not "colo?r" or COUNT("*ing") > 1`), false)
+	exp, err := p.Parse()
+	assert.Nil(err)
+	assert.Equal(WildcardTermKey("refund*"), exp.LExpr.LExpr.Literal)
+	assert.Equal(WildcardTermKey("colo?r"), exp.LExpr.RExpr.RExpr.Literal)
+	assert.Equal(WildcardTermKey("*ing"), exp.RExpr.RExpr.Literal)
+	assert.Equal(map[string]struct{}{"refund*": {}, "colo?r": {}, "*ing": {}}, p.GetWildcards())
+	assert.Equal(map[string]struct{}{"refund": {}, "colo": {}, "ing": {}}, p.GetKeywords())
+
+	_, err = NewParser(strings.NewReader(`"a" and "*?"`), false).Parse()
+	assert.Equal(fmt.Errorf("invalid expression: invalid wildcard '*?': the wildcard must have a literal part"), err)
+}
+
+func TestParserFuzzyTerms(t *testing.T) {
+	assert := assert.New(t)
+	p := NewParser(strings.NewReader(`F1"Password" and INORD("user" and f2"credit card")`), false)
+	exp, err := p.Parse()
+	assert.Nil(err)
+	assert.Equal(termKeyMark+`f1"password"`, exp.LExpr.Literal)
+	assert.Equal(termKeyMark+`f2"credit card"`, exp.RExpr.RExpr.RExpr.Literal)
+	assert.True(exp.RExpr.RExpr.RExpr.Inord)
+	assert.Equal(map[string]FuzzyTerm{
+		termKeyMark + `f1"password"`:    {Term: "password", Distance: 1},
+		termKeyMark + `f2"credit card"`: {Term: "credit card", Distance: 2},
+	}, p.GetFuzzyTerms())
+	assert.Equal(map[string]struct{}{"user": {}}, p.GetKeywords())
+
+	_, err = NewParser(strings.NewReader(`F3"cat"`), false).Parse()
+	assert.Equal(fmt.Errorf("invalid expression: invalid fuzzy term 'cat': the distance 3 must be lower than the length of the term"), err)
+
+	_, err = NewParser(strings.NewReader(`F1"--"`), false).Parse()
+	assert.Equal(fmt.Errorf("invalid expression: invalid fuzzy term '--': the term must have a word character"), err)
+}
+
+func TestParserCaseKeywords(t *testing.T) {
+	assert := assert.New(t)
+	p := NewParser(strings.NewReader(`C"US" and not "US" and C"Café"`), false)
+	exp, err := p.Parse()
+	assert.Nil(err)
+	assert.Equal(CaseTermKey("US"), exp.LExpr.LExpr.Literal)
+	assert.Equal("us", exp.LExpr.RExpr.RExpr.Literal)
+	assert.Equal(CaseTermKey("Café"), exp.RExpr.Literal)
+	assert.Equal(map[string]string{"US": "us", "Café": "café"}, p.GetCaseKeywords())
+	assert.Equal(map[string]struct{}{"us": {}, "café": {}}, p.GetKeywords())
+
+	p = NewParser(strings.NewReader(`C"Café"`), false)
+	p.SetNormalizer(NewNormalizer(CaseFolding | DiacriticStripping))
+	_, err = p.Parse()
+	assert.Nil(err)
+	assert.Equal(map[string]string{"Cafe": "cafe"}, p.GetCaseKeywords())
+}
+
+func TestParserRegexFlags(t *testing.T) {
+	assert := assert.New(t)
+	p := NewParser(strings.NewReader(`R"\\S+@\\W"/i and (r"^Total$"/sm or R"\\S+@\\W")`), false)
+	exp, err := p.Parse()
+	assert.Nil(err)
+	assert.Equal(`(?i)\S+@\W`, exp.LExpr.Literal)
+	assert.Equal(`(?ms)^Total$`, exp.RExpr.LExpr.Literal)
+	assert.Equal(`\S+@\W`, exp.RExpr.RExpr.Literal)
+	assert.Equal(map[string]RegexTerm{
+		`(?i)\S+@\W`:   {Pattern: `\S+@\W`, Flags: RegexCaseInsensitive},
+		`(?ms)^Total$`: {Pattern: `^Total$`, Flags: RegexMultiline | RegexDotAll},
+		`\S+@\W`:       {Pattern: `\S+@\W`},
+	}, p.GetRegexTerms())
+	assert.Equal(map[string]struct{}{`(?i)\S+@\W`: {}, `(?ms)^Total$`: {}, `\S+@\W`: {}}, p.GetRegexes())
+	assert.Equal(map[string]struct{}{}, p.GetKeywords())
+
+	p = NewParser(strings.NewReader(`r"Ação"`), false)
+	p.SetNormalizer(NewNormalizer(CaseFolding | DiacriticStripping))
+	exp, err = p.Parse()
+	assert.Nil(err)
+	assert.Equal("Ação", exp.Literal)
+
+	_, err = NewParser(strings.NewReader(`r"a"/x`), false).Parse()
+	assert.Equal(fmt.Errorf("invalid expression: invalid regex flag 'x' on 'x'"), err)
+
+	_, err = NewParser(strings.NewReader(`"a" /i`), false).Parse()
+	assert.Equal(fmt.Errorf("invalid expression: Unexpected operator was found (%d = 'i')", FLAGS), err)
+}
@@ -0,0 +1,221 @@
+package dsl
+
+import "fmt"
+
+// truth is the value of an expression whose terms may not be known yet.
+type truth int
+
+const (
+	falseTruth truth = iota
+	trueTruth
+	unknownTruth
+)
+
+// newTruth returns the truth of a known value.
+func newTruth(val bool) truth {
+	if val {
+		return trueTruth
+	}
+	return falseTruth
+}
+
+// PartialResult is the evaluation of an expression when the
+// matches of some of its terms are not known yet.
+type PartialResult struct {
+	// Decided is true if the matches of the unknown terms
+	// can not change the evaluation of the expression.
+	Decided bool
+	// Value is the evaluation of the expression if it is decided.
+	Value bool
+	// Needed are the unknown terms whose matches may change the
+	// evaluation of the expression. It is nil if the expression is decided.
+	Needed map[string]struct{}
+}
+
+// SolvePartially solves the expression when the matches of the unknownTerms are not known yet,
+// so the terms that are expensive to search (eg: regexes) are only searched if they are needed.
+// The other terms are solved as on SolveWithWordIndex. Unknown terms that are only on subtrees
+// whose outcome is already decided are not needed. Eg: for `"a" and R"[0-9]+"`, if "a" has
+// no matches the expression is decided as false and the regex is not needed.
+func (exp *Expression) SolvePartially(
+	sortedMatchesByKeyword map[string][]int,
+	unknownTerms map[string]struct{},
+	wordIndex *WordIndex,
+) (PartialResult, error) {
+	val, needed, err := exp.solvePartially(sortedMatchesByKeyword, unknownTerms, wordIndex)
+	if err != nil {
+		return PartialResult{}, err
+	}
+	if val != unknownTruth {
+		return PartialResult{Decided: true, Value: val == trueTruth}, nil
+	}
+	return PartialResult{Needed: needed}, nil
+}
+
+// solvePartially implements SolvePartially. The needed terms are only returned
+// if the expression is unknown.
+func (exp *Expression) solvePartially(
+	sortedMatchesByKeyword map[string][]int,
+	unknownTerms map[string]struct{},
+	wordIndex *WordIndex,
+) (truth, map[string]struct{}, error) {
+	unknown := make(map[string]struct{})
+	exp.collectTerms(unknownTerms, unknown)
+	if len(unknown) == 0 {
+		val, _, err := exp.solve(sortedMatchesByKeyword, wordIndex, nil)
+		return newTruth(val), nil, err
+	}
+
+	switch exp.Type {
+	case UNIT_EXPR:
+		return unknownTruth, unknown, nil
+
+	case AND_EXPR, OR_EXPR:
+		if exp.LExpr == nil || exp.RExpr == nil {
+			return falseTruth, nil, fmt.Errorf("%s statment do not have rigth or left expression: %v", exp.GetTypeName(), exp)
+		}
+		// the operand that decides the operator: false for AND and true for OR
+		decisive := newTruth(exp.Type == OR_EXPR)
+		needed := make(map[string]struct{})
+		for _, child := range []*Expression{exp.LExpr, exp.RExpr} {
+			val, childNeeded, err := child.solvePartially(sortedMatchesByKeyword, unknownTerms, wordIndex)
+			if err != nil {
+				return falseTruth, nil, err
+			}
+			if val == decisive {
+				return decisive, nil, nil
+			}
+			for term := range childNeeded {
+				needed[term] = struct{}{}
+			}
+		}
+		if len(needed) == 0 {
+			return newTruth(exp.Type == AND_EXPR), nil, nil
+		}
+		return unknownTruth, needed, nil
+
+	case NOT_EXPR:
+		if exp.RExpr == nil {
+			return falseTruth, nil, fmt.Errorf("NOT statement do not have expression: %v", exp)
+		}
+		val, needed, err := exp.RExpr.solvePartially(sortedMatchesByKeyword, unknownTerms, wordIndex)
+		if err != nil || val == unknownTruth {
+			return val, needed, err
+		}
+		return newTruth(val == falseTruth), nil, nil
+
+	case INORD_EXPR:
+		if exp.RExpr == nil {
+			return falseTruth, nil, fmt.Errorf("INORD statement do not have expression: %v", exp)
+		}
+		// the order of the matches may depend on every unknown term
+		if exp.inordPresence(sortedMatchesByKeyword, unknownTerms) == falseTruth {
+			return falseTruth, nil, nil
+		}
+		return unknownTruth, unknown, nil
+
+	case NEAR_EXPR, ONEAR_EXPR:
+		return exp.solveNearPartially(sortedMatchesByKeyword, unknownTerms, unknown, wordIndex)
+
+	case ATLEAST_EXPR, EXACTLY_EXPR, ATMOST_EXPR:
+		if len(exp.Operands) == 0 {
+			return falseTruth, nil, fmt.Errorf("%s statement do not have operands: %v", exp.GetTypeName(), exp)
+		}
+		found := 0
+		needed := make(map[string]struct{})
+		pending := 0
+		for _, operand := range exp.Operands {
+			val, operandNeeded, err := operand.solvePartially(sortedMatchesByKeyword, unknownTerms, wordIndex)
+			if err != nil {
+				return falseTruth, nil, err
+			}
+			switch val {
+			case trueTruth:
+				found++
+			case unknownTruth:
+				pending++
+				for term := range operandNeeded {
+					needed[term] = struct{}{}
+				}
+			}
+		}
+		if decided, eval := exp.thresholdDecided(found, pending); decided {
+			return newTruth(eval), nil, nil
+		}
+		return unknownTruth, needed, nil
+
+	case COUNT_EXPR:
+		return unknownTruth, unknown, nil
+
+	default:
+		return falseTruth, nil, fmt.Errorf("unable to process expression type %d", exp.Type)
+	}
+}
+
+// solveNearPartially implements solvePartially for the NEAR operators. The operands
+// are decided as false if one of them has no matches, and as true if the known
+// matches are already near, since more matches can not make them apart.
+func (exp *Expression) solveNearPartially(
+	sortedMatchesByKeyword map[string][]int,
+	unknownTerms map[string]struct{},
+	unknown map[string]struct{},
+	wordIndex *WordIndex,
+) (truth, map[string]struct{}, error) {
+	if exp.LExpr == nil || exp.RExpr == nil {
+		return falseTruth, nil, fmt.Errorf("%s statment do not have rigth or left expression: %v", exp.GetTypeName(), exp)
+	}
+	lpos, err := exp.LExpr.termPositions(sortedMatchesByKeyword)
+	if err != nil {
+		return falseTruth, nil, err
+	}
+	rpos, err := exp.RExpr.termPositions(sortedMatchesByKeyword)
+	if err != nil {
+		return falseTruth, nil, err
+	}
+	lUnknown := make(map[string]struct{})
+	exp.LExpr.collectTerms(unknownTerms, lUnknown)
+	rUnknown := make(map[string]struct{})
+	exp.RExpr.collectTerms(unknownTerms, rUnknown)
+	if (len(lpos) == 0 && len(lUnknown) == 0) || (len(rpos) == 0 && len(rUnknown) == 0) {
+		return falseTruth, nil, nil
+	}
+
+	if len(lpos) > 0 && len(rpos) > 0 {
+		lDist, rDist := lpos, rpos
+		if exp.DistanceUnit == WORD_DISTANCE && wordIndex != nil {
+			lDist = wordIndex.convertPositions(lpos)
+			rDist = wordIndex.convertPositions(rpos)
+		}
+		if (exp.Type == ONEAR_EXPR && isOrderedNear(lpos, rpos, lDist, rDist, exp.Distance)) ||
+			(exp.Type == NEAR_EXPR && isNear(lDist, rDist, exp.Distance)) {
+			return trueTruth, nil, nil
+		}
+	}
+	return unknownTruth, unknown, nil
+}
+
+// collectTerms adds to terms the literals of the expression that are on filter.
+// If filter is nil all the literals are added.
+func (exp *Expression) collectTerms(filter map[string]struct{}, terms map[string]struct{}) {
+	if exp == nil {
+		return
+	}
+	if exp.Type == UNIT_EXPR {
+		if _, ok := filter[exp.Literal]; ok || filter == nil {
+			terms[exp.Literal] = struct{}{}
+		}
+		return
+	}
+	exp.LExpr.collectTerms(filter, terms)
+	exp.RExpr.collectTerms(filter, terms)
+	for _, operand := range exp.Operands {
+		operand.collectTerms(filter, terms)
+	}
+}
+
+// Terms returns the literals of all the terms of the expression.
+func (exp *Expression) Terms() map[string]struct{} {
+	terms := make(map[string]struct{})
+	exp.collectTerms(nil, terms)
+	return terms
+}
@@ -0,0 +1,154 @@
+package dsl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolvePartially(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		expStr                 string
+		sortedMatchesByKeyword map[string][]int
+		unknownTerms           map[string]struct{}
+		expected               PartialResult
+		message                string
+	}{
+		{
+			expStr:                 `"a" and R"[0-9]+"`,
+			sortedMatchesByKeyword: map[string][]int{},
+			unknownTerms:           map[string]struct{}{"[0-9]+": {}},
+			expected:               PartialResult{Decided: true, Value: false},
+			message:                "and decided by the keyword",
+		},
+		{
+			expStr:                 `"a" and R"[0-9]+"`,
+			sortedMatchesByKeyword: map[string][]int{"a": {0}},
+			unknownTerms:           map[string]struct{}{"[0-9]+": {}},
+			expected:               PartialResult{Needed: map[string]struct{}{"[0-9]+": {}}},
+			message:                "and needs the regex",
+		},
+		{
+			expStr:                 `"a" or R"[0-9]+"`,
+			sortedMatchesByKeyword: map[string][]int{"a": {0}},
+			unknownTerms:           map[string]struct{}{"[0-9]+": {}},
+			expected:               PartialResult{Decided: true, Value: true},
+			message:                "or decided by the keyword",
+		},
+		{
+			expStr:                 `("a" and R"x+") or R"y+"`,
+			sortedMatchesByKeyword: map[string][]int{},
+			unknownTerms:           map[string]struct{}{"x+": {}, "y+": {}},
+			expected:               PartialResult{Needed: map[string]struct{}{"y+": {}}},
+			message:                "regex on a decided subtree",
+		},
+		{
+			expStr:                 `not ("a" and R"x+")`,
+			sortedMatchesByKeyword: map[string][]int{},
+			unknownTerms:           map[string]struct{}{"x+": {}},
+			expected:               PartialResult{Decided: true, Value: true},
+			message:                "not decided",
+		},
+		{
+			expStr:                 `atleast(2, "a", "b", R"x+")`,
+			sortedMatchesByKeyword: map[string][]int{"a": {0}, "b": {1}},
+			unknownTerms:           map[string]struct{}{"x+": {}},
+			expected:               PartialResult{Decided: true, Value: true},
+			message:                "threshold reached",
+		},
+		{
+			expStr:                 `exactly(1, "a", "b", R"x+")`,
+			sortedMatchesByKeyword: map[string][]int{"a": {0}},
+			unknownTerms:           map[string]struct{}{"x+": {}},
+			expected:               PartialResult{Needed: map[string]struct{}{"x+": {}}},
+			message:                "threshold pending",
+		},
+		{
+			expStr:                 `"a" near/3 (R"x+" or "b")`,
+			sortedMatchesByKeyword: map[string][]int{"a": {0}, "b": {2}},
+			unknownTerms:           map[string]struct{}{"x+": {}},
+			expected:               PartialResult{Decided: true, Value: true},
+			message:                "near with known matches near",
+		},
+		{
+			expStr:                 `"a" near/3 R"x+"`,
+			sortedMatchesByKeyword: map[string][]int{},
+			unknownTerms:           map[string]struct{}{"x+": {}},
+			expected:               PartialResult{Decided: true, Value: false},
+			message:                "near without known operand",
+		},
+		{
+			expStr:                 `inord("a" and R"x+")`,
+			sortedMatchesByKeyword: map[string][]int{"a": {0}},
+			unknownTerms:           map[string]struct{}{"x+": {}},
+			expected:               PartialResult{Needed: map[string]struct{}{"x+": {}}},
+			message:                "inord needs the order",
+		},
+		{
+			expStr:                 `count(R"x+") > 1 or "a"`,
+			sortedMatchesByKeyword: map[string][]int{},
+			unknownTerms:           map[string]struct{}{"x+": {}},
+			expected:               PartialResult{Needed: map[string]struct{}{"x+": {}}},
+			message:                "count",
+		},
+	}
+
+	for _, tc := range tests {
+		exp, err := NewParser(strings.NewReader(tc.expStr), true).Parse()
+		assert.Nil(err, tc.message)
+		res, err := exp.SolvePartially(tc.sortedMatchesByKeyword, tc.unknownTerms, nil)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expected, res, tc.message)
+	}
+}
+
+func TestSolvePartiallyMatchesSolve(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range solverTestCases {
+		exp, err := NewParser(strings.NewReader(tc.expStr), true).Parse()
+		assert.Nil(err, tc.message)
+
+		// each term is unknown in turn, a decided expression must have the
+		// same result with and without the matches of the unknown term
+		for term := range exp.Terms() {
+			known := make(map[string][]int)
+			for key, positions := range tc.sortedMatchesByKeyword {
+				if key != term {
+					known[key] = positions
+				}
+			}
+			res, err := exp.SolvePartially(known, map[string]struct{}{term: {}}, nil)
+			assert.Nil(err, tc.message)
+			if !res.Decided {
+				assert.Equal(map[string]struct{}{term: {}}, res.Needed, tc.message)
+				continue
+			}
+			assert.Equal(tc.expectedResp, res.Value, tc.message+" "+term)
+			val, err := exp.Solve(known)
+			assert.Nil(err, tc.message)
+			assert.Equal(res.Value, val, tc.message+" "+term)
+		}
+	}
+}
+
+func TestSolveShortCircuit(t *testing.T) {
+	assert := assert.New(t)
+	// the NEAR operand is invalid, but it is not evaluated if "a" is not found
+	exp, err := NewParser(strings.NewReader(`"a" and "b"`), true).Parse()
+	assert.Nil(err)
+	exp.RExpr = &Expression{
+		Type:     NEAR_EXPR,
+		Distance: 1,
+		LExpr:    &Expression{Type: UNIT_EXPR, Literal: "b"},
+		RExpr:    &Expression{Type: NOT_EXPR, RExpr: &Expression{Type: UNIT_EXPR, Literal: "c"}},
+	}
+
+	val, err := exp.Solve(map[string][]int{})
+	assert.Nil(err)
+	assert.False(val)
+
+	_, err = exp.Solve(map[string][]int{"a": {0}, "b": {1}})
+	assert.NotNil(err)
+}
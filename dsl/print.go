@@ -0,0 +1,200 @@
+package dsl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// String returns the expression written on the DSL. The output is canonical: the operators
+// are uppercase, the terms are written with their kinds (see TermType), flags and escapes
+// and only the needed parentheses are kept. Eg: `("a" OR R"b+"/i) AND NOT W"c"`
+// Parsing the output with the parser settings that returned the expression returns an
+// expression that is structurally equal to it.
+func (exp *Expression) String() string {
+	var sb strings.Builder
+	exp.writeTo(&sb)
+	return sb.String()
+}
+
+// writeTo writes the expression on the DSL to the builder.
+func (exp *Expression) writeTo(sb *strings.Builder) {
+	if exp == nil {
+		return
+	}
+	switch exp.Type {
+	case UNIT_EXPR:
+		exp.writeTerm(sb)
+	case AND_EXPR, OR_EXPR:
+		// the AND and OR operators have the same precedence and are
+		// left associative, so only the right operand needs parentheses
+		exp.LExpr.writeTo(sb)
+		sb.WriteString(" " + exp.GetTypeName() + " ")
+		exp.RExpr.writeOperand(sb, exp.RExpr != nil && (exp.RExpr.Type == AND_EXPR || exp.RExpr.Type == OR_EXPR))
+	case NOT_EXPR:
+		sb.WriteString("NOT ")
+		exp.RExpr.writeOperand(sb, exp.RExpr != nil && exp.RExpr.Type != UNIT_EXPR)
+	case INORD_EXPR:
+		sb.WriteString("INORD")
+		if exp.Distance > 0 {
+			exp.writeDistance(sb)
+		}
+		sb.WriteByte('(')
+		exp.RExpr.writeTo(sb)
+		sb.WriteByte(')')
+	case NEAR_EXPR, ONEAR_EXPR:
+		exp.LExpr.writeOperand(sb, exp.LExpr != nil && exp.LExpr.Type != UNIT_EXPR)
+		sb.WriteString(" " + exp.GetTypeName())
+		exp.writeDistance(sb)
+		sb.WriteByte(' ')
+		exp.RExpr.writeOperand(sb, exp.RExpr != nil && exp.RExpr.Type != UNIT_EXPR)
+	case ATLEAST_EXPR, EXACTLY_EXPR, ATMOST_EXPR:
+		sb.WriteString(exp.GetTypeName() + "(" + strconv.Itoa(exp.Threshold))
+		for _, operand := range exp.Operands {
+			sb.WriteString(", ")
+			operand.writeTo(sb)
+		}
+		sb.WriteByte(')')
+	case COUNT_EXPR:
+		sb.WriteString("COUNT(")
+		exp.RExpr.writeTo(sb)
+		sb.WriteString(") " + exp.Comparator + " " + strconv.Itoa(exp.Threshold))
+	}
+}
+
+// writeOperand writes the operand of an operator, enclosed by parentheses if parens is set.
+func (exp *Expression) writeOperand(sb *strings.Builder, parens bool) {
+	if !parens {
+		exp.writeTo(sb)
+		return
+	}
+	sb.WriteByte('(')
+	exp.writeTo(sb)
+	sb.WriteByte(')')
+}
+
+// writeDistance writes the distance of the NEAR operators or the gap of the INORD operator.
+// The unit is only written for the character distances, since words are the default.
+func (exp *Expression) writeDistance(sb *strings.Builder) {
+	sb.WriteString("/" + strconv.Itoa(exp.Distance))
+	if exp.DistanceUnit == CHAR_DISTANCE {
+		sb.WriteString(exp.DistanceUnit.suffix())
+	}
+}
+
+// writeTerm writes the term of the UNIT expression with the prefix of its kind,
+// extracting the term from the key that is kept as the Literal (see newTermExpr).
+func (exp *Expression) writeTerm(sb *strings.Builder) {
+	lit := exp.Literal
+	if exp.TermType != REGEX_TERM && exp.TermType != KEYWORD_TERM {
+		lit = strings.TrimPrefix(lit, termKeyMark)
+	}
+	switch exp.TermType {
+	case REGEX_TERM:
+		pattern, flags := splitRegexKey(lit)
+		sb.WriteByte('R')
+		writeQuoted(sb, pattern, false)
+		if flags != 0 {
+			sb.WriteString("/" + flags.String())
+		}
+	case WORD_TERM:
+		sb.WriteByte('W')
+		writeQuoted(sb, unquoteKey(strings.TrimPrefix(lit, "w")), false)
+	case CASE_TERM:
+		sb.WriteByte('C')
+		writeQuoted(sb, unquoteKey(strings.TrimPrefix(lit, "c")), false)
+	case FUZZY_TERM:
+		quote := strings.IndexByte(lit, '"')
+		if quote < 1 {
+			// not a fuzzy key, it is kept as a keyword
+			writeQuoted(sb, lit, true)
+			return
+		}
+		sb.WriteString("F" + lit[1:quote])
+		writeQuoted(sb, unquoteKey(lit[quote:]), false)
+	case WILDCARD_TERM:
+		writeWildcard(sb, unquoteKey(lit))
+	default:
+		writeQuoted(sb, lit, true)
+	}
+}
+
+// splitRegexKey returns the pattern and the flags of a regex key (see RegexTerm.Key).
+// A key whose flags group is not written as RegexTerm.Key writes it is kept as the pattern.
+func splitRegexKey(key string) (pattern string, flags RegexFlags) {
+	if !strings.HasPrefix(key, "(?") {
+		return key, 0
+	}
+	end := strings.IndexByte(key, ')')
+	if end < 0 {
+		return key, 0
+	}
+	flags, err := ParseRegexFlags(key[2:end])
+	if err != nil || flags == 0 || flags.String() != key[2:end] {
+		return key, 0
+	}
+	return key[end+1:], flags
+}
+
+// unquoteKey removes the quotes that enclose the term on its key. Eg: "term"
+func unquoteKey(key string) string {
+	if len(key) >= 2 && key[0] == '"' && key[len(key)-1] == '"' {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// writeQuoted writes the term enclosed by quotes, escaping the characters as scanKeyword
+// expects. If keyword is set the '*' and '?' are escaped so it is not read as a wildcard.
+func writeQuoted(sb *strings.Builder, term string, keyword bool) {
+	sb.WriteByte('"')
+	for _, ch := range term {
+		switch ch {
+		case '*', '?':
+			if keyword {
+				sb.WriteByte('\\')
+			}
+			sb.WriteRune(ch)
+		default:
+			writeEscaped(sb, ch)
+		}
+	}
+	sb.WriteByte('"')
+}
+
+// writeWildcard writes the pattern of a wildcard term enclosed by quotes. The escapes
+// of the pattern are kept (see scanKeyword) and the other characters are escaped.
+func writeWildcard(sb *strings.Builder, pattern string) {
+	sb.WriteByte('"')
+	escaped := false
+	for _, ch := range pattern {
+		switch {
+		case escaped:
+			sb.WriteRune(ch)
+			escaped = false
+		case ch == '\\':
+			sb.WriteRune(ch)
+			escaped = true
+		default:
+			writeEscaped(sb, ch)
+		}
+	}
+	sb.WriteByte('"')
+}
+
+// writeEscaped writes the rune escaped as scanKeyword expects.
+func writeEscaped(sb *strings.Builder, ch rune) {
+	switch ch {
+	case '\\':
+		sb.WriteString(`\\`)
+	case '"':
+		sb.WriteString(`\"`)
+	case '\n':
+		sb.WriteString(`\n`)
+	case '\r':
+		sb.WriteString(`\r`)
+	case '\t':
+		sb.WriteString(`\t`)
+	default:
+		sb.WriteRune(ch)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build go1.18
+// +build go1.18
+
+package dsl
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func FuzzExpressionString(f *testing.F) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		f.Add(randomExpression(rnd, 3), i%2 == 0)
+	}
+	f.Add(`"a\"b\\c\nd" and R"\\d+"/i or not W"x*"`, false)
+	f.Add(`INORD/2("a" and not ("b" or "c")) and "d" NEAR/3c "e"`, true)
+	f.Add(`ATLEAST(1, "a*", F1"bcd") or COUNT(C"US") != 2`, false)
+	f.Fuzz(func(t *testing.T, expStr string, caseSense bool) {
+		checkRoundTrip(t, expStr, caseSense)
+	})
+}
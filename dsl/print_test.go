@@ -0,0 +1,175 @@
+package dsl
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpressionString(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		expStr    string
+		expected  string
+		caseSense bool
+		message   string
+	}{
+		{
+			expStr:   `"a" and r"a"`,
+			expected: `"a" AND R"a"`,
+			message:  "keyword and regex with the same literal",
+		},
+		{
+			expStr:   `"a" and "b" or ("c" and ("d" or "e"))`,
+			expected: `"a" AND "b" OR ("c" AND ("d" OR "e"))`,
+			message:  "parentheses of the right operands",
+		},
+		{
+			expStr:   `(("a" and "b") or "c")`,
+			expected: `"a" AND "b" OR "c"`,
+			message:  "left associative operators",
+		},
+		{
+			expStr:   `not "a" and not ("b" or r"c") and not(not "d")`,
+			expected: `NOT "a" AND NOT ("b" OR R"c") AND NOT (NOT "d")`,
+			message:  "not operator",
+		},
+		{
+			expStr:   `inord/3c("a" and not ("b" or "c") and inord("d" and "e")) or inord("f")`,
+			expected: `INORD/3c("a" AND NOT ("b" OR "c") AND INORD("d" AND "e")) OR INORD("f")`,
+			message:  "inord operator",
+		},
+		{
+			expStr:   `("a" or "b") near/5w ("c" or r"d") and "e" onear/2c "f"`,
+			expected: `("a" OR "b") NEAR/5 ("c" OR R"d") AND "e" ONEAR/2c "f"`,
+			message:  "near operators",
+		},
+		{
+			expStr:   `atleast(2, "a", r"b", w"c") or count(f1"refund") >= 03`,
+			expected: `ATLEAST(2, "a", R"b", W"c") OR COUNT(F1"refund") >= 3`,
+			message:  "threshold and count operators",
+		},
+		{
+			expStr:   `r"\\d+"/mi and r"(?i)a" and r"(?mi)b" and C"US" and w"Cat"`,
+			expected: `R"\\d+"/im AND R"a"/i AND R"(?mi)b" AND C"US" AND W"cat"`,
+			message:  "term kinds",
+		},
+		{
+			expStr:   `"a\"b\\c\nd\te\rf\*g\?" or "a\*b*c\\?\"" or w"x*\"" or f1"a\\b"`,
+			expected: `"a\"b\\c\nd\te\rf\*g\?" OR "a\*b*c\\?\"" OR W"x*\"" OR F1"a\\b"`,
+			message:  "escapes",
+		},
+		{
+			expStr:    `"A" and C"B" or "ç" and not R"D"`,
+			expected:  `"A" AND C"B" OR "ç" AND NOT R"D"`,
+			caseSense: true,
+			message:   "case sensitive",
+		},
+	}
+
+	for _, tc := range tests {
+		exp, err := NewParser(strings.NewReader(tc.expStr), tc.caseSense).Parse()
+		if !assert.Nil(err, tc.message) {
+			continue
+		}
+		assert.Equal(tc.expected, exp.String(), tc.message)
+		reparsed, err := NewParser(strings.NewReader(exp.String()), tc.caseSense).Parse()
+		assert.Nil(err, tc.message)
+		assert.Equal(exp, reparsed, tc.message)
+	}
+}
+
+// randomTermChars are the characters of the random terms, including the ones that are escaped.
+var randomTermChars = []rune("aAbZ 19*?\\\"\n\t\r-ção")
+
+// randomTerm returns a random term written on the DSL, that may be invalid.
+func randomTerm(rnd *rand.Rand) string {
+	var sb strings.Builder
+	for i := rnd.Intn(5); i >= 0; i-- {
+		ch := randomTermChars[rnd.Intn(len(randomTermChars))]
+		switch ch {
+		case '\\', '"':
+			sb.WriteRune('\\')
+			sb.WriteRune(ch)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '*', '?':
+			if rnd.Intn(2) == 0 {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(ch)
+		default:
+			sb.WriteRune(ch)
+		}
+	}
+	term := `"` + sb.String() + `"`
+	switch rnd.Intn(8) {
+	case 0:
+		flags := []string{"", "/i", "/ms", "/sim"}
+		return "r" + term + flags[rnd.Intn(len(flags))]
+	case 1:
+		return "W" + term
+	case 2:
+		return "c" + term
+	case 3:
+		return "F" + strconv.Itoa(rnd.Intn(3)) + term
+	}
+	return term
+}
+
+// randomExpression returns a random expression written on the DSL with at most depth
+// levels of operators. It may be invalid, since the operators are combined freely.
+func randomExpression(rnd *rand.Rand, depth int) string {
+	if depth == 0 || rnd.Intn(4) == 0 {
+		return randomTerm(rnd)
+	}
+	next := func() string { return randomExpression(rnd, depth-1) }
+	switch rnd.Intn(8) {
+	case 0:
+		return next() + " and " + next()
+	case 1:
+		return next() + " OR " + next()
+	case 2:
+		return "(" + next() + ")"
+	case 3:
+		return "not " + next()
+	case 4:
+		gaps := []string{"", "/2", "/3c"}
+		return "inord" + gaps[rnd.Intn(len(gaps))] + "(" + next() + ")"
+	case 5:
+		ops := []string{" near/4 ", " ONEAR/1c ", " Near/0W "}
+		return next() + ops[rnd.Intn(len(ops))] + next()
+	case 6:
+		ops := []string{"atleast", "EXACTLY", "atmost"}
+		return ops[rnd.Intn(len(ops))] + "(" + strconv.Itoa(rnd.Intn(3)) + ", " + randomTerm(rnd) + ", " + randomTerm(rnd) + ")"
+	default:
+		ops := []string{">", ">=", "<", "<=", "==", "!="}
+		return "count(" + randomTerm(rnd) + ") " + ops[rnd.Intn(len(ops))] + " " + strconv.Itoa(rnd.Intn(5))
+	}
+}
+
+// checkRoundTrip checks that the printed expression is parsed to an expression structurally
+// equal to the parsed one and that it is printed again with the same text.
+func checkRoundTrip(t *testing.T, expStr string, caseSense bool) {
+	exp, err := NewParser(strings.NewReader(expStr), caseSense).Parse()
+	if err != nil {
+		return
+	}
+	printed := exp.String()
+	reparsed, err := NewParser(strings.NewReader(printed), caseSense).Parse()
+	if !assert.Nil(t, err, "%q printed as %q", expStr, printed) {
+		return
+	}
+	assert.Equal(t, exp, reparsed, "%q printed as %q", expStr, printed)
+	assert.Equal(t, printed, reparsed.String(), "%q printed as %q", expStr, printed)
+}
+
+func TestExpressionStringRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(25))
+	for i := 0; i < 5000; i++ {
+		checkRoundTrip(t, randomExpression(rnd, 4), rnd.Intn(2) == 0)
+	}
+}
@@ -0,0 +1,79 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegexFlags is a set of flags that changes how a regex is matched.
+type RegexFlags uint
+
+const (
+	// RegexCaseInsensitive matches the regex ignoring the case. Eg: R"refund"/i
+	RegexCaseInsensitive RegexFlags = 1 << iota
+	// RegexMultiline makes '^' and '$' match the start and the end of each line. Eg: R"^total$"/m
+	RegexMultiline
+	// RegexDotAll makes '.' match '\n' as well. Eg: R"begin.*end"/s
+	RegexDotAll
+)
+
+// regexFlagNames holds the letters of the flags on the DSL syntax
+// in the order they are written by RegexFlags.String.
+var regexFlagNames = []struct {
+	flag RegexFlags
+	name byte
+}{
+	{RegexCaseInsensitive, 'i'},
+	{RegexMultiline, 'm'},
+	{RegexDotAll, 's'},
+}
+
+// ParseRegexFlags parses the letters of the flags of a regex term. Eg: "ims"
+// Returns an error if a letter is not a valid flag.
+func ParseRegexFlags(lit string) (flags RegexFlags, err error) {
+	for i := 0; i < len(lit); i++ {
+		found := false
+		for _, fn := range regexFlagNames {
+			if lit[i] == fn.name {
+				flags |= fn.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid regex flag '%c' on '%s'", lit[i], lit)
+		}
+	}
+	return
+}
+
+// String returns the letters of the flags. Eg: "ims"
+func (flags RegexFlags) String() string {
+	var sb strings.Builder
+	for _, fn := range regexFlagNames {
+		if flags&fn.flag != 0 {
+			sb.WriteByte(fn.name)
+		}
+	}
+	return sb.String()
+}
+
+// RegexTerm is a regex with the flags it is matched with. Eg: R"\S+@\S+"/i
+// The pattern is kept as it was written, even if the parser is not case
+// sensitive, since changing its case would change the meaning of escapes
+// like \S or \W.
+type RegexTerm struct {
+	Pattern string
+	Flags   RegexFlags
+}
+
+// Key returns the key of the regex term on the map of matches used to solve the
+// expressions. A regex without flags is keyed by its pattern and a regex with flags
+// by the pattern prefixed with the equivalent Go flags group. Eg: (?i)\S+@\S+
+// So engines that only receive the keys (see RegexEngine) still honor the flags.
+func (rt RegexTerm) Key() string {
+	if rt.Flags == 0 {
+		return rt.Pattern
+	}
+	return "(?" + rt.Flags.String() + ")" + rt.Pattern
+}
@@ -1,250 +1,525 @@
-package dsl
-
-import (
-	"bufio"
-	"bytes"
-	"fmt"
-	"io"
-	"strings"
-)
-
-// Token represents a lexical token.
-type Token int
-
-const (
-	// Special tokens
-	ILLEGAL Token = iota
-	EOF
-	WS
-
-	// Literals
-	KEYWORD // "keyword"
-
-	// Misc characters
-	QUOTATION // "
-	OPPAR     // (
-	CLPAR     // )
-
-	// Operators
-	AND   // 'and' or 'AND'
-	OR    // 'or' or 'OR'
-	NOT   // 'not' or 'NOT'
-	INORD // 'inord' or 'INORD'
-	REGEX // 'r' or 'R'
-)
-
-// getName retuns a readable name for the Token
-func (tok Token) getName() string {
-	switch tok {
-	case ILLEGAL:
-		return "ILLEGAL"
-	case EOF:
-		return "EOF"
-	case WS:
-		return "WS"
-	case KEYWORD:
-		return "KEYWORD"
-	case QUOTATION:
-		return "QUOTATION"
-	case OPPAR:
-		return "OPPAR"
-	case CLPAR:
-		return "CLPAR"
-	case AND:
-		return "AND"
-	case OR:
-		return "OR"
-	case NOT:
-		return "NOT"
-	case INORD:
-		return "INORD"
-	case REGEX:
-		return "REGEX"
-	default:
-		return "UNEXPECTED"
-	}
-}
-
-// Scanner represents a lexical scanner.
-type Scanner struct {
-	r *bufio.Reader
-}
-
-// NewScanner returns a new instance of Scanner.
-func NewScanner(r io.Reader) *Scanner {
-	return &Scanner{r: bufio.NewReader(r)}
-}
-
-// Scan returns the next token and literal value.
-func (s *Scanner) Scan() (tok Token, lit string, err error) {
-	// Read the next rune.
-	ch := s.read()
-
-	// If we see whitespace then consume all contiguous whitespace.
-	// If we see a letter then consume as an operator.
-	// If we see a '"' consume as a KEYWORD.
-	// If we see a '(' or ')' returns OPPAR or CLPAR respectively
-	switch {
-	case isWhitespace(ch):
-		s.unread()
-		return s.scanWhitespace()
-	case ch == '"':
-		s.unread()
-		return s.scanKeyword(false)
-	case isLetter(ch):
-		s.unread()
-		return s.scanOperators()
-	case ch == '(':
-		return OPPAR, "(", nil
-	case ch == ')':
-		return CLPAR, ")", nil
-	case ch == eof:
-		return EOF, "", nil
-	}
-
-	return ILLEGAL, "", fmt.Errorf("illegal char was found %c", ch)
-}
-
-// scanWhitespace consumes the current rune and all contiguous whitespace.
-func (s *Scanner) scanWhitespace() (tok Token, lit string, err error) {
-	// Create a buffer and read the current character into it.
-	var buf bytes.Buffer
-	buf.WriteRune(s.read())
-
-	// Read every subsequent whitespace character into the buffer.
-	// Non-whitespace characters and EOF will cause the loop to exit.
-	for {
-		if ch := s.read(); ch == eof {
-			break
-		} else if !isWhitespace(ch) {
-			s.unread()
-			break
-		} else {
-			buf.WriteRune(ch)
-		}
-	}
-
-	return WS, buf.String(), nil
-}
-
-// scanOperators consumes the current rune and all contiguous operator runes.
-func (s *Scanner) scanOperators() (tok Token, lit string, err error) {
-	// Create a buffer and read the current character into it.
-	ch := s.read()
-	if !isLetter(ch) {
-		return ILLEGAL, "", fmt.Errorf("fail to scan operator: expected letter but found %c", ch)
-	}
-	var buf bytes.Buffer
-
-	buf.WriteRune(ch)
-
-	// Read every subsequent operator character into the buffer.
-	// Non-operator characters and EOF will cause the loop to exit.
-	for {
-		if ch := s.read(); ch == eof {
-			break
-		} else if !isLetter(ch) {
-			s.unread()
-			break
-		} else {
-			_, _ = buf.WriteRune(ch)
-		}
-	}
-
-	// If the string matches a operator then return that operator.
-	// Otherwise return an error.
-	lit = buf.String()
-	switch strings.ToUpper(lit) {
-	case "AND":
-		tok = AND
-	case "OR":
-		tok = OR
-	case "NOT":
-		tok = NOT
-	case "INORD":
-		tok = INORD
-	case "R":
-		tok, lit, err = s.scanKeyword(true)
-	default:
-		return ILLEGAL, "", fmt.Errorf("failed to scan operator: unexpected operator '%s' found", lit)
-	}
-
-	return
-}
-
-// scanKeyword scans the keyword and scape needed characters
-// If a invalid scape is used an error will be returned and if EOF is found
-// before a '"' returns an error as well.
-func (s *Scanner) scanKeyword(isRegex bool) (tok Token, lit string, err error) {
-	ch := s.read()
-	scanType := "keyword"
-	if isRegex {
-		scanType = "regex"
-	}
-	if ch != '"' {
-		return ILLEGAL, "", fmt.Errorf("fail to scan %s: expected \" but found %c", scanType, ch)
-	}
-	var buf bytes.Buffer
-
-	endloop := false
-	for {
-		ch := s.read()
-		switch ch {
-		case eof:
-			return ILLEGAL, "", fmt.Errorf("fail to scan %s: expected \" but found EOF", scanType)
-		case '\\':
-			scapedCh := s.read()
-			switch scapedCh {
-			case '\\':
-				_, _ = buf.WriteRune(scapedCh)
-			case 'n':
-				_, _ = buf.WriteRune('\n')
-			case 'r':
-				_, _ = buf.WriteRune('\r')
-			case 't':
-				_, _ = buf.WriteRune('\t')
-			case '"':
-				_, _ = buf.WriteRune(scapedCh)
-			default:
-				return ILLEGAL, "", fmt.Errorf("fail to scan %s: invalid escaped char %c", scanType, scapedCh)
-			}
-		case '"':
-			endloop = true
-		default:
-			_, _ = buf.WriteRune(ch)
-		}
-		if endloop {
-			break
-		}
-	}
-	lit = buf.String()
-	if isRegex {
-		tok = REGEX
-	} else {
-		tok = KEYWORD
-	}
-
-	return
-}
-
-// read reads the next rune from the buffered reader.
-// Returns the rune(0) if an error occurs (or io.EOF is returned).
-func (s *Scanner) read() rune {
-	ch, _, err := s.r.ReadRune()
-	if err != nil {
-		return eof
-	}
-	return ch
-}
-
-// unread places the previously read rune back on the reader.
-func (s *Scanner) unread() { _ = s.r.UnreadRune() }
-
-// isWhitespace returns true if the rune is a space, tab, or newline.
-func isWhitespace(ch rune) bool { return ch == ' ' || ch == '\t' || ch == '\n' }
-
-// isLetter returns true if the rune is a letter.
-func isLetter(ch rune) bool { return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') }
-
-// eof represents a marker rune for the end of the reader.
-var eof = rune(0)
+package dsl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Token represents a lexical token.
+type Token int
+
+const (
+	// Special tokens
+	ILLEGAL Token = iota
+	EOF
+	WS
+
+	// Literals
+	KEYWORD // "keyword"
+
+	// Misc characters
+	QUOTATION // "
+	OPPAR     // (
+	CLPAR     // )
+
+	// Operators
+	AND   // 'and' or 'AND'
+	OR    // 'or' or 'OR'
+	NOT   // 'not' or 'NOT'
+	INORD // 'inord', 'INORD' or 'INORD/n'
+	REGEX // 'r' or 'R'
+	NEAR  // 'near/n' or 'NEAR/n'
+	ONEAR // 'onear/n' or 'ONEAR/n'
+
+	// Threshold operators
+	ATLEAST // 'atleast' or 'ATLEAST'
+	EXACTLY // 'exactly' or 'EXACTLY'
+	ATMOST  // 'atmost' or 'ATMOST'
+
+	NUMBER // 123
+	COMMA  // ,
+
+	COUNT // 'count' or 'COUNT'
+	WORD  // 'w' or 'W'
+
+	WILDCARD // "keyword*" or "key?word"
+	FUZZY    // 'f1"keyword"' or 'F1"keyword"'
+	CASE     // 'c' or 'C'
+	FLAGS    // '/ims' after a regex
+
+	// Comparison operators
+	GT  // >
+	GTE // >=
+	LT  // <
+	LTE // <=
+	EQ  // ==
+	NEQ // !=
+)
+
+// getName retuns a readable name for the Token
+func (tok Token) getName() string {
+	switch tok {
+	case ILLEGAL:
+		return "ILLEGAL"
+	case EOF:
+		return "EOF"
+	case WS:
+		return "WS"
+	case KEYWORD:
+		return "KEYWORD"
+	case QUOTATION:
+		return "QUOTATION"
+	case OPPAR:
+		return "OPPAR"
+	case CLPAR:
+		return "CLPAR"
+	case AND:
+		return "AND"
+	case OR:
+		return "OR"
+	case NOT:
+		return "NOT"
+	case INORD:
+		return "INORD"
+	case REGEX:
+		return "REGEX"
+	case NEAR:
+		return "NEAR"
+	case ONEAR:
+		return "ONEAR"
+	case ATLEAST:
+		return "ATLEAST"
+	case EXACTLY:
+		return "EXACTLY"
+	case ATMOST:
+		return "ATMOST"
+	case NUMBER:
+		return "NUMBER"
+	case COMMA:
+		return "COMMA"
+	case COUNT:
+		return "COUNT"
+	case WORD:
+		return "WORD"
+	case WILDCARD:
+		return "WILDCARD"
+	case FUZZY:
+		return "FUZZY"
+	case CASE:
+		return "CASE"
+	case FLAGS:
+		return "FLAGS"
+	case GT:
+		return "GT"
+	case GTE:
+		return "GTE"
+	case LT:
+		return "LT"
+	case LTE:
+		return "LTE"
+	case EQ:
+		return "EQ"
+	case NEQ:
+		return "NEQ"
+	default:
+		return "UNEXPECTED"
+	}
+}
+
+// Scanner represents a lexical scanner.
+type Scanner struct {
+	r *bufio.Reader
+}
+
+// NewScanner returns a new instance of Scanner.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{r: bufio.NewReader(r)}
+}
+
+// Scan returns the next token and literal value.
+func (s *Scanner) Scan() (tok Token, lit string, err error) {
+	// Read the next rune.
+	ch := s.read()
+
+	// If we see whitespace then consume all contiguous whitespace.
+	// If we see a letter then consume as an operator.
+	// If we see a '"' consume as a KEYWORD.
+	// If we see a '(' or ')' returns OPPAR or CLPAR respectively
+	// If we see a digit consume as a NUMBER.
+	// If we see a ',' returns COMMA
+	// If we see a '>', '<', '=' or '!' consume as a comparison operator.
+	// If we see a '/' consume as the FLAGS of a regex.
+	switch {
+	case isWhitespace(ch):
+		s.unread()
+		return s.scanWhitespace()
+	case ch == '"':
+		s.unread()
+		return s.scanKeyword(KEYWORD)
+	case isLetter(ch):
+		s.unread()
+		return s.scanOperators()
+	case ch == '(':
+		return OPPAR, "(", nil
+	case ch == ')':
+		return CLPAR, ")", nil
+	case isDigit(ch):
+		s.unread()
+		return s.scanNumber()
+	case ch == ',':
+		return COMMA, ",", nil
+	case ch == '>' || ch == '<' || ch == '=' || ch == '!':
+		s.unread()
+		return s.scanComparison()
+	case ch == '/':
+		return s.scanFlags()
+	case ch == eof:
+		return EOF, "", nil
+	}
+
+	return ILLEGAL, "", fmt.Errorf("illegal char was found %c", ch)
+}
+
+// scanWhitespace consumes the current rune and all contiguous whitespace.
+func (s *Scanner) scanWhitespace() (tok Token, lit string, err error) {
+	// Create a buffer and read the current character into it.
+	var buf bytes.Buffer
+	buf.WriteRune(s.read())
+
+	// Read every subsequent whitespace character into the buffer.
+	// Non-whitespace characters and EOF will cause the loop to exit.
+	for {
+		if ch := s.read(); ch == eof {
+			break
+		} else if !isWhitespace(ch) {
+			s.unread()
+			break
+		} else {
+			buf.WriteRune(ch)
+		}
+	}
+
+	return WS, buf.String(), nil
+}
+
+// scanOperators consumes the current rune and all contiguous operator runes.
+func (s *Scanner) scanOperators() (tok Token, lit string, err error) {
+	// Create a buffer and read the current character into it.
+	ch := s.read()
+	if !isLetter(ch) {
+		return ILLEGAL, "", fmt.Errorf("fail to scan operator: expected letter but found %c", ch)
+	}
+	var buf bytes.Buffer
+
+	buf.WriteRune(ch)
+
+	// Read every subsequent operator character into the buffer.
+	// Non-operator characters and EOF will cause the loop to exit.
+	for {
+		if ch := s.read(); ch == eof {
+			break
+		} else if !isLetter(ch) {
+			s.unread()
+			break
+		} else {
+			_, _ = buf.WriteRune(ch)
+		}
+	}
+
+	// If the string matches a operator then return that operator.
+	// Otherwise return an error.
+	lit = buf.String()
+	switch strings.ToUpper(lit) {
+	case "AND":
+		tok = AND
+	case "OR":
+		tok = OR
+	case "NOT":
+		tok = NOT
+	case "INORD":
+		tok = INORD
+		// the gap of the INORD operator is optional
+		if ch := s.read(); ch == '/' {
+			s.unread()
+			lit, err = s.scanDistance(lit)
+			if err != nil {
+				return ILLEGAL, "", err
+			}
+		} else {
+			s.unread()
+		}
+	case "R":
+		tok, lit, err = s.scanKeyword(REGEX)
+	case "W":
+		tok, lit, err = s.scanKeyword(WORD)
+	case "F":
+		tok, lit, err = s.scanFuzzy()
+	case "C":
+		tok, lit, err = s.scanKeyword(CASE)
+	case "ATLEAST":
+		tok = ATLEAST
+	case "EXACTLY":
+		tok = EXACTLY
+	case "ATMOST":
+		tok = ATMOST
+	case "COUNT":
+		tok = COUNT
+	case "NEAR", "ONEAR":
+		tok = NEAR
+		if strings.ToUpper(lit) == "ONEAR" {
+			tok = ONEAR
+		}
+		lit, err = s.scanDistance(lit)
+		if err != nil {
+			return ILLEGAL, "", err
+		}
+	default:
+		return ILLEGAL, "", fmt.Errorf("failed to scan operator: unexpected operator '%s' found", lit)
+	}
+
+	return
+}
+
+// scanNumber consumes the current rune and all contiguous digits.
+func (s *Scanner) scanNumber() (tok Token, lit string, err error) {
+	var buf bytes.Buffer
+	buf.WriteRune(s.read())
+
+	for {
+		if ch := s.read(); ch == eof {
+			break
+		} else if !isDigit(ch) {
+			s.unread()
+			break
+		} else {
+			buf.WriteRune(ch)
+		}
+	}
+
+	return NUMBER, buf.String(), nil
+}
+
+// scanComparison consumes a comparison operator, that can have one or two runes.
+func (s *Scanner) scanComparison() (tok Token, lit string, err error) {
+	ch := s.read()
+	lit = string(ch)
+	if next := s.read(); next == '=' {
+		lit += string(next)
+	} else {
+		s.unread()
+	}
+
+	switch lit {
+	case ">":
+		tok = GT
+	case ">=":
+		tok = GTE
+	case "<":
+		tok = LT
+	case "<=":
+		tok = LTE
+	case "==":
+		tok = EQ
+	case "!=":
+		tok = NEQ
+	default:
+		return ILLEGAL, "", fmt.Errorf("fail to scan comparison: invalid operator '%s'", lit)
+	}
+	return
+}
+
+// scanDistance scans the distance of a NEAR operator or the gap of an INORD operator, that is
+// a '/' followed by the distance and an optional unit ('w' for words or 'c' for characters).
+// Returns the operator literal with the distance. Eg: "NEAR/5c"
+func (s *Scanner) scanDistance(operator string) (lit string, err error) {
+	var buf bytes.Buffer
+	buf.WriteString(operator)
+
+	if ch := s.read(); ch != '/' {
+		return "", fmt.Errorf("fail to scan %s: expected / but found %c", operator, ch)
+	}
+	buf.WriteRune('/')
+
+	digits := 0
+	for {
+		ch := s.read()
+		if !isDigit(ch) {
+			s.unread()
+			break
+		}
+		buf.WriteRune(ch)
+		digits++
+	}
+	if digits == 0 {
+		return "", fmt.Errorf("fail to scan %s: expected the distance after /", operator)
+	}
+
+	ch := s.read()
+	switch ch {
+	case 'w', 'W', 'c', 'C':
+		buf.WriteRune(ch)
+		ch = s.read()
+	}
+	if isLetter(ch) || isDigit(ch) {
+		return "", fmt.Errorf("fail to scan %s: invalid distance unit %c", operator, ch)
+	}
+	s.unread()
+
+	return buf.String(), nil
+}
+
+// scanFlags consumes the letters of the flags of a regex after the '/'.
+// Returns the letters as the literal. Eg: "ims"
+func (s *Scanner) scanFlags() (tok Token, lit string, err error) {
+	var buf bytes.Buffer
+	for {
+		ch := s.read()
+		if !isLetter(ch) {
+			s.unread()
+			break
+		}
+		buf.WriteRune(ch)
+	}
+	if buf.Len() == 0 {
+		return ILLEGAL, "", fmt.Errorf("fail to scan flags: expected the flags after /")
+	}
+	return FLAGS, buf.String(), nil
+}
+
+// scanFuzzy scans a fuzzy term, that is the edit distance followed by the keyword.
+// Returns the distance followed by the quoted keyword as the literal. Eg: 1"password"
+func (s *Scanner) scanFuzzy() (tok Token, lit string, err error) {
+	var buf bytes.Buffer
+	for {
+		ch := s.read()
+		if !isDigit(ch) {
+			s.unread()
+			break
+		}
+		buf.WriteRune(ch)
+	}
+	if buf.Len() == 0 {
+		return ILLEGAL, "", fmt.Errorf("fail to scan fuzzy: expected the distance after F")
+	}
+
+	_, keyword, err := s.scanKeyword(FUZZY)
+	if err != nil {
+		return ILLEGAL, "", err
+	}
+	buf.WriteRune('"')
+	buf.WriteString(keyword)
+	buf.WriteRune('"')
+	return FUZZY, buf.String(), nil
+}
+
+// scanKeyword scans the keyword and scape needed characters
+// If a invalid scape is used an error will be returned and if EOF is found
+// before a '"' returns an error as well.
+// If a keyword has a '*' or '?' that is not escaped it is returned as a WILDCARD
+// with the escapes of '*', '?' and '\' kept on the literal, so it can be parsed
+// by NewWildcard.
+func (s *Scanner) scanKeyword(termTok Token) (tok Token, lit string, err error) {
+	ch := s.read()
+	scanType := "keyword"
+	switch termTok {
+	case REGEX:
+		scanType = "regex"
+	case WORD:
+		scanType = "word"
+	case FUZZY:
+		scanType = "fuzzy"
+	case CASE:
+		scanType = "case"
+	}
+	if ch != '"' {
+		return ILLEGAL, "", fmt.Errorf("fail to scan %s: expected \" but found %c", scanType, ch)
+	}
+	var buf bytes.Buffer
+	// pattern holds the literal in case it is a WILDCARD
+	var pattern bytes.Buffer
+	isWildcard := false
+
+	endloop := false
+	for {
+		ch := s.read()
+		switch ch {
+		case eof:
+			return ILLEGAL, "", fmt.Errorf("fail to scan %s: expected \" but found EOF", scanType)
+		case '\\':
+			scapedCh := s.read()
+			switch scapedCh {
+			case '\\':
+				_, _ = buf.WriteRune(scapedCh)
+				_, _ = pattern.WriteString(`\\`)
+			case 'n':
+				_, _ = buf.WriteRune('\n')
+				_, _ = pattern.WriteRune('\n')
+			case 'r':
+				_, _ = buf.WriteRune('\r')
+				_, _ = pattern.WriteRune('\r')
+			case 't':
+				_, _ = buf.WriteRune('\t')
+				_, _ = pattern.WriteRune('\t')
+			case '"':
+				_, _ = buf.WriteRune(scapedCh)
+				_, _ = pattern.WriteRune(scapedCh)
+			case '*', '?':
+				if termTok != KEYWORD {
+					return ILLEGAL, "", fmt.Errorf("fail to scan %s: invalid escaped char %c", scanType, scapedCh)
+				}
+				_, _ = buf.WriteRune(scapedCh)
+				_, _ = pattern.WriteRune('\\')
+				_, _ = pattern.WriteRune(scapedCh)
+			default:
+				return ILLEGAL, "", fmt.Errorf("fail to scan %s: invalid escaped char %c", scanType, scapedCh)
+			}
+		case '"':
+			endloop = true
+		case '*', '?':
+			if termTok == KEYWORD {
+				isWildcard = true
+			}
+			_, _ = buf.WriteRune(ch)
+			_, _ = pattern.WriteRune(ch)
+		default:
+			_, _ = buf.WriteRune(ch)
+			_, _ = pattern.WriteRune(ch)
+		}
+		if endloop {
+			break
+		}
+	}
+
+	if isWildcard {
+		return WILDCARD, pattern.String(), nil
+	}
+	return termTok, buf.String(), nil
+}
+
+// read reads the next rune from the buffered reader.
+// Returns the rune(0) if an error occurs (or io.EOF is returned).
+func (s *Scanner) read() rune {
+	ch, _, err := s.r.ReadRune()
+	if err != nil {
+		return eof
+	}
+	return ch
+}
+
+// unread places the previously read rune back on the reader.
+func (s *Scanner) unread() { _ = s.r.UnreadRune() }
+
+// isWhitespace returns true if the rune is a space, tab, or newline.
+func isWhitespace(ch rune) bool { return ch == ' ' || ch == '\t' || ch == '\n' }
+
+// isLetter returns true if the rune is a letter.
+func isLetter(ch rune) bool { return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') }
+
+// isDigit returns true if the rune is a digit.
+func isDigit(ch rune) bool { return ch >= '0' && ch <= '9' }
+
+// eof represents a marker rune for the end of the reader.
+var eof = rune(0)
@@ -91,15 +91,261 @@ func TestScanner(t *testing.T) {
 		},
 
 		{
-			expStr: `123`,
+			expStr: `near/5 ONEAR/10c Near/3W`,
+			expected: []expectedAtScan{
+				{Tok: NEAR, Lit: "near/5", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: ONEAR, Lit: "ONEAR/10c", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: NEAR, Lit: "Near/3W", Err: nil},
+				{Tok: EOF, Lit: "", Err: nil},
+			},
+			message: "near tokens",
+		},
+		{
+			expStr: `inord/3 INORD/10c`,
+			expected: []expectedAtScan{
+				{Tok: INORD, Lit: "inord/3", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: INORD, Lit: "INORD/10c", Err: nil},
+				{Tok: EOF, Lit: "", Err: nil},
+			},
+			message: "inord tokens with gap",
+		},
+		{
+			expStr: `near 5`,
 			expected: []expectedAtScan{
 				{
 					Tok: ILLEGAL,
 					Lit: "",
-					Err: fmt.Errorf("illegal char was found 1"),
+					Err: fmt.Errorf("fail to scan near: expected / but found  "),
 				},
 			},
-			message: "invalid scaped keyword",
+			message: "near without distance",
+		},
+		{
+			expStr: `NEAR/"a"`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("fail to scan NEAR: expected the distance after /"),
+				},
+			},
+			message: "near with empty distance",
+		},
+		{
+			expStr: `NEAR/5x`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("fail to scan NEAR: invalid distance unit x"),
+				},
+			},
+			message: "near with invalid unit",
+		},
+		{
+			expStr: `#`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("illegal char was found #"),
+				},
+			},
+			message: "invalid char",
+		},
+		{
+			expStr: `\123`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("illegal char was found \\"),
+				},
+			},
+			message: "scaped number outside a keyword",
+		},
+		{
+			expStr: `atleast(12, "a",r"b") EXACTLY ATMOST`,
+			expected: []expectedAtScan{
+				{Tok: ATLEAST, Lit: "atleast", Err: nil},
+				{Tok: OPPAR, Lit: "(", Err: nil},
+				{Tok: NUMBER, Lit: "12", Err: nil},
+				{Tok: COMMA, Lit: ",", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: KEYWORD, Lit: "a", Err: nil},
+				{Tok: COMMA, Lit: ",", Err: nil},
+				{Tok: REGEX, Lit: "b", Err: nil},
+				{Tok: CLPAR, Lit: ")", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: EXACTLY, Lit: "EXACTLY", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: ATMOST, Lit: "ATMOST", Err: nil},
+				{Tok: EOF, Lit: "", Err: nil},
+			},
+			message: "threshold tokens",
+		},
+		{
+			expStr: `count("a")>=3 > < <= == !=`,
+			expected: []expectedAtScan{
+				{Tok: COUNT, Lit: "count", Err: nil},
+				{Tok: OPPAR, Lit: "(", Err: nil},
+				{Tok: KEYWORD, Lit: "a", Err: nil},
+				{Tok: CLPAR, Lit: ")", Err: nil},
+				{Tok: GTE, Lit: ">=", Err: nil},
+				{Tok: NUMBER, Lit: "3", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: GT, Lit: ">", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: LT, Lit: "<", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: LTE, Lit: "<=", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: EQ, Lit: "==", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: NEQ, Lit: "!=", Err: nil},
+				{Tok: EOF, Lit: "", Err: nil},
+			},
+			message: "count tokens",
+		},
+		{
+			expStr: `w"cat" W"dog"`,
+			expected: []expectedAtScan{
+				{Tok: WORD, Lit: "cat", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: WORD, Lit: "dog", Err: nil},
+				{Tok: EOF, Lit: "", Err: nil},
+			},
+			message: "word tokens",
+		},
+		{
+			expStr: `"refund*" "colo?r" "a\*b\?c\\" "a\**" r"a*" W"a?"`,
+			expected: []expectedAtScan{
+				{Tok: WILDCARD, Lit: "refund*", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: WILDCARD, Lit: "colo?r", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: KEYWORD, Lit: "a*b?c\\", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: WILDCARD, Lit: "a\\**", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: REGEX, Lit: "a*", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: WORD, Lit: "a?", Err: nil},
+				{Tok: EOF, Lit: "", Err: nil},
+			},
+			message: "wildcard tokens",
+		},
+		{
+			expStr: `f1"pasword" F12"a*b"`,
+			expected: []expectedAtScan{
+				{Tok: FUZZY, Lit: `1"pasword"`, Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: FUZZY, Lit: `12"a*b"`, Err: nil},
+				{Tok: EOF, Lit: "", Err: nil},
+			},
+			message: "fuzzy tokens",
+		},
+		{
+			expStr: `C"US" c"It"`,
+			expected: []expectedAtScan{
+				{Tok: CASE, Lit: "US", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: CASE, Lit: "It", Err: nil},
+				{Tok: EOF, Lit: "", Err: nil},
+			},
+			message: "case tokens",
+		},
+		{
+			expStr: `r"\\S+"/ims r"a"`,
+			expected: []expectedAtScan{
+				{Tok: REGEX, Lit: `\S+`, Err: nil},
+				{Tok: FLAGS, Lit: "ims", Err: nil},
+				{Tok: WS, Lit: " ", Err: nil},
+				{Tok: REGEX, Lit: "a", Err: nil},
+				{Tok: EOF, Lit: "", Err: nil},
+			},
+			message: "regex flags tokens",
+		},
+		{
+			expStr: `r"a"/ "b"`,
+			expected: []expectedAtScan{
+				{Tok: REGEX, Lit: "a", Err: nil},
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("fail to scan flags: expected the flags after /"),
+				},
+			},
+			message: "regex flags without letters",
+		},
+		{
+			expStr: `C"US`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("fail to scan case: expected \" but found EOF"),
+				},
+			},
+			message: "invalid case token",
+		},
+		{
+			expStr: `F"password"`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("fail to scan fuzzy: expected the distance after F"),
+				},
+			},
+			message: "fuzzy token without distance",
+		},
+		{
+			expStr: `F1 "password"`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("fail to scan fuzzy: expected \" but found  "),
+				},
+			},
+			message: "fuzzy token with space",
+		},
+		{
+			expStr: `r"a\*"`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("fail to scan regex: invalid escaped char *"),
+				},
+			},
+			message: "invalid escaped wildcard on regex",
+		},
+		{
+			expStr: `W"cat`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("fail to scan word: expected \" but found EOF"),
+				},
+			},
+			message: "invalid word token",
+		},
+		{
+			expStr: `= 3`,
+			expected: []expectedAtScan{
+				{
+					Tok: ILLEGAL,
+					Lit: "",
+					Err: fmt.Errorf("fail to scan comparison: invalid operator '='"),
+				},
+			},
+			message: "invalid comparison",
 		},
 	}
 
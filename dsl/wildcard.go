@@ -0,0 +1,195 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// wildcardKind defines the kind of a part of a wildcard pattern
+type wildcardKind int
+
+const (
+	literalPart  wildcardKind = iota
+	anyRunePart               // ?
+	anyRunesPart              // *
+)
+
+// wildcardPart is a part of a wildcard pattern
+type wildcardPart struct {
+	kind    wildcardKind
+	literal string
+}
+
+// Wildcard is a keyword with wildcards, where '?' matches exactly one word rune
+// and '*' matches zero or more word runes (see IsWordRune). The '*', '?' and '\'
+// characters can be escaped with '\' to be matched literally.
+// The longest literal part of the pattern is its anchor, that is searched by the
+// substring engines, and the rest of the pattern is verified around each anchor found.
+type Wildcard struct {
+	Pattern string
+	parts   []wildcardPart
+	anchor  int
+}
+
+// NewWildcard parses the pattern and returns a Wildcard.
+// Returns an error if the pattern has no literal part.
+func NewWildcard(pattern string) (*Wildcard, error) {
+	wc := &Wildcard{Pattern: pattern, anchor: -1}
+	var literal strings.Builder
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			wc.parts = append(wc.parts, wildcardPart{kind: literalPart, literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	escaped := false
+	for _, ch := range pattern {
+		switch {
+		case escaped:
+			literal.WriteRune(ch)
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == '?':
+			flushLiteral()
+			wc.parts = append(wc.parts, wildcardPart{kind: anyRunePart})
+		case ch == '*':
+			flushLiteral()
+			// consecutive '*' are the same as one
+			if len(wc.parts) == 0 || wc.parts[len(wc.parts)-1].kind != anyRunesPart {
+				wc.parts = append(wc.parts, wildcardPart{kind: anyRunesPart})
+			}
+		default:
+			literal.WriteRune(ch)
+		}
+	}
+	if escaped {
+		literal.WriteRune('\\')
+	}
+	flushLiteral()
+
+	for i, part := range wc.parts {
+		if part.kind != literalPart {
+			continue
+		}
+		if wc.anchor < 0 || len(part.literal) > len(wc.parts[wc.anchor].literal) {
+			wc.anchor = i
+		}
+	}
+	if wc.anchor < 0 {
+		return nil, fmt.Errorf("invalid wildcard '%s': the wildcard must have a literal part", pattern)
+	}
+	return wc, nil
+}
+
+// Anchor returns the literal part of the pattern that must be searched on the text.
+func (wc *Wildcard) Anchor() string {
+	return wc.parts[wc.anchor].literal
+}
+
+// MatchAt verifies if the pattern matches the text with its anchor at anchorPos,
+// returning the start and the end of the match. The '*' consume as many runes as possible.
+func (wc *Wildcard) MatchAt(text string, anchorPos int) (start int, end int, ok bool) {
+	anchor := wc.Anchor()
+	if anchorPos < 0 || !strings.HasPrefix(text[anchorPos:], anchor) {
+		return 0, 0, false
+	}
+	start, ok = matchBackward(wc.parts[:wc.anchor], text, anchorPos)
+	if !ok {
+		return 0, 0, false
+	}
+	end, ok = matchForward(wc.parts[wc.anchor+1:], text, anchorPos+len(anchor))
+	if !ok {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// matchForward matches the parts on the text starting at pos and returns the end of the match.
+func matchForward(parts []wildcardPart, text string, pos int) (int, bool) {
+	if len(parts) == 0 {
+		return pos, true
+	}
+
+	switch parts[0].kind {
+	case literalPart:
+		if !strings.HasPrefix(text[pos:], parts[0].literal) {
+			return 0, false
+		}
+		return matchForward(parts[1:], text, pos+len(parts[0].literal))
+
+	case anyRunePart:
+		ch, size := utf8.DecodeRuneInString(text[pos:])
+		if size == 0 || !IsWordRune(ch) {
+			return 0, false
+		}
+		return matchForward(parts[1:], text, pos+size)
+
+	default:
+		ends := []int{pos}
+		for end := pos; end < len(text); {
+			ch, size := utf8.DecodeRuneInString(text[end:])
+			if !IsWordRune(ch) {
+				break
+			}
+			end += size
+			ends = append(ends, end)
+		}
+		for i := len(ends) - 1; i >= 0; i-- {
+			if end, ok := matchForward(parts[1:], text, ends[i]); ok {
+				return end, true
+			}
+		}
+		return 0, false
+	}
+}
+
+// matchBackward matches the parts on the text ending at pos and returns the start of the match.
+func matchBackward(parts []wildcardPart, text string, pos int) (int, bool) {
+	if len(parts) == 0 {
+		return pos, true
+	}
+
+	last := parts[len(parts)-1]
+	rest := parts[:len(parts)-1]
+	switch last.kind {
+	case literalPart:
+		if !strings.HasSuffix(text[:pos], last.literal) {
+			return 0, false
+		}
+		return matchBackward(rest, text, pos-len(last.literal))
+
+	case anyRunePart:
+		ch, size := utf8.DecodeLastRuneInString(text[:pos])
+		if size == 0 || !IsWordRune(ch) {
+			return 0, false
+		}
+		return matchBackward(rest, text, pos-size)
+
+	default:
+		starts := []int{pos}
+		for start := pos; start > 0; {
+			ch, size := utf8.DecodeLastRuneInString(text[:start])
+			if !IsWordRune(ch) {
+				break
+			}
+			start -= size
+			starts = append(starts, start)
+		}
+		for i := len(starts) - 1; i >= 0; i-- {
+			if start, ok := matchBackward(rest, text, starts[i]); ok {
+				return start, true
+			}
+		}
+		return 0, false
+	}
+}
+
+// WildcardTermKey returns the key of the wildcard term on the map of matches
+// used to solve the expressions, that is the pattern enclosed by quotes after the
+// termKeyMark (see WordTermKey). Eg: "term*"
+func WildcardTermKey(pattern string) string {
+	return termKeyMark + `"` + pattern + `"`
+}
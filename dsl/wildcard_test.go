@@ -0,0 +1,133 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWildcard(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		pattern        string
+		text           string
+		anchorPos      int
+		expectedAnchor string
+		expectedStart  int
+		expectedEnd    int
+		expectedOk     bool
+		message        string
+	}{
+		{
+			pattern:        "refund*",
+			text:           "the refunded value",
+			anchorPos:      4,
+			expectedAnchor: "refund",
+			expectedStart:  4,
+			expectedEnd:    12,
+			expectedOk:     true,
+			message:        "suffix star",
+		},
+		{
+			pattern:        "refund*",
+			text:           "refund.",
+			anchorPos:      0,
+			expectedAnchor: "refund",
+			expectedStart:  0,
+			expectedEnd:    6,
+			expectedOk:     true,
+			message:        "star matches nothing",
+		},
+		{
+			pattern:        "colo?r",
+			text:           "the colour",
+			anchorPos:      4,
+			expectedAnchor: "colo",
+			expectedStart:  4,
+			expectedEnd:    10,
+			expectedOk:     true,
+			message:        "single rune",
+		},
+		{
+			pattern:        "colo?r",
+			text:           "the color",
+			anchorPos:      4,
+			expectedAnchor: "colo",
+			expectedOk:     false,
+			message:        "single rune is required",
+		},
+		{
+			pattern:        "?ção",
+			text:           "a ação",
+			anchorPos:      3,
+			expectedAnchor: "ção",
+			expectedStart:  2,
+			expectedEnd:    8,
+			expectedOk:     true,
+			message:        "multi byte runes",
+		},
+		{
+			pattern:        "*ing?",
+			text:           "testings",
+			anchorPos:      4,
+			expectedAnchor: "ing",
+			expectedStart:  0,
+			expectedEnd:    8,
+			expectedOk:     true,
+			message:        "prefix star",
+		},
+		{
+			pattern:        "a*b*c",
+			text:           "xaxxbxxbxcx",
+			anchorPos:      1,
+			expectedAnchor: "a",
+			expectedStart:  1,
+			expectedEnd:    10,
+			expectedOk:     true,
+			message:        "backtracking",
+		},
+		{
+			pattern:        "a*c",
+			text:           "ab c",
+			anchorPos:      0,
+			expectedAnchor: "a",
+			expectedOk:     false,
+			message:        "star does not match spaces",
+		},
+		{
+			pattern:        `x\*y*`,
+			text:           "x*yz",
+			anchorPos:      0,
+			expectedAnchor: "x*y",
+			expectedStart:  0,
+			expectedEnd:    4,
+			expectedOk:     true,
+			message:        "escaped star",
+		},
+		{
+			pattern:        "ab?cde",
+			text:           "abxcde",
+			anchorPos:      3,
+			expectedAnchor: "cde",
+			expectedStart:  0,
+			expectedEnd:    6,
+			expectedOk:     true,
+			message:        "longest literal is the anchor",
+		},
+	}
+
+	for _, tc := range tests {
+		wc, err := NewWildcard(tc.pattern)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedAnchor, wc.Anchor(), tc.message)
+		start, end, ok := wc.MatchAt(tc.text, tc.anchorPos)
+		assert.Equal(tc.expectedOk, ok, tc.message)
+		if ok {
+			assert.Equal(tc.expectedStart, start, tc.message)
+			assert.Equal(tc.expectedEnd, end, tc.message)
+		}
+	}
+
+	_, err := NewWildcard("*?*")
+	assert.NotNil(err)
+}
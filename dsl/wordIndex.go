@@ -0,0 +1,57 @@
+package dsl
+
+import (
+	"sort"
+	"unicode"
+)
+
+// WordIndex holds the offsets of the words of a text to convert the byte
+// offsets of the matches to word positions. A word is a sequence of word runes
+// (see IsWordRune).
+type WordIndex struct {
+	wordStarts []int
+	size       int
+	inWord     bool
+}
+
+// NewWordIndex returns a WordIndex of the text.
+func NewWordIndex(text string) *WordIndex {
+	wi := &WordIndex{}
+	wi.Append(text)
+	return wi
+}
+
+// Append adds the text to the end of the indexed text. It allows the text to be
+// indexed in chunks, as long as each chunk does not end in the middle of a rune.
+func (wi *WordIndex) Append(text string) {
+	for i, ch := range text {
+		isWordCh := IsWordRune(ch)
+		if isWordCh && !wi.inWord {
+			wi.wordStarts = append(wi.wordStarts, wi.size+i)
+		}
+		wi.inWord = isWordCh
+	}
+	wi.size += len(text)
+}
+
+// WordPosition returns the index of the word that contains the byte offset.
+// Offsets between two words return the index of the previous word and offsets
+// before the first word return -1.
+func (wi *WordIndex) WordPosition(offset int) int {
+	return sort.SearchInts(wi.wordStarts, offset+1) - 1
+}
+
+// convertPositions converts the sorted byte offsets to word positions
+func (wi *WordIndex) convertPositions(offsets []int) []int {
+	positions := make([]int, len(offsets))
+	for i, offset := range offsets {
+		positions[i] = wi.WordPosition(offset)
+	}
+	return positions
+}
+
+// IsWordRune returns true if the rune is part of a word,
+// that is a letter, a digit, a mark or '_'.
+func IsWordRune(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch) || unicode.IsMark(ch)
+}
@@ -23,6 +23,7 @@ func main() {
 	fmt.Printf("regexes:\n%v\n", regexes)
 
 	fmt.Printf("pretty format:\n%s\n", expression.PrettyFormat())
+	fmt.Printf("canonical: %s\n", expression.String())
 
 	matches := map[string][]int{
 		"foo":   {0, 2, 5},
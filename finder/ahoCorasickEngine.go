@@ -0,0 +1,523 @@
+package finder
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AhoCorasickMode defines which matches are reported by the AhoCorasickEngine.
+type AhoCorasickMode int
+
+const (
+	// OverlappingMatches reports every match of the terms, including the overlapping ones.
+	OverlappingMatches AhoCorasickMode = iota
+	// LeftmostLongestMatches reports the matches that do not overlap, choosing the match that
+	// starts first and, among the ones that start at the same position, the longest one.
+	LeftmostLongestMatches
+)
+
+// AhoCorasickEngine implements SubstringEngine with an Aho-Corasick automaton over the bytes of
+// the terms. The positions and the lengths of the matches are byte offsets on the searched text.
+// If the engine is not case sensitive the terms and the text are folded to lowercase while they
+// are searched, without copying the text, and the matches are reported on the original text.
+// It is the default engine of the finder (see NewFinder).
+type AhoCorasickEngine struct {
+	// Mode defines which matches are reported, OverlappingMatches by default.
+	Mode AhoCorasickMode
+	// automaton is the automaton of the last BuildEngine call. It is only read by the
+	// searches, so FindSubstrings is safe to be called from multiple goroutines at once.
+	automaton *acAutomaton
+}
+
+// acAutomaton is an Aho-Corasick automaton stored on flat slices. The state 0 is the root.
+type acAutomaton struct {
+	// root holds the transitions of the root by byte, since most bytes of a text
+	// go through it. The bytes without a transition go back to the root.
+	root [256]int32
+	// the transitions of the state s are edgeBytes[edgeStart[s]:edgeStart[s+1]],
+	// sorted by byte, to the states edgeNext[edgeStart[s]:edgeStart[s+1]].
+	edgeStart []int32
+	edgeBytes []byte
+	edgeNext  []int32
+	// fail is the state of the longest proper suffix of each state that is on the automaton.
+	fail []int32
+	// the terms that end on the state s are terms[termStart[s]:termStart[s+1]].
+	termStart []int32
+	terms     []int32
+	// output is the closest state on the fail chain of each state that ends
+	// a term, -1 if there is none.
+	output []int32
+	// dict holds the terms as they were given and keyLens the lengths of the keys
+	// of the automaton, that are the terms folded when the engine is not case sensitive.
+	dict    []string
+	keyLens []int32
+	// maxKeyLen is the length of the longest key.
+	maxKeyLen int
+	foldCase  bool
+}
+
+// BuildEngine implements BuildEngine. The terms are folded to lowercase if caseSensitive is not set.
+func (ace *AhoCorasickEngine) BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error) {
+	dict := make([]string, 0, len(keywords))
+	for key := range keywords {
+		dict = append(dict, key)
+	}
+	// the terms are sorted so the same terms always build the same automaton
+	sort.Strings(dict)
+	ace.automaton = newACAutomaton(dict, !caseSensitive)
+	return
+}
+
+// NewEngine implements ReplicableEngine. The new engine has the same Mode.
+func (ace *AhoCorasickEngine) NewEngine() interface{} {
+	return &AhoCorasickEngine{Mode: ace.Mode}
+}
+
+// Features implements FeaturesEngine. In the LeftmostLongestMatches mode the
+// overlapping matches are not reported and the text is not searched in chunks,
+// since the longest match of a position may end on the next chunks.
+func (ace *AhoCorasickEngine) Features() EngineFeatures {
+	if ace.Mode == LeftmostLongestMatches {
+		return PositionsFeature | CaseFoldingFeature
+	}
+	return PositionsFeature | OverlappingFeature | StreamingFeature | CaseFoldingFeature
+}
+
+// FindSubstrings implements FindSubstrings. The matches are sorted
+// by their end position, or by their position on the LeftmostLongestMatches mode.
+func (ace *AhoCorasickEngine) FindSubstrings(text string) (matches []*Match, err error) {
+	if ace.automaton == nil {
+		return
+	}
+	matches = ace.automaton.find(text)
+	if ace.Mode == LeftmostLongestMatches {
+		matches = leftmostLongest(matches)
+	}
+	return
+}
+
+// NewStream implements StreamSubstringEngine. The stream carries the state of the automaton
+// between the chunks and reports every match that ends on each chunk, including the
+// overlapping ones, on all the modes. If the engine is not case sensitive the chunks
+// must not split the runes of the text, as the chunks of the finder ProcessReader.
+func (ace *AhoCorasickEngine) NewStream() SubstringStream {
+	if ace.automaton == nil {
+		return &acStream{}
+	}
+	return &acStream{search: ace.automaton.newSearch()}
+}
+
+// acStream implements SubstringStream with the search of an acAutomaton.
+type acStream struct {
+	search acSearch
+}
+
+// FindSubstrings implements FindSubstrings of SubstringStream.
+func (acs *acStream) FindSubstrings(chunk string) (matches []*Match, err error) {
+	if acs.search.ac == nil {
+		return
+	}
+	return acs.search.next(chunk, nil), nil
+}
+
+// acTrieNode is a node of the trie that is compiled to an acAutomaton.
+type acTrieNode struct {
+	children map[byte]int32
+	terms    []int32
+}
+
+// newACAutomaton builds the automaton of the terms of dict.
+func newACAutomaton(dict []string, foldCase bool) *acAutomaton {
+	ac := &acAutomaton{dict: dict, keyLens: make([]int32, len(dict)), foldCase: foldCase}
+	trie := []acTrieNode{{}}
+	for i, term := range dict {
+		key := term
+		if foldCase {
+			key = foldKey(term)
+		}
+		ac.keyLens[i] = int32(len(key))
+		if len(key) > ac.maxKeyLen {
+			ac.maxKeyLen = len(key)
+		}
+		// empty terms would match everywhere, so they are never reported
+		if len(key) == 0 {
+			continue
+		}
+
+		state := int32(0)
+		for j := 0; j < len(key); j++ {
+			next, ok := trie[state].children[key[j]]
+			if !ok {
+				next = int32(len(trie))
+				trie = append(trie, acTrieNode{})
+				if trie[state].children == nil {
+					trie[state].children = make(map[byte]int32)
+				}
+				trie[state].children[key[j]] = next
+			}
+			state = next
+		}
+		trie[state].terms = append(trie[state].terms, int32(i))
+	}
+
+	ac.edgeStart = make([]int32, len(trie)+1)
+	ac.termStart = make([]int32, len(trie)+1)
+	for s, node := range trie {
+		ac.edgeStart[s+1] = ac.edgeStart[s] + int32(len(node.children))
+		ac.termStart[s+1] = ac.termStart[s] + int32(len(node.terms))
+		ac.terms = append(ac.terms, node.terms...)
+		bytes := make([]byte, 0, len(node.children))
+		for b := range node.children {
+			bytes = append(bytes, b)
+		}
+		sort.Slice(bytes, func(i, j int) bool { return bytes[i] < bytes[j] })
+		for _, b := range bytes {
+			ac.edgeBytes = append(ac.edgeBytes, b)
+			ac.edgeNext = append(ac.edgeNext, node.children[b])
+		}
+	}
+	for b := range ac.root {
+		ac.root[b] = ac.edge(0, byte(b))
+	}
+	ac.buildFailLinks()
+	return ac
+}
+
+// buildFailLinks sets the fail and output links of the states, visiting them in breadth-first
+// order so the links of the shorter states are known when the longer ones are visited.
+func (ac *acAutomaton) buildFailLinks() {
+	states := len(ac.edgeStart) - 1
+	ac.fail = make([]int32, states)
+	ac.output = make([]int32, states)
+	ac.output[0] = -1
+	queue := make([]int32, 0, states)
+	for e := ac.edgeStart[0]; e < ac.edgeStart[1]; e++ {
+		next := ac.edgeNext[e]
+		ac.output[next] = -1
+		queue = append(queue, next)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for e := ac.edgeStart[state]; e < ac.edgeStart[state+1]; e++ {
+			next := ac.edgeNext[e]
+			fail := ac.step(ac.fail[state], ac.edgeBytes[e])
+			ac.fail[next] = fail
+			if ac.termStart[fail] < ac.termStart[fail+1] {
+				ac.output[next] = fail
+			} else {
+				ac.output[next] = ac.output[fail]
+			}
+			queue = append(queue, next)
+		}
+	}
+}
+
+// edge returns the state of the transition of the state by the byte, -1 if there is none.
+func (ac *acAutomaton) edge(state int32, b byte) int32 {
+	lo, hi := int(ac.edgeStart[state]), int(ac.edgeStart[state+1])
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if ac.edgeBytes[mid] < b {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < int(ac.edgeStart[state+1]) && ac.edgeBytes[lo] == b {
+		return ac.edgeNext[lo]
+	}
+	return -1
+}
+
+// step returns the next state of the automaton after the byte.
+func (ac *acAutomaton) step(state int32, b byte) int32 {
+	for state != 0 {
+		if next := ac.edge(state, b); next >= 0 {
+			return next
+		}
+		state = ac.fail[state]
+	}
+	if next := ac.root[b]; next >= 0 {
+		return next
+	}
+	return 0
+}
+
+// find returns all the matches of the terms on the text.
+func (ac *acAutomaton) find(text string) []*Match {
+	search := ac.newSearch()
+	return search.next(text, nil)
+}
+
+// acSearch is the state of a search of the automaton on a text
+// that may be split in chunks, that are searched by next.
+type acSearch struct {
+	ac    *acAutomaton
+	state int32
+	// offset is the number of bytes of the text that were searched.
+	offset int
+	// starts holds the offset on the text of the rune of each of the last folded
+	// bytes, so the start of the matches can be found, and folded is the number
+	// of folded bytes that were searched. They are only used if the text is folded.
+	starts []int
+	folded int
+}
+
+// newSearch returns the state of a new search on the automaton.
+func (ac *acAutomaton) newSearch() acSearch {
+	search := acSearch{ac: ac}
+	if ac.foldCase {
+		search.starts = make([]int, ac.maxKeyLen+1)
+	}
+	return search
+}
+
+// next searches the next chunk of the text, appending the matches that end on it to matches.
+// The positions of the matches are offsets from the start of the text. If the text is folded
+// the chunks must not split its runes.
+func (search *acSearch) next(chunk string, matches []*Match) []*Match {
+	ac := search.ac
+	state := search.state
+	if !ac.foldCase {
+		for i := 0; i < len(chunk); i++ {
+			state = ac.step(state, chunk[i])
+			if ac.hasMatches(state) {
+				matches = ac.appendMatches(matches, state, search.offset+i+1, 0, nil)
+			}
+		}
+		search.state = state
+		search.offset += len(chunk)
+		return matches
+	}
+
+	starts := search.starts
+	folded := search.folded
+	var buf [utf8.UTFMax]byte
+	for i := 0; i < len(chunk); {
+		key, size := lowerRuneBytes(chunk[i:], &buf)
+		start := search.offset + i
+		end := start + size
+		for _, b := range key {
+			starts[folded%len(starts)] = start
+			folded++
+			state = ac.step(state, b)
+			if ac.hasMatches(state) {
+				matches = ac.appendMatches(matches, state, end, folded, starts)
+			}
+		}
+		i += size
+	}
+	search.state = state
+	search.folded = folded
+	search.offset += len(chunk)
+	return matches
+}
+
+// hasMatches returns true if a term ends on the state or on its fail chain.
+func (ac *acAutomaton) hasMatches(state int32) bool {
+	return ac.termStart[state] < ac.termStart[state+1] || ac.output[state] >= 0
+}
+
+// appendMatches appends the matches of the terms that end on the state, at the end offset
+// of the text. If starts is not nil the text is folded, folded is the number of folded
+// bytes that were searched and starts holds the offsets of the last ones (see find).
+func (ac *acAutomaton) appendMatches(matches []*Match, state int32, end int, folded int, starts []int) []*Match {
+	if ac.termStart[state] == ac.termStart[state+1] {
+		state = ac.output[state]
+	}
+	for ; state > 0; state = ac.output[state] {
+		for _, term := range ac.terms[ac.termStart[state]:ac.termStart[state+1]] {
+			start := end - int(ac.keyLens[term])
+			if starts != nil {
+				start = starts[(folded-int(ac.keyLens[term]))%len(starts)]
+			}
+			matches = append(matches, &Match{
+				Term:     ac.dict[term],
+				Position: start,
+				Length:   end - start,
+			})
+		}
+	}
+	return matches
+}
+
+// leftmostLongest returns the matches that do not overlap, choosing the match that starts first
+// and the longest one among the matches that start at the same position.
+func leftmostLongest(matches []*Match) []*Match {
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Position != matches[j].Position {
+			return matches[i].Position < matches[j].Position
+		}
+		return matches[i].Length > matches[j].Length
+	})
+	selected := matches[:0]
+	end := 0
+	for _, match := range matches {
+		if match.Position >= end {
+			selected = append(selected, match)
+			end = match.Position + match.Length
+		}
+	}
+	return selected
+}
+
+// lowerRuneBytes returns the bytes of the first rune of the text folded to lowercase and the size
+// of the rune on the text. The invalid UTF-8 bytes are returned as they are, one at a time.
+func lowerRuneBytes(text string, buf *[utf8.UTFMax]byte) (folded []byte, size int) {
+	if c := text[0]; c < utf8.RuneSelf {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		buf[0] = c
+		return buf[:1], 1
+	}
+	r, size := utf8.DecodeRuneInString(text)
+	if lower := unicode.ToLower(r); lower != r {
+		return buf[:utf8.EncodeRune(buf[:], lower)], size
+	}
+	return buf[:copy(buf[:], text[:size])], size
+}
+
+// foldKey returns the term folded to lowercase as the text is folded by the automaton.
+func foldKey(term string) string {
+	folded := make([]byte, 0, len(term))
+	var buf [utf8.UTFMax]byte
+	for i := 0; i < len(term); {
+		key, size := lowerRuneBytes(term[i:], &buf)
+		folded = append(folded, key...)
+		i += size
+	}
+	return string(folded)
+}
+
+// acEncodingVersion is the version of the encoding of the automaton of the AhoCorasickEngine.
+const acEncodingVersion = 1
+
+// MarshalBinary implements encoding.BinaryMarshaler, encoding the automaton of the last
+// BuildEngine call, so it can be loaded by UnmarshalBinary without being built again.
+// The Mode is not encoded, since the same automaton is searched on all modes.
+func (ace *AhoCorasickEngine) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	bw := &binaryWriter{w: &buf}
+	bw.writeUint(acEncodingVersion)
+	ac := ace.automaton
+	if ac == nil {
+		bw.writeBool(false)
+		return buf.Bytes(), bw.err
+	}
+	bw.writeBool(true)
+	bw.writeBool(ac.foldCase)
+	bw.writeStrings(ac.dict)
+	bw.writeInt32s(ac.keyLens)
+	bw.writeInt32s(ac.root[:])
+	bw.writeInt32s(ac.edgeStart)
+	bw.writeBytes(ac.edgeBytes)
+	bw.writeInt32s(ac.edgeNext)
+	bw.writeInt32s(ac.fail)
+	bw.writeInt32s(ac.termStart)
+	bw.writeInt32s(ac.terms)
+	bw.writeInt32s(ac.output)
+	return buf.Bytes(), bw.err
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, loading an automaton encoded
+// by MarshalBinary. Returns an error if the data is malformed.
+func (ace *AhoCorasickEngine) UnmarshalBinary(data []byte) error {
+	br := &binaryReader{r: bufio.NewReader(bytes.NewReader(data))}
+	version := br.readUint()
+	if br.err == nil && version != acEncodingVersion {
+		return fmt.Errorf("invalid aho-corasick automaton: version %d is not supported, expected version %d", version, acEncodingVersion)
+	}
+	if !br.readBool() {
+		if br.err != nil {
+			return br.fileError()
+		}
+		ace.automaton = nil
+		return nil
+	}
+	ac := &acAutomaton{}
+	ac.foldCase = br.readBool()
+	ac.dict = br.readStrings()
+	ac.keyLens = br.readInt32s()
+	root := br.readInt32s()
+	ac.edgeStart = br.readInt32s()
+	ac.edgeBytes = br.readBytes()
+	ac.edgeNext = br.readInt32s()
+	ac.fail = br.readInt32s()
+	ac.termStart = br.readInt32s()
+	ac.terms = br.readInt32s()
+	ac.output = br.readInt32s()
+	if br.err != nil {
+		return br.fileError()
+	}
+	if len(root) != len(ac.root) {
+		return errors.New("invalid aho-corasick automaton: inconsistent states")
+	}
+	copy(ac.root[:], root)
+	if !ac.valid() {
+		return errors.New("invalid aho-corasick automaton: inconsistent states")
+	}
+	for _, keyLen := range ac.keyLens {
+		if int(keyLen) > ac.maxKeyLen {
+			ac.maxKeyLen = int(keyLen)
+		}
+	}
+	ace.automaton = ac
+	return nil
+}
+
+// valid returns true if the slices of the automaton are consistent, so the
+// searches of a decoded automaton never index them out of their bounds.
+func (ac *acAutomaton) valid() bool {
+	states := len(ac.edgeStart) - 1
+	if states < 1 || len(ac.termStart) != states+1 || len(ac.fail) != states ||
+		len(ac.output) != states || len(ac.keyLens) != len(ac.dict) ||
+		len(ac.edgeBytes) != len(ac.edgeNext) {
+		return false
+	}
+	if !ascending(ac.edgeStart, len(ac.edgeNext)) || !ascending(ac.termStart, len(ac.terms)) ||
+		!inRange(ac.edgeNext, 0, states) || !inRange(ac.fail, 0, states) ||
+		!inRange(ac.output, -1, states) || !inRange(ac.terms, 0, len(ac.dict)) ||
+		!inRange(ac.root[:], -1, states) {
+		return false
+	}
+	for i, term := range ac.dict {
+		key := term
+		if ac.foldCase {
+			key = foldKey(term)
+		}
+		if int(ac.keyLens[i]) != len(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// ascending returns true if the offsets start at 0, never decrease and end at last.
+func ascending(offsets []int32, last int) bool {
+	if offsets[0] != 0 || int(offsets[len(offsets)-1]) != last {
+		return false
+	}
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i] < offsets[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// inRange returns true if all the values are at least min and lower than max.
+func inRange(values []int32, min int, max int) bool {
+	for _, v := range values {
+		if int(v) < min || int(v) >= max {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package finder
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bruteForceMatches returns every match of the keywords on the text, sorted by sortByEnd.
+func bruteForceMatches(text string, keywords []string) []*Match {
+	var matches []*Match
+	for _, key := range keywords {
+		for start := 0; start+len(key) <= len(text); start++ {
+			if key != "" && text[start:start+len(key)] == key {
+				matches = append(matches, &Match{Term: key, Position: start, Length: len(key)})
+			}
+		}
+	}
+	sortByEnd(matches)
+	return matches
+}
+
+// sortByEnd sorts the matches by their end position, then by their length and term.
+func sortByEnd(matches []*Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		iEnd, jEnd := matches[i].Position+matches[i].Length, matches[j].Position+matches[j].Length
+		if iEnd != jEnd {
+			return iEnd < jEnd
+		}
+		if matches[i].Length != matches[j].Length {
+			return matches[i].Length > matches[j].Length
+		}
+		return matches[i].Term < matches[j].Term
+	})
+}
+
+func TestAhoCorasickEngineOverlapping(t *testing.T) {
+	assert := assert.New(t)
+	rnd := rand.New(rand.NewSource(1))
+	alphabet := []byte("ab c")
+	randomString := func(maxLen int) string {
+		buf := make([]byte, 1+rnd.Intn(maxLen))
+		for i := range buf {
+			buf[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		return string(buf)
+	}
+
+	for i := 0; i < 300; i++ {
+		keywords := make(map[string]struct{})
+		for j := rnd.Intn(8); j >= 0; j-- {
+			keywords[randomString(5)] = struct{}{}
+		}
+		dict := make([]string, 0, len(keywords))
+		for key := range keywords {
+			dict = append(dict, key)
+		}
+		text := randomString(60)
+
+		eng := &AhoCorasickEngine{}
+		assert.Nil(eng.BuildEngine(keywords, true))
+		matches, err := eng.FindSubstrings(text)
+		assert.Nil(err)
+		sortByEnd(matches)
+		assert.Equal(bruteForceMatches(text, dict), matches, "%v on %q", dict, text)
+	}
+}
+
+func TestAhoCorasickEngineCaseFolding(t *testing.T) {
+	assert := assert.New(t)
+	text := "ÇÃ Foo AÇÃO bar ÇÃ"
+	keywords := map[string]struct{}{"ação": {}, "BAR": {}, "çã": {}}
+	expected := []*Match{
+		{Position: 0, Length: 4, Term: "çã"},
+		{Position: 10, Length: 4, Term: "çã"},
+		{Position: 9, Length: 6, Term: "ação"},
+		{Position: 16, Length: 3, Term: "BAR"},
+		{Position: 20, Length: 4, Term: "çã"},
+	}
+
+	eng := &AhoCorasickEngine{}
+	assert.Nil(eng.BuildEngine(keywords, false))
+	matches, err := eng.FindSubstrings(text)
+	assert.Nil(err)
+	assert.Equal(expected, matches)
+
+	// the folded forms with a different length are mapped to the original text
+	assert.Nil(eng.BuildEngine(map[string]struct{}{"ik": {}}, false))
+	matches, err = eng.FindSubstrings("xIK")
+	assert.Nil(err)
+	assert.Equal([]*Match{{Position: 1, Length: 4, Term: "ik"}}, matches)
+
+	// the case sensitive engine does not fold the text
+	assert.Nil(eng.BuildEngine(keywords, true))
+	matches, err = eng.FindSubstrings(text)
+	assert.Nil(err)
+	assert.Empty(matches)
+}
+
+func TestAhoCorasickEngineLeftmostLongest(t *testing.T) {
+	assert := assert.New(t)
+	keywords := map[string]struct{}{"abc": {}, "ab": {}, "bcd": {}, "cd": {}, "d": {}}
+	eng := &AhoCorasickEngine{Mode: LeftmostLongestMatches}
+	assert.Nil(eng.BuildEngine(keywords, true))
+	matches, err := eng.FindSubstrings("xabcdxabd")
+	assert.Nil(err)
+	assert.Equal([]*Match{
+		{Position: 1, Length: 3, Term: "abc"},
+		{Position: 4, Length: 1, Term: "d"},
+		{Position: 6, Length: 2, Term: "ab"},
+		{Position: 8, Length: 1, Term: "d"},
+	}, matches)
+	assert.False(eng.Features().Has(OverlappingFeature))
+}
+
+func TestAhoCorasickEngineStream(t *testing.T) {
+	assert := assert.New(t)
+	rnd := rand.New(rand.NewSource(1))
+	text := "ÇÃ Foo AÇÃO bar ÇÃ xIK ação foo barfoo"
+	keywords := map[string]struct{}{"ação": {}, "BAR": {}, "çã": {}, "ik": {}, "foo": {}, "o ba": {}}
+
+	for _, caseSensitive := range []bool{true, false} {
+		eng := &AhoCorasickEngine{}
+		assert.Nil(eng.BuildEngine(keywords, caseSensitive))
+		expected, err := eng.FindSubstrings(text)
+		assert.Nil(err)
+		for i := 0; i < 50; i++ {
+			// the chunks are split between the runes, as the chunks of ProcessReader
+			var matches []*Match
+			stream := eng.NewStream()
+			for start := 0; start < len(text); {
+				end := start + 1 + rnd.Intn(6)
+				for end < len(text) && !utf8.RuneStart(text[end]) {
+					end++
+				}
+				if end > len(text) {
+					end = len(text)
+				}
+				chunkMatches, err := stream.FindSubstrings(text[start:end])
+				assert.Nil(err)
+				matches = append(matches, chunkMatches...)
+				start = end
+			}
+			assert.Equal(expected, matches, "case sensitive: %v", caseSensitive)
+		}
+	}
+
+	matches, err := (&AhoCorasickEngine{}).NewStream().FindSubstrings("foo")
+	assert.Nil(err)
+	assert.Empty(matches)
+}
+
+func TestAhoCorasickEngineEmpty(t *testing.T) {
+	assert := assert.New(t)
+	eng := &AhoCorasickEngine{}
+	matches, err := eng.FindSubstrings("foo")
+	assert.Nil(err)
+	assert.Empty(matches)
+
+	assert.Nil(eng.BuildEngine(map[string]struct{}{"": {}}, false))
+	matches, err = eng.FindSubstrings("foo")
+	assert.Nil(err)
+	assert.Empty(matches)
+}
+
+func TestNewFinderDefaultEngine(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(nil, &RegexpEngine{}, false)
+	assert.Nil(findthem.AddExpression(`INORD("foo" and "bar") and COUNT("o") == 2`))
+	expRes, err := findthem.ProcessText(strings.Repeat("x", 3) + "FOO BAR")
+	assert.Nil(err)
+	assert.Len(expRes, 1)
+}
@@ -0,0 +1,79 @@
+package finder
+
+import (
+	"context"
+
+	"github.com/pedroegsilva/gofindthem/internal/batch"
+)
+
+// BatchResult holds the result of a single text of a batch.
+// Index is the position of the text on the input and Err is the error
+// that happened while processing that text, if any.
+type BatchResult struct {
+	Index   int
+	Results []ExpressionResult
+	Err     error
+}
+
+// ProcessBatch processes the texts using the given number of workers and returns
+// one BatchResult for each text, ordered by the index of the text. An error on a
+// text does not stop the processing of the others, it is set on its BatchResult.
+// If workers is lower than 1, a single worker is used.
+func (finder *Finder) ProcessBatch(texts []string, workers int) []BatchResult {
+	input := make(chan string)
+	go func() {
+		for _, text := range texts {
+			input <- text
+		}
+		close(input)
+	}()
+
+	results := make([]BatchResult, len(texts))
+	for res := range finder.ProcessChannel(context.Background(), input, workers, false) {
+		results[res.Index] = res
+	}
+	return results
+}
+
+// ProcessChannel processes the texts received from the channel using the given number of
+// workers and sends a BatchResult for each text on the returned channel. Index is the order
+// in which the text was received. If preserveOrder is set the results are sent in the same
+// order as the texts were received, otherwise they are sent as soon as they are ready.
+// The returned channel is closed after the texts channel is closed and all texts are processed.
+// If the consumer stops reading the results it must cancel the context, that stops the workers
+// and closes the returned channel. The texts are processed with ProcessTextContext.
+// An error on a text does not stop the processing of the others, it is set on its BatchResult.
+// If workers is lower than 1, a single worker is used.
+func (finder *Finder) ProcessChannel(
+	ctx context.Context,
+	texts <-chan string,
+	workers int,
+	preserveOrder bool,
+) <-chan BatchResult {
+	next := func(ctx context.Context) (interface{}, bool) {
+		select {
+		case text, ok := <-texts:
+			return text, ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	process := func(text interface{}) interface{} {
+		expRes, err := finder.ProcessTextContext(ctx, text.(string))
+		return BatchResult{Results: expRes, Err: err}
+	}
+
+	results := make(chan BatchResult)
+	go func() {
+		defer close(results)
+		for res := range batch.Process(ctx, workers, preserveOrder, next, process) {
+			batchRes := res.Value.(BatchResult)
+			batchRes.Index = res.Index
+			select {
+			case results <- batchRes:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return results
+}
@@ -0,0 +1,118 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBatchTestFinder(assert *assert.Assertions) *Finder {
+	subMock := new(SubstringEngineMock)
+	subMock.On("BuildEngine", map[string]struct{}{"foo": {}}).Return(nil)
+	subMock.On("FindSubstrings", "foo").Return([]*Match{{Position: 0, Length: 3, Term: "foo"}}, nil)
+	subMock.On("FindSubstrings", "bar").Return([]*Match{}, nil)
+	subMock.On("FindSubstrings", "bad").Return([]*Match{}, fmt.Errorf("error on bad"))
+
+	findthem := NewFinder(subMock, &EmptyRgxEngine{}, true)
+	assert.Nil(findthem.AddExpressionWithTag(`"foo"`, "tag"))
+	return findthem
+}
+
+func TestProcessBatch(t *testing.T) {
+	assert := assert.New(t)
+	findthem := newBatchTestFinder(assert)
+	fooRes := []ExpressionResult{{ExpresionIndex: 0, ExpresionStr: `"foo"`, Tag: "tag", Version: 1}}
+
+	texts := []string{"foo", "bar", "bad", "foo", "bar"}
+	expected := []BatchResult{
+		{Index: 0, Results: fooRes},
+		{Index: 1, Results: []ExpressionResult{}},
+		{Index: 2, Err: fmt.Errorf("error on bad")},
+		{Index: 3, Results: fooRes},
+		{Index: 4, Results: []ExpressionResult{}},
+	}
+
+	for _, workers := range []int{-1, 0, 1, 3, 10} {
+		message := fmt.Sprintf("%d workers", workers)
+		assert.Equal(expected, findthem.ProcessBatch(texts, workers), message)
+	}
+	assert.Equal([]BatchResult{}, findthem.ProcessBatch(nil, 2), "empty batch")
+}
+
+func TestProcessChannel(t *testing.T) {
+	assert := assert.New(t)
+	findthem := newBatchTestFinder(assert)
+
+	numTexts := 100
+	for _, preserveOrder := range []bool{true, false} {
+		message := fmt.Sprintf("preserve order %v", preserveOrder)
+		texts := make(chan string)
+		go func() {
+			for i := 0; i < numTexts; i++ {
+				switch i % 3 {
+				case 0:
+					texts <- "foo"
+				case 1:
+					texts <- "bar"
+				default:
+					texts <- "bad"
+				}
+			}
+			close(texts)
+		}()
+
+		seen := make(map[int]struct{})
+		count := 0
+		for res := range findthem.ProcessChannel(context.Background(), texts, 4, preserveOrder) {
+			if preserveOrder {
+				assert.Equal(count, res.Index, message)
+			}
+			seen[res.Index] = struct{}{}
+			count++
+
+			switch res.Index % 3 {
+			case 0:
+				assert.Nil(res.Err, message)
+				assert.Len(res.Results, 1, message)
+			case 1:
+				assert.Nil(res.Err, message)
+				assert.Empty(res.Results, message)
+			default:
+				assert.Equal(fmt.Errorf("error on bad"), res.Err, message)
+			}
+		}
+		assert.Equal(numTexts, count, message)
+		assert.Len(seen, numTexts, message)
+	}
+}
+
+func TestProcessChannelCancel(t *testing.T) {
+	assert := assert.New(t)
+	findthem := newBatchTestFinder(assert)
+
+	for _, preserveOrder := range []bool{true, false} {
+		message := fmt.Sprintf("preserve order %v", preserveOrder)
+		ctx, cancel := context.WithCancel(context.Background())
+		texts := make(chan string)
+		// the texts channel is never closed, so the results channel
+		// is only closed because the context was canceled
+		go func() {
+			for {
+				select {
+				case texts <- "foo":
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		results := findthem.ProcessChannel(ctx, texts, 4, preserveOrder)
+		res := <-results
+		assert.Nil(res.Err, message)
+		cancel()
+		for range results {
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package finder
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+)
+
+// addCaseTerms increments the reference count of the case-sensitive terms
+// and adds the new ones to the terms that are compared with the original text.
+// caseKeywords maps the case-sensitive terms to the keywords searched for them.
+func (finder *Finder) addCaseTerms(caseKeywords map[string]string) {
+	if len(caseKeywords) == 0 {
+		return
+	}
+	if finder.caseKeywords == nil {
+		finder.caseKeywords = make(map[string]string)
+	}
+	if finder.caseKeywordsRefCount == nil {
+		finder.caseKeywordsRefCount = make(map[string]int)
+	}
+
+	for term, keyword := range caseKeywords {
+		finder.caseKeywordsRefCount[term]++
+		finder.caseKeywords[term] = keyword
+	}
+	finder.indexCaseTerms()
+}
+
+// removeCaseTerms decrements the reference count of the case-sensitive
+// terms and removes the ones that are no longer used.
+func (finder *Finder) removeCaseTerms(caseKeywords map[string]string) {
+	if len(caseKeywords) == 0 {
+		return
+	}
+	for term := range caseKeywords {
+		finder.caseKeywordsRefCount[term]--
+		if finder.caseKeywordsRefCount[term] <= 0 {
+			delete(finder.caseKeywordsRefCount, term)
+			delete(finder.caseKeywords, term)
+		}
+	}
+	finder.indexCaseTerms()
+}
+
+// indexCaseTerms groups the case-sensitive terms by their keywords.
+func (finder *Finder) indexCaseTerms() {
+	finder.caseTermsByKeyword = make(map[string][]string)
+	for term, keyword := range finder.caseKeywords {
+		finder.caseTermsByKeyword[keyword] = append(finder.caseTermsByKeyword[keyword], term)
+	}
+	for _, terms := range finder.caseTermsByKeyword {
+		sort.Strings(terms)
+	}
+}
+
+// isCaseTermKey returns true if the term is the dsl.CaseTermKey of a case-sensitive
+// term, that must not be changed to lowercase.
+func (finder *Finder) isCaseTermKey(term string) bool {
+	if len(finder.caseKeywords) == 0 {
+		return false
+	}
+	caseTerm, ok := dsl.CaseTermOfKey(term)
+	if !ok {
+		return false
+	}
+	_, ok = finder.caseKeywords[caseTerm]
+	return ok
+}
+
+// filterCaseMatches returns the matches of the case-sensitive terms, that are the matches of
+// their keywords whose text on the original text is equal to the term. The normalization,
+// without the case folding, is applied to the original text before the comparison.
+// The returned matches have the dsl.CaseTermKey of the term as Term and keep the positions of
+// the keyword matches. origOffset is the position of the first byte of original on the original
+// text and positions maps the positions of the matches to the original text, if it was normalized.
+func (finder *Finder) filterCaseMatches(
+	matches []*Match,
+	original string,
+	origOffset int,
+	positions *dsl.PositionMap,
+) []*Match {
+	caseMatches := make([]*Match, 0)
+	for _, match := range matches {
+		term := match.Term
+		if !finder.caseSensitive {
+			term = strings.ToLower(term)
+		}
+		caseTerms, ok := finder.caseTermsByKeyword[term]
+		if !ok {
+			continue
+		}
+
+		start, end := match.Position, match.Position+match.Length
+		if positions != nil {
+			start, end = positions.Original(start, end)
+		}
+		start -= origOffset
+		end -= origOffset
+		if start < 0 || end > len(original) {
+			continue
+		}
+		text := original[start:end]
+		if finder.caseNormalizer != nil {
+			text = finder.caseNormalizer.NormalizeString(text)
+		}
+
+		for _, caseTerm := range caseTerms {
+			if text == caseTerm {
+				caseMatches = append(caseMatches, &Match{
+					Position: match.Position,
+					Length:   match.Length,
+					Term:     dsl.CaseTermKey(caseTerm),
+				})
+			}
+		}
+	}
+	return caseMatches
+}
+
+// streamCaseFilter filters the matches of the case-sensitive terms on a text that is processed
+// in chunks. It keeps the bytes of the original text where the next matches can start.
+type streamCaseFilter struct {
+	finder *Finder
+	text   string
+	offset int
+	// longest is the length of the longest keyword of the case-sensitive terms.
+	longest int
+}
+
+// newStreamCaseFilter returns a streamCaseFilter for the case-sensitive terms of the finder.
+func (finder *Finder) newStreamCaseFilter() *streamCaseFilter {
+	longest := 0
+	for keyword := range finder.caseTermsByKeyword {
+		if len(keyword) > longest {
+			longest = len(keyword)
+		}
+	}
+	return &streamCaseFilter{finder: finder, longest: longest}
+}
+
+// filter appends the original chunk to the text and returns the matches of the case-sensitive
+// terms of the given keyword matches. The positions of the matches must be offsets from the
+// start of the searched text and searchedEnd is the end of the text that was already searched.
+// positions maps the searched text to the original text, if it was normalized.
+func (scf *streamCaseFilter) filter(
+	chunk string,
+	matches []*Match,
+	searchedEnd int,
+	positions *dsl.PositionMap,
+) []*Match {
+	scf.text += chunk
+	caseMatches := scf.finder.filterCaseMatches(matches, scf.text, scf.offset, positions)
+
+	// the next matches end after searchedEnd
+	cut := searchedEnd - scf.longest
+	if positions != nil {
+		cut, _ = positions.Original(cut, cut)
+	}
+	cut -= scf.offset
+	if cut > len(scf.text) {
+		cut = len(scf.text)
+	}
+	if cut > 0 {
+		scf.offset += cut
+		scf.text = scf.text[cut:]
+	}
+	return caseMatches
+}
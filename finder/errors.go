@@ -0,0 +1,53 @@
+package finder
+
+import "fmt"
+
+// Stages of the processing of a text that are reported by PartialResultError.
+const (
+	SubstringSearchStage = "substring search"
+	RegexSearchStage     = "regex search"
+	FuzzySearchStage     = "fuzzy search"
+	ExpressionsStage     = "expressions"
+)
+
+// PartialResultError is returned when the processing of a text is stopped
+// by its context. Stage is the processing stage that was not completed and
+// Processed is how many of the Total expressions were evaluated before the stop.
+// Err is the error of the context.
+type PartialResultError struct {
+	Stage     string
+	Processed int
+	Total     int
+	Err       error
+}
+
+// Error implements the error interface
+func (pre *PartialResultError) Error() string {
+	return fmt.Sprintf(
+		"processing stopped at %s stage after evaluating %d of %d expressions: %v",
+		pre.Stage,
+		pre.Processed,
+		pre.Total,
+		pre.Err,
+	)
+}
+
+// Unwrap returns the error of the context
+func (pre *PartialResultError) Unwrap() error {
+	return pre.Err
+}
+
+// UnsupportedFeatureError is returned when an expression or an operation of the finder
+// needs features that its engine does not support (see FeaturesEngine).
+// Subject is what needs the features, Engine is the engine that lacks them
+// and Missing are the features that are not supported.
+type UnsupportedFeatureError struct {
+	Subject string
+	Engine  string
+	Missing EngineFeatures
+}
+
+// Error implements the error interface
+func (ufe *UnsupportedFeatureError) Error() string {
+	return fmt.Sprintf("%s needs the %s to support %s", ufe.Subject, ufe.Engine, ufe.Missing)
+}
@@ -0,0 +1,167 @@
+package finder
+
+import (
+	"strings"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+)
+
+// EngineFeatures is a set of features that a substring or regex engine supports.
+type EngineFeatures uint
+
+const (
+	// PositionsFeature is set if the engine reports the positions of the matches.
+	// The positions are needed by the INORD, NEAR and ONEAR operators, by the whole word,
+	// wildcard and case-sensitive terms and by ProcessReader.
+	PositionsFeature EngineFeatures = 1 << iota
+	// OverlappingFeature is set if the engine reports every match of the terms,
+	// including the repeated and the overlapping ones, that are counted by COUNT.
+	OverlappingFeature
+	// StreamingFeature is set if the engine is able to search a text split in chunks
+	// carrying the state of the search between them (see StreamSubstringEngine).
+	// ProcessReader only uses the streams of the engines that support it.
+	StreamingFeature
+	// CaseFoldingFeature is set if the engine folds the case of the terms it builds and of
+	// the texts it searches when the finder is not case sensitive. The regex engine must fold
+	// the case of the regexes, since they are kept as they were written. The text is only
+	// changed to lowercase if one of the engines that search it does not report the feature
+	// on its Features method, or if there are wildcard or fuzzy terms (see Finder.ProcessText).
+	CaseFoldingFeature
+)
+
+// defaultFeatures are the features of the engines that do not implement FeaturesEngine.
+const defaultFeatures = PositionsFeature | OverlappingFeature | CaseFoldingFeature
+
+// featureNames are the readable names of the features.
+var featureNames = []struct {
+	feature EngineFeatures
+	name    string
+}{
+	{PositionsFeature, "positions"},
+	{OverlappingFeature, "overlapping matches"},
+	{StreamingFeature, "streaming"},
+	{CaseFoldingFeature, "case folding"},
+}
+
+// Has returns true if all the given features are on the set.
+func (ef EngineFeatures) Has(features EngineFeatures) bool {
+	return ef&features == features
+}
+
+// String returns the names of the features on the set. Eg: "positions, case folding"
+func (ef EngineFeatures) String() string {
+	var names []string
+	for _, fn := range featureNames {
+		if ef.Has(fn.feature) {
+			names = append(names, fn.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// FeaturesEngine is implemented by the substring and regex engines that report the
+// features they support. The finder checks the features when an expression is added,
+// failing with an *UnsupportedFeatureError if the expression needs a missing feature.
+// Engines that do not implement it are assumed to support positions, overlapping
+// matches and case folding, and streaming if they implement StreamSubstringEngine.
+type FeaturesEngine interface {
+	Features() EngineFeatures
+}
+
+// engineFeatures returns the features supported by the engine. The engines that do not
+// implement FeaturesEngine support streaming if they implement StreamSubstringEngine.
+func engineFeatures(engine interface{}) EngineFeatures {
+	if featEng, ok := engine.(FeaturesEngine); ok {
+		return featEng.Features()
+	}
+	features := defaultFeatures
+	if _, ok := engine.(StreamSubstringEngine); ok {
+		features |= StreamingFeature
+	}
+	return features
+}
+
+// foldsText returns true if the engine reports on its Features method that it
+// folds the case of the texts, so they do not need to be changed to lowercase.
+func foldsText(engine interface{}) bool {
+	featEng, ok := engine.(FeaturesEngine)
+	return ok && featEng.Features().Has(CaseFoldingFeature)
+}
+
+// needsLowercaseText returns true if the text must be changed to lowercase before it is searched,
+// that is when the finder is not case sensitive and the text is not normalized, unless all the
+// engines that search it fold its case. The wildcard and fuzzy terms are compared to the lowercase text.
+func (finder *Finder) needsLowercaseText() bool {
+	if finder.caseSensitive || finder.normalizer != nil {
+		return false
+	}
+	if len(finder.wildcards) > 0 || len(finder.fuzzyTerms) > 0 {
+		return true
+	}
+	if (len(finder.keywords) > 0 || finder.usesRegexPrefilter()) && !foldsText(finder.subEng) {
+		return true
+	}
+	return len(finder.regexes) > 0 && !foldsText(finder.rgxEng)
+}
+
+// requiredFeatures holds the features that an expression needs from each engine.
+type requiredFeatures struct {
+	substring EngineFeatures
+	regex     EngineFeatures
+}
+
+// requiredFeatures returns the features that the parsed expression needs from the engines.
+func (finder *Finder) requiredFeatures(exp *dsl.Expression, p *dsl.Parser) requiredFeatures {
+	var req requiredFeatures
+	if len(p.GetWordKeywords()) > 0 || len(p.GetWildcards()) > 0 || len(p.GetCaseKeywords()) > 0 {
+		req.substring |= PositionsFeature
+	}
+	if len(p.GetRegexes()) > 0 && !finder.caseSensitive {
+		req.regex |= CaseFoldingFeature
+	}
+	collectRequiredFeatures(exp, p, 0, &req)
+	return req
+}
+
+// collectRequiredFeatures adds to req the features that the terms of the expression need
+// from their engines. The features are the ones needed by the operators that enclose the terms.
+func collectRequiredFeatures(exp *dsl.Expression, p *dsl.Parser, features EngineFeatures, req *requiredFeatures) {
+	if exp == nil {
+		return
+	}
+	switch exp.Type {
+	case dsl.UNIT_EXPR:
+		if _, ok := p.GetRegexes()[exp.Literal]; ok {
+			// the regex matches are counted as the regex engine reports them
+			req.regex |= features &^ OverlappingFeature
+			return
+		}
+		if _, ok := p.GetFuzzyTerms()[exp.Literal]; ok {
+			return
+		}
+		req.substring |= features
+		return
+	case dsl.INORD_EXPR, dsl.NEAR_EXPR, dsl.ONEAR_EXPR:
+		features |= PositionsFeature
+	case dsl.COUNT_EXPR:
+		features |= OverlappingFeature
+	}
+	collectRequiredFeatures(exp.LExpr, p, features, req)
+	collectRequiredFeatures(exp.RExpr, p, features, req)
+	for _, operand := range exp.Operands {
+		collectRequiredFeatures(operand, p, features, req)
+	}
+}
+
+// checkFeatures returns an *UnsupportedFeatureError if the parsed expression
+// needs a feature that the engines of the finder do not support.
+func (finder *Finder) checkFeatures(expression string, exp *dsl.Expression, p *dsl.Parser) error {
+	req := finder.requiredFeatures(exp, p)
+	if missing := req.substring &^ engineFeatures(finder.subEng); missing != 0 {
+		return &UnsupportedFeatureError{Subject: "expression '" + expression + "'", Engine: "substring engine", Missing: missing}
+	}
+	if missing := req.regex &^ engineFeatures(finder.rgxEng); missing != 0 {
+		return &UnsupportedFeatureError{Subject: "expression '" + expression + "'", Engine: "regex engine", Missing: missing}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package finder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// positionsRegexEngine is a RegexEngine that reports positions but does not fold case.
+type positionsRegexEngine struct {
+	RegexpEngine
+}
+
+func (pre *positionsRegexEngine) Features() EngineFeatures {
+	return PositionsFeature
+}
+
+// featurelessStreamEngine implements StreamSubstringEngine but not FeaturesEngine.
+type featurelessStreamEngine struct {
+	EmptyEngine
+}
+
+func (eng *featurelessStreamEngine) NewStream() SubstringStream {
+	return nil
+}
+
+func TestEngineFeatures(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(PositionsFeature|OverlappingFeature, engineFeatures(&AnknownEngine{}))
+	assert.Equal(EngineFeatures(0), engineFeatures(&CloudflareEngine{}))
+	assert.Equal(defaultFeatures, engineFeatures(&EmptyEngine{}))
+	assert.Equal(PositionsFeature|OverlappingFeature|StreamingFeature, engineFeatures(&fullTextStreamEngine{}))
+	assert.Equal(PositionsFeature|OverlappingFeature, engineFeatures(&unusedStreamEngine{}))
+	assert.Equal(defaultFeatures|StreamingFeature, engineFeatures(&featurelessStreamEngine{}))
+	assert.Equal(PositionsFeature|CaseFoldingFeature, engineFeatures(NewPrefilterRegexEngine(nil)))
+	assert.Equal(defaultFeatures|StreamingFeature, engineFeatures(&AhoCorasickEngine{}))
+	assert.Equal(PositionsFeature|CaseFoldingFeature, engineFeatures(&AhoCorasickEngine{Mode: LeftmostLongestMatches}))
+	assert.Equal("positions, case folding", (PositionsFeature | CaseFoldingFeature).String())
+}
+
+func TestAddExpressionUnsupportedFeatures(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		expression string
+		rgxEng     RegexEngine
+		expected   *UnsupportedFeatureError
+		message    string
+	}{
+		{
+			expression: `"a" and ("b" or not "c")`,
+			rgxEng:     &RegexpEngine{},
+			message:    "logical operators",
+		},
+		{
+			expression: `INORD("a" and "b")`,
+			rgxEng:     &RegexpEngine{},
+			expected: &UnsupportedFeatureError{
+				Subject: `expression 'INORD("a" and "b")'`,
+				Engine:  "substring engine",
+				Missing: PositionsFeature,
+			},
+			message: "inord",
+		},
+		{
+			expression: `"a" NEAR/3 "b"`,
+			rgxEng:     &RegexpEngine{},
+			expected: &UnsupportedFeatureError{
+				Subject: `expression '"a" NEAR/3 "b"'`,
+				Engine:  "substring engine",
+				Missing: PositionsFeature,
+			},
+			message: "near",
+		},
+		{
+			expression: `COUNT("a") > 2`,
+			rgxEng:     &RegexpEngine{},
+			expected: &UnsupportedFeatureError{
+				Subject: `expression 'COUNT("a") > 2'`,
+				Engine:  "substring engine",
+				Missing: OverlappingFeature,
+			},
+			message: "count",
+		},
+		{
+			expression: `W"a" or "b"`,
+			rgxEng:     &RegexpEngine{},
+			expected: &UnsupportedFeatureError{
+				Subject: `expression 'W"a" or "b"'`,
+				Engine:  "substring engine",
+				Missing: PositionsFeature,
+			},
+			message: "whole word",
+		},
+		{
+			expression: `INORD(R"a+" and F1"bbb") or COUNT(R"c") > 1`,
+			rgxEng:     &RegexpEngine{},
+			message:    "regex and fuzzy terms",
+		},
+		{
+			expression: `R"Foo" and "bar"`,
+			rgxEng:     &positionsRegexEngine{},
+			expected: &UnsupportedFeatureError{
+				Subject: `expression 'R"Foo" and "bar"'`,
+				Engine:  "regex engine",
+				Missing: CaseFoldingFeature,
+			},
+			message: "regex case folding",
+		},
+	}
+
+	for _, tc := range tests {
+		findthem := NewFinder(&CloudflareEngine{}, tc.rgxEng, false)
+		err := findthem.AddExpression(tc.expression)
+		if tc.expected == nil {
+			assert.Nil(err, tc.message)
+			continue
+		}
+		assert.Equal(tc.expected, err, tc.message)
+		assert.Empty(findthem.GetExpressions(), tc.message)
+	}
+}
+
+func TestUpdateExpressionUnsupportedFeatures(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareEngine{}, &RegexpEngine{}, false)
+	assert.Nil(findthem.AddExpression(`"a" and "b"`))
+
+	err := findthem.UpdateExpression(0, `INORD("a" and "b")`, "")
+	assert.IsType(&UnsupportedFeatureError{}, err)
+	info, err := findthem.GetExpression(0)
+	assert.Nil(err)
+	assert.Equal(`"a" and "b"`, info.Expression)
+}
+
+func TestProcessReaderUnsupportedFeatures(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareEngine{}, &RegexpEngine{}, false)
+	assert.Nil(findthem.AddExpression(`"a" and "b"`))
+
+	_, err := findthem.ProcessReader(strings.NewReader("a b"))
+	assert.Equal(&UnsupportedFeatureError{
+		Subject: "ProcessReader",
+		Engine:  "substring engine",
+		Missing: PositionsFeature,
+	}, err)
+	assert.EqualError(err, "ProcessReader needs the substring engine to support positions")
+}
@@ -1,240 +1,1125 @@
-package finder
-
-import (
-	"strings"
-
-	"github.com/pedroegsilva/gofindthem/dsl"
-)
-
-// Match holds the maching position
-// and with substring that was found
-type Match struct {
-	Position int
-	Term     string
-}
-
-// exprWrapper store the expression as a string and
-// the SolverOrder to later solve the expression.
-type exprWrapper struct {
-	exprString string
-	expression *dsl.Expression
-	tag        string
-}
-
-// ExpressionResult
-type ExpressionResult struct {
-	ExpresionIndex int
-	ExpresionStr   string
-	Tag            string
-}
-
-// Finder stores the needed information to find the terms and solve the expressions
-type Finder struct {
-	expressions       []exprWrapper
-	keywords          map[string]struct{}
-	regexes           map[string]struct{}
-	subEng            SubstringEngine
-	rgxEng            RegexEngine
-	updatedSubMachine bool
-	updatedRgxMachine bool
-	caseSensitive     bool
-}
-
-// NewFinder retruns a new instace of Finder
-// Setting the engine and if the search will be case sensitive or not.
-func NewFinder(subEng SubstringEngine, rgxEng RegexEngine, caseSensitive bool) (finder *Finder) {
-	return &Finder{
-		expressions:       make([]exprWrapper, 0),
-		keywords:          make(map[string]struct{}),
-		regexes:           make(map[string]struct{}),
-		subEng:            subEng,
-		rgxEng:            rgxEng,
-		updatedSubMachine: false,
-		updatedRgxMachine: false,
-		caseSensitive:     caseSensitive,
-	}
-}
-
-// NewFinderWithExpressions retruns a new instace of Finder with the
-// expressions and tags given at expressionsByTag.
-func NewFinderWithExpressions(
-	subEng SubstringEngine,
-	rgxEng RegexEngine,
-	caseSensitive bool,
-	expressionsByTag map[string][]string,
-) (finder *Finder, err error) {
-	finder = NewFinder(subEng, rgxEng, caseSensitive)
-	for tag, expressions := range expressionsByTag {
-		err = finder.AddExpressionsWithTag(expressions, tag)
-		if err != nil {
-			return
-		}
-	}
-
-	return
-}
-
-// AddExpression adds the expression to the finder. It also collect
-// and store the terms that are going to be used by the substring engine
-// If the expression is malformed returns an error.
-func (finder *Finder) AddExpression(expression string) error {
-	return finder.AddExpressionWithTag(expression, "")
-}
-
-// AddExpressions adds the expressions to the finder. It also collect
-// and store the terms from all expressions that are going to be used by
-// the substring engine If the expression is malformed returns an error.
-func (finder *Finder) AddExpressions(expressions []string) error {
-	for _, expression := range expressions {
-		err := finder.AddExpressionWithTag(expression, "")
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// AddExpressionsWithTag adds the expressions to the finder with the same tag.
-// the tag will be returned on the process text. It also collect
-// and store the terms that are going to be used by the substring engine
-// If the expression is malformed returns an error.
-func (finder *Finder) AddExpressionsWithTag(expressions []string, tag string) error {
-	for _, expression := range expressions {
-		err := finder.AddExpressionWithTag(expression, tag)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// AddExpressionWithTag adds the expression to the finder with a tag.
-// the tag will be returned on the process text. It also collect
-// and store the terms that are going to be used by the substring engine
-// If the expression is malformed returns an error.
-func (finder *Finder) AddExpressionWithTag(expression string, tag string) error {
-	p := dsl.NewParser(strings.NewReader(expression), finder.caseSensitive)
-	exp, err := p.Parse()
-	if err != nil {
-		return err
-	}
-
-	finder.expressions = append(finder.expressions, exprWrapper{expression, exp, tag})
-	for key := range p.GetKeywords() {
-		finder.keywords[key] = struct{}{}
-		finder.updatedSubMachine = false
-	}
-
-	for rgx := range p.GetRegexes() {
-		finder.regexes[rgx] = struct{}{}
-		finder.updatedRgxMachine = false
-	}
-
-	return nil
-}
-
-// ProcessText uses all the unique terms to create the substring engine.
-// Searches for matching terms and solves the expressions.
-// and returns an array of ExpressionResult for the all expressions that were evaluetad as true.
-func (finder *Finder) ProcessText(text string) (expRes []ExpressionResult, err error) {
-	if !finder.caseSensitive {
-		text = strings.ToLower(text)
-	}
-
-	sortedMatchesByKeyword := make(map[string][]int)
-
-	if len(finder.keywords) > 0 {
-		if !finder.updatedSubMachine {
-			err = finder.subEng.BuildEngine(finder.keywords, finder.caseSensitive)
-			if err != nil {
-				return
-			}
-			finder.updatedSubMachine = true
-		}
-
-		keyMaches, err := finder.subEng.FindSubstrings(text)
-		if err != nil {
-			return nil, err
-		}
-		finder.addMatchesToSolverMap(keyMaches, sortedMatchesByKeyword)
-	}
-
-	if len(finder.regexes) > 0 {
-		if !finder.updatedRgxMachine {
-			err = finder.rgxEng.BuildEngine(finder.regexes, finder.caseSensitive)
-			if err != nil {
-				return
-			}
-			finder.updatedRgxMachine = true
-		}
-
-		rgxMaches, err := finder.rgxEng.FindRegexes(text)
-		if err != nil {
-			return nil, err
-		}
-		finder.addMatchesToSolverMap(rgxMaches, sortedMatchesByKeyword)
-	}
-
-	return finder.solveExpressions(sortedMatchesByKeyword)
-}
-
-func (finder *Finder) addMatchesToSolverMap(matches []*Match, sortedMatchesByKeyword map[string][]int) {
-	for _, match := range matches {
-		term := match.Term
-		// if the engine returns the substring that was actually matched
-		// we turn the key to lower to avoid inconsistency
-		if !finder.caseSensitive {
-			term = strings.ToLower(term)
-		}
-
-		if sortedMatches, ok := sortedMatchesByKeyword[term]; ok {
-			sortedMatchesByKeyword[term] = append(sortedMatches, match.Position)
-		} else {
-			sortedMatchesByKeyword[term] = []int{match.Position}
-		}
-	}
-}
-
-// solveExpressions returns all expressions that were true using the values of the solverMap
-func (finder *Finder) solveExpressions(sortedMatchesByKeyword map[string][]int) (expRes []ExpressionResult, err error) {
-	expRes = make([]ExpressionResult, 0)
-	for i, exp := range finder.expressions {
-		res, err := exp.expression.Solve(sortedMatchesByKeyword)
-		if err != nil {
-			return nil, err
-		}
-		if res {
-			expRes = append(expRes, ExpressionResult{
-				Tag:            exp.tag,
-				ExpresionStr:   exp.exprString,
-				ExpresionIndex: i,
-			})
-		}
-	}
-	return
-}
-
-// ForceBuild forces the substring engine to be built if needed
-func (finder *Finder) ForceBuild() (err error) {
-	if !finder.updatedSubMachine {
-		err = finder.subEng.BuildEngine(finder.keywords, finder.caseSensitive)
-		if err != nil {
-			return
-		}
-		finder.updatedSubMachine = true
-	}
-
-	if !finder.updatedRgxMachine {
-		err = finder.rgxEng.BuildEngine(finder.regexes, finder.caseSensitive)
-		if err != nil {
-			return
-		}
-		finder.updatedSubMachine = true
-	}
-	return
-}
-
-// GetKeywords returns all unique terms found on the expressions
-func (finder *Finder) GetKeywords() map[string]struct{} {
-	return finder.keywords
-}
+package finder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+)
+
+// Match holds the maching position, the length of the match
+// and with substring that was found. Position and Length are byte offsets
+// on the processed text.
+type Match struct {
+	Position int
+	Length   int
+	Term     string
+}
+
+// exprWrapper store the expression as a string and
+// the SolverOrder to later solve the expression.
+type exprWrapper struct {
+	exprString string
+	expression *dsl.Expression
+	tag        string
+	// terms are the terms of the expression, whose reference
+	// counts are decremented when the expression is removed.
+	terms *expressionTerms
+}
+
+// expressionTerms holds the terms that were found by the parser of an expression.
+type expressionTerms struct {
+	keywords     map[string]struct{}
+	regexes      map[string]dsl.RegexTerm
+	wordKeywords map[string]struct{}
+	wildcards    map[string]struct{}
+	fuzzyTerms   map[string]dsl.FuzzyTerm
+	caseKeywords map[string]string
+	// wordDistance is set if the expression measures distances in words.
+	wordDistance bool
+}
+
+// newExpressionTerms returns the terms of the expression that was parsed by p.
+func newExpressionTerms(exp *dsl.Expression, p *dsl.Parser) *expressionTerms {
+	return &expressionTerms{
+		keywords:     p.GetKeywords(),
+		regexes:      p.GetRegexTerms(),
+		wordKeywords: p.GetWordKeywords(),
+		wildcards:    p.GetWildcards(),
+		fuzzyTerms:   p.GetFuzzyTerms(),
+		caseKeywords: p.GetCaseKeywords(),
+		wordDistance: exp.UsesWordDistance(),
+	}
+}
+
+// ExpressionInfo holds the raw expression, its tag and its index on the finder.
+type ExpressionInfo struct {
+	Index      int
+	Expression string
+	Tag        string
+}
+
+// ExpressionResult holds the information of an expression that was evaluated as true.
+// Matches is only filled when the match reporting is enabled (see SetMatchReporting)
+// and holds every match of the terms and regexes that contributed to the evaluation.
+// Version is the version of the set of expressions that was used to process the text.
+type ExpressionResult struct {
+	ExpresionIndex int
+	ExpresionStr   string
+	Tag            string
+	Matches        []*Match
+	Version        uint64
+}
+
+// Finder stores the needed information to find the terms and solve the expressions.
+// It is safe to use a Finder from multiple goroutines, expressions can be added while
+// texts are being processed, as long as the engines FindSubstrings, FindRegexes and
+// FindFuzzy are safe for concurrent use.
+type Finder struct {
+	// regexExecuted and regexSkipped are the counters of RegexPrefilterStats,
+	// kept first for the alignment of the atomic operations.
+	regexExecuted uint64
+	regexSkipped  uint64
+	mu            sync.RWMutex
+	// settingsMu serializes ReplaceExpressions with the writers of the settings it copies
+	// to the new expressions, so they are not reverted by the swap. It is locked before mu.
+	settingsMu sync.Mutex
+	// streams is the number of readers that are being processed with a snapshot
+	// of the finder, whose engines must not be rebuilt (see ProcessReaderContext).
+	streams          int32
+	expressions      []exprWrapper
+	keywords         map[string]struct{}
+	regexes          map[string]struct{}
+	keywordsRefCount map[string]int
+	regexesRefCount  map[string]int
+	// regexTerms are the regexes with their flags by key, that are
+	// built by the regex engines that implement RegexFlagsEngine.
+	regexTerms map[string]dsl.RegexTerm
+	// regexLiterals are the literals required by each regex by key, that are searched
+	// with the keywords when the regex prefilter is enabled (see SetRegexPrefilter).
+	regexLiterals    map[string][]string
+	regexesByLiteral map[string][]string
+	regexPrefilter   bool
+	// wordKeywords are the keywords of the whole word terms, whose matches
+	// are filtered by the word boundaries after the substring search.
+	wordKeywords         map[string]struct{}
+	wordKeywordsRefCount map[string]int
+	// wildcards are the wildcard terms by pattern, whose matches are
+	// verified around the matches of their anchors after the substring search.
+	wildcards         map[string]*dsl.Wildcard
+	wildcardsRefCount map[string]int
+	wildcardsByAnchor map[string][]*dsl.Wildcard
+	// fuzzyTerms are the fuzzy terms by key, that are searched by the fuzzy engine.
+	fuzzyTerms         map[string]dsl.FuzzyTerm
+	fuzzyTermsRefCount map[string]int
+	// caseKeywords are the case-sensitive terms mapped to the keywords searched for them,
+	// whose matches are compared with the original text after the substring search.
+	caseKeywords         map[string]string
+	caseKeywordsRefCount map[string]int
+	caseTermsByKeyword   map[string][]string
+	subEng               SubstringEngine
+	rgxEng               RegexEngine
+	fuzzyEng             FuzzyEngine
+	updatedSubMachine    bool
+	updatedRgxMachine    bool
+	updatedFuzzyMachine  bool
+	caseSensitive        bool
+	// normalizer normalizes the terms and the texts, it is nil if no normalization is set.
+	normalizer *dsl.Normalizer
+	// caseNormalizer is the normalizer without the case folding,
+	// that is applied to the text of the case-sensitive terms.
+	caseNormalizer    *dsl.Normalizer
+	reportMatches     bool
+	version           uint64
+	streamChunkSize   int
+	streamRegexWindow int
+	// wordDistanceExprs is the number of expressions with NEAR operators that
+	// measure the distance in words, that need the word index of the text.
+	wordDistanceExprs int
+	// dag shares the equal subtrees of the expressions,
+	// so each one is solved once per text.
+	dag *dsl.ExpressionDAG
+}
+
+// NewFinder retruns a new instace of Finder
+// Setting the engine and if the search will be case sensitive or not.
+// If subEng is nil an AhoCorasickEngine is used.
+// The fuzzy terms are searched by a LevenshteinEngine (see SetFuzzyEngine).
+func NewFinder(subEng SubstringEngine, rgxEng RegexEngine, caseSensitive bool) (finder *Finder) {
+	if subEng == nil {
+		subEng = &AhoCorasickEngine{}
+	}
+	return &Finder{
+		expressions:         make([]exprWrapper, 0),
+		keywords:            make(map[string]struct{}),
+		regexes:             make(map[string]struct{}),
+		keywordsRefCount:    make(map[string]int),
+		regexesRefCount:     make(map[string]int),
+		regexTerms:          make(map[string]dsl.RegexTerm),
+		wordKeywords:        make(map[string]struct{}),
+		fuzzyTerms:          make(map[string]dsl.FuzzyTerm),
+		fuzzyTermsRefCount:  make(map[string]int),
+		subEng:              subEng,
+		rgxEng:              rgxEng,
+		fuzzyEng:            &LevenshteinEngine{},
+		updatedSubMachine:   false,
+		updatedRgxMachine:   false,
+		updatedFuzzyMachine: false,
+		caseSensitive:       caseSensitive,
+		dag:                 dsl.NewExpressionDAG(),
+	}
+}
+
+// NewFinderWithExpressions retruns a new instace of Finder with the
+// expressions and tags given at expressionsByTag.
+func NewFinderWithExpressions(
+	subEng SubstringEngine,
+	rgxEng RegexEngine,
+	caseSensitive bool,
+	expressionsByTag map[string][]string,
+) (finder *Finder, err error) {
+	finder = NewFinder(subEng, rgxEng, caseSensitive)
+	for tag, expressions := range expressionsByTag {
+		err = finder.AddExpressionsWithTag(expressions, tag)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// SetMatchReporting enables or disables the report of the matches on the ExpressionResult.
+// When enabled, each ExpressionResult will carry the matches of the terms and regexes
+// that contributed to the expression being evaluated as true.
+func (finder *Finder) SetMatchReporting(enabled bool) {
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+	finder.reportMatches = enabled
+}
+
+// SetFuzzyEngine sets the engine that searches the fuzzy terms. The engine
+// is built with the fuzzy terms of the expressions on the next process.
+func (finder *Finder) SetFuzzyEngine(fuzzyEng FuzzyEngine) {
+	finder.settingsMu.Lock()
+	defer finder.settingsMu.Unlock()
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+	finder.fuzzyEng = fuzzyEng
+	finder.updatedFuzzyMachine = false
+}
+
+// AddExpression adds the expression to the finder. It also collect
+// and store the terms that are going to be used by the substring engine
+// If the expression is malformed returns an error.
+func (finder *Finder) AddExpression(expression string) error {
+	return finder.AddExpressionWithTag(expression, "")
+}
+
+// AddExpressions adds the expressions to the finder. It also collect
+// and store the terms from all expressions that are going to be used by
+// the substring engine If the expression is malformed returns an error.
+func (finder *Finder) AddExpressions(expressions []string) error {
+	for _, expression := range expressions {
+		err := finder.AddExpressionWithTag(expression, "")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddExpressionsWithTag adds the expressions to the finder with the same tag.
+// the tag will be returned on the process text. It also collect
+// and store the terms that are going to be used by the substring engine
+// If the expression is malformed returns an error.
+func (finder *Finder) AddExpressionsWithTag(expressions []string, tag string) error {
+	for _, expression := range expressions {
+		err := finder.AddExpressionWithTag(expression, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddExpressionWithTag adds the expression to the finder with a tag.
+// the tag will be returned on the process text. It also collect
+// and store the terms that are going to be used by the substring engine
+// If the expression is malformed returns an error. If the expression needs a
+// feature that the engines do not support returns an *UnsupportedFeatureError.
+func (finder *Finder) AddExpressionWithTag(expression string, tag string) error {
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+	p := finder.newParser(expression)
+	exp, err := p.Parse()
+	if err != nil {
+		return err
+	}
+	err = finder.checkFeatures(expression, exp, p)
+	if err != nil {
+		return err
+	}
+
+	terms := newExpressionTerms(exp, p)
+	finder.version++
+	finder.expressions = append(finder.expressions, exprWrapper{expression, finder.shareExpression(exp), tag, terms})
+	finder.addExpressionTerms(terms)
+	return nil
+}
+
+// UpdateExpression replaces the expression and tag at the given index.
+// The index of the expression is kept. If the index is out of range or
+// the expression is malformed returns an error and the finder is left unchanged.
+func (finder *Finder) UpdateExpression(index int, expression string, tag string) error {
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+	p := finder.newParser(expression)
+	exp, err := p.Parse()
+	if err != nil {
+		return err
+	}
+	err = finder.checkFeatures(expression, exp, p)
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index >= len(finder.expressions) {
+		return fmt.Errorf("expression index %d out of range [0, %d)", index, len(finder.expressions))
+	}
+
+	// the new terms are added before the old ones are removed, so the terms
+	// kept by the update are never dropped and the engines are not rebuilt
+	terms := newExpressionTerms(exp, p)
+	newExp := exprWrapper{expression, finder.shareExpression(exp), tag, terms}
+	finder.addExpressionTerms(terms)
+	finder.removeExpressionTerms(finder.expressions[index])
+	finder.version++
+	finder.expressions[index] = newExp
+	return nil
+}
+
+// RemoveExpression removes the expression at the given index. The index of
+// all the expressions after the removed one are decremented by one.
+// If the index is out of range returns an error.
+func (finder *Finder) RemoveExpression(index int) error {
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+	if index < 0 || index >= len(finder.expressions) {
+		return fmt.Errorf("expression index %d out of range [0, %d)", index, len(finder.expressions))
+	}
+
+	finder.removeExpressionTerms(finder.expressions[index])
+	finder.version++
+	finder.expressions = append(finder.expressions[:index], finder.expressions[index+1:]...)
+	return nil
+}
+
+// RemoveExpressionsByTag removes all the expressions with the given tag and
+// returns how many expressions were removed. The remaining expressions keep
+// their relative order but their indexes are updated.
+func (finder *Finder) RemoveExpressionsByTag(tag string) (removed int, err error) {
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+
+	kept := make([]exprWrapper, 0, len(finder.expressions))
+	for _, exp := range finder.expressions {
+		if exp.tag != tag {
+			kept = append(kept, exp)
+			continue
+		}
+		finder.removeExpressionTerms(exp)
+		removed++
+	}
+
+	if removed > 0 {
+		finder.version++
+		finder.expressions = kept
+	}
+	return
+}
+
+// GetExpression returns the expression at the given index.
+// If the index is out of range returns an error.
+func (finder *Finder) GetExpression(index int) (info ExpressionInfo, err error) {
+	finder.mu.RLock()
+	defer finder.mu.RUnlock()
+	if index < 0 || index >= len(finder.expressions) {
+		err = fmt.Errorf("expression index %d out of range [0, %d)", index, len(finder.expressions))
+		return
+	}
+
+	exp := finder.expressions[index]
+	return ExpressionInfo{Index: index, Expression: exp.exprString, Tag: exp.tag}, nil
+}
+
+// GetExpressions returns all the expressions of the finder ordered by index.
+func (finder *Finder) GetExpressions() []ExpressionInfo {
+	finder.mu.RLock()
+	defer finder.mu.RUnlock()
+
+	infos := make([]ExpressionInfo, 0, len(finder.expressions))
+	for i, exp := range finder.expressions {
+		infos = append(infos, ExpressionInfo{Index: i, Expression: exp.exprString, Tag: exp.tag})
+	}
+	return infos
+}
+
+// GetExpressionsByTag returns all the expressions with the given tag ordered by index.
+func (finder *Finder) GetExpressionsByTag(tag string) []ExpressionInfo {
+	finder.mu.RLock()
+	defer finder.mu.RUnlock()
+
+	infos := make([]ExpressionInfo, 0)
+	for i, exp := range finder.expressions {
+		if exp.tag == tag {
+			infos = append(infos, ExpressionInfo{Index: i, Expression: exp.exprString, Tag: exp.tag})
+		}
+	}
+	return infos
+}
+
+// newParser returns a parser of the expression with the case
+// sensitivity and the normalizer of the finder.
+func (finder *Finder) newParser(expression string) *dsl.Parser {
+	p := dsl.NewParser(strings.NewReader(expression), finder.caseSensitive)
+	p.SetNormalizer(finder.normalizer)
+	return p
+}
+
+// addTerms increments the reference count of the terms and adds the
+// new ones to the sets used by the engines.
+func (finder *Finder) addTerms(keywords map[string]struct{}, regexes map[string]dsl.RegexTerm) {
+	if finder.keywordsRefCount == nil {
+		finder.keywordsRefCount = make(map[string]int)
+	}
+	if finder.regexesRefCount == nil {
+		finder.regexesRefCount = make(map[string]int)
+	}
+	if finder.regexTerms == nil {
+		finder.regexTerms = make(map[string]dsl.RegexTerm)
+	}
+
+	for key := range keywords {
+		finder.keywordsRefCount[key]++
+		if _, ok := finder.keywords[key]; !ok {
+			finder.keywords[key] = struct{}{}
+			finder.updatedSubMachine = false
+		}
+	}
+
+	finder.addRegexLiterals(regexes)
+	for rgx, rt := range regexes {
+		finder.regexesRefCount[rgx]++
+		if _, ok := finder.regexes[rgx]; !ok {
+			finder.regexes[rgx] = struct{}{}
+			finder.regexTerms[rgx] = rt
+			finder.updatedRgxMachine = false
+		}
+	}
+}
+
+// addWordTerms increments the reference count of the whole word keywords
+// and adds the new ones to the set of keywords that are filtered.
+func (finder *Finder) addWordTerms(wordKeywords map[string]struct{}) {
+	if finder.wordKeywords == nil {
+		finder.wordKeywords = make(map[string]struct{})
+	}
+	if finder.wordKeywordsRefCount == nil {
+		finder.wordKeywordsRefCount = make(map[string]int)
+	}
+
+	for key := range wordKeywords {
+		finder.wordKeywordsRefCount[key]++
+		finder.wordKeywords[key] = struct{}{}
+	}
+}
+
+// addFuzzyTerms increments the reference count of the fuzzy
+// terms and adds the new ones to the set used by the fuzzy engine.
+func (finder *Finder) addFuzzyTerms(fuzzyTerms map[string]dsl.FuzzyTerm) {
+	if finder.fuzzyTerms == nil {
+		finder.fuzzyTerms = make(map[string]dsl.FuzzyTerm)
+	}
+	if finder.fuzzyTermsRefCount == nil {
+		finder.fuzzyTermsRefCount = make(map[string]int)
+	}
+
+	for key, ft := range fuzzyTerms {
+		finder.fuzzyTermsRefCount[key]++
+		if _, ok := finder.fuzzyTerms[key]; !ok {
+			finder.fuzzyTerms[key] = ft
+			finder.updatedFuzzyMachine = false
+		}
+	}
+}
+
+// addExpressionTerms increments the reference count of the terms of
+// an expression and adds the new ones to the sets used by the engines.
+func (finder *Finder) addExpressionTerms(terms *expressionTerms) {
+	finder.addTerms(terms.keywords, terms.regexes)
+	finder.addWordTerms(terms.wordKeywords)
+	finder.addWildcardTerms(terms.wildcards)
+	finder.addFuzzyTerms(terms.fuzzyTerms)
+	finder.addCaseTerms(terms.caseKeywords)
+	if terms.wordDistance {
+		finder.wordDistanceExprs++
+	}
+}
+
+// removeExpressionTerms decrements the reference count of the terms of the
+// expression and removes from the sets the terms that are no longer used.
+func (finder *Finder) removeExpressionTerms(exp exprWrapper) {
+	if finder.dag != nil {
+		finder.dag.Remove(exp.expression)
+	}
+	terms := exp.terms
+	if terms == nil {
+		return
+	}
+
+	for key := range terms.keywords {
+		finder.keywordsRefCount[key]--
+		if finder.keywordsRefCount[key] <= 0 {
+			delete(finder.keywordsRefCount, key)
+			delete(finder.keywords, key)
+			finder.updatedSubMachine = false
+		}
+	}
+
+	for rgx := range terms.regexes {
+		finder.regexesRefCount[rgx]--
+		if finder.regexesRefCount[rgx] <= 0 {
+			delete(finder.regexesRefCount, rgx)
+			delete(finder.regexes, rgx)
+			delete(finder.regexTerms, rgx)
+			finder.updatedRgxMachine = false
+		}
+	}
+	finder.removeRegexLiterals(terms.regexes)
+
+	for key := range terms.wordKeywords {
+		finder.wordKeywordsRefCount[key]--
+		if finder.wordKeywordsRefCount[key] <= 0 {
+			delete(finder.wordKeywordsRefCount, key)
+			delete(finder.wordKeywords, key)
+		}
+	}
+
+	finder.removeWildcardTerms(terms.wildcards)
+	finder.removeCaseTerms(terms.caseKeywords)
+
+	for key := range terms.fuzzyTerms {
+		finder.fuzzyTermsRefCount[key]--
+		if finder.fuzzyTermsRefCount[key] <= 0 {
+			delete(finder.fuzzyTermsRefCount, key)
+			delete(finder.fuzzyTerms, key)
+			finder.updatedFuzzyMachine = false
+		}
+	}
+
+	if terms.wordDistance {
+		finder.wordDistanceExprs--
+	}
+}
+
+// ReplicableEngine is implemented by the substring, regex and fuzzy engines that are able to
+// return a new instance of themselves, with the same settings and without any built term.
+// ReplaceExpressions builds the new expressions on new instances of the engines of the finder,
+// so the engines in use are never built while texts are being processed.
+type ReplicableEngine interface {
+	// NewEngine returns a new instance of the engine with the same settings.
+	// It returns nil if the engine can not be replicated.
+	NewEngine() interface{}
+}
+
+// newEngineOf returns a new instance of the engine (see ReplicableEngine).
+// If the engine is nil returns nil.
+func newEngineOf(engine interface{}) (interface{}, error) {
+	if engine == nil {
+		return nil, nil
+	}
+	if repEng, ok := engine.(ReplicableEngine); ok {
+		if newEng := repEng.NewEngine(); newEng != nil {
+			return newEng, nil
+		}
+	}
+	return nil, fmt.Errorf("the engine %T can not be replicated", engine)
+}
+
+// finderEngines holds the engines of a finder.
+type finderEngines struct {
+	subEng   SubstringEngine
+	rgxEng   RegexEngine
+	fuzzyEng FuzzyEngine
+}
+
+// newEngines returns new instances of the engines of the finder (see ReplicableEngine).
+func (finder *Finder) newEngines() (engines finderEngines, err error) {
+	subEng, err := newEngineOf(finder.subEng)
+	if err != nil {
+		return
+	}
+	rgxEng, err := newEngineOf(finder.rgxEng)
+	if err != nil {
+		return
+	}
+	fuzzyEng, err := newEngineOf(finder.fuzzyEng)
+	if err != nil {
+		return
+	}
+
+	var ok bool
+	if engines.subEng, ok = subEng.(SubstringEngine); subEng != nil && !ok {
+		return engines, fmt.Errorf("the new instance of the engine %T is not a SubstringEngine", finder.subEng)
+	}
+	if engines.rgxEng, ok = rgxEng.(RegexEngine); rgxEng != nil && !ok {
+		return engines, fmt.Errorf("the new instance of the engine %T is not a RegexEngine", finder.rgxEng)
+	}
+	if engines.fuzzyEng, ok = fuzzyEng.(FuzzyEngine); fuzzyEng != nil && !ok {
+		return engines, fmt.Errorf("the new instance of the engine %T is not a FuzzyEngine", finder.fuzzyEng)
+	}
+	return
+}
+
+// ReplaceExpressions replaces all the expressions of the finder with the
+// expressions and tags given at expressionsByTag. The new expressions are parsed
+// and new instances of the engines of the finder are built before the swap (see
+// ReplicableEngine), so the texts that are being processed finish with the previous
+// expressions and engines while the new ones are prepared. Returns the version of the
+// new set of expressions. If any expression is malformed or an engine can not be
+// replicated or fails to build, returns an error and the finder is left unchanged.
+func (finder *Finder) ReplaceExpressions(expressionsByTag map[string][]string) (version uint64, err error) {
+	finder.settingsMu.Lock()
+	defer finder.settingsMu.Unlock()
+
+	finder.mu.RLock()
+	normalizer := finder.normalizer
+	regexPrefilter := finder.regexPrefilter
+	engines, err := finder.newEngines()
+	finder.mu.RUnlock()
+	if err != nil {
+		return
+	}
+
+	newFinder := NewFinder(engines.subEng, engines.rgxEng, finder.caseSensitive)
+	newFinder.fuzzyEng = engines.fuzzyEng
+	newFinder.normalizer = normalizer
+	newFinder.regexPrefilter = regexPrefilter
+	for tag, expressions := range expressionsByTag {
+		err = newFinder.AddExpressionsWithTag(expressions, tag)
+		if err != nil {
+			return
+		}
+	}
+
+	err = newFinder.ForceBuild()
+	if err != nil {
+		return
+	}
+
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+	finder.version++
+	finder.setTerms(newFinder)
+	finder.subEng = newFinder.subEng
+	finder.rgxEng = newFinder.rgxEng
+	finder.fuzzyEng = newFinder.fuzzyEng
+	finder.updatedSubMachine = newFinder.updatedSubMachine
+	finder.updatedRgxMachine = newFinder.updatedRgxMachine
+	finder.updatedFuzzyMachine = newFinder.updatedFuzzyMachine
+	return finder.version, nil
+}
+
+// setTerms replaces the expressions and the terms of the finder with the ones of newFinder.
+func (finder *Finder) setTerms(newFinder *Finder) {
+	finder.expressions = newFinder.expressions
+	finder.keywords = newFinder.keywords
+	finder.regexes = newFinder.regexes
+	finder.keywordsRefCount = newFinder.keywordsRefCount
+	finder.regexesRefCount = newFinder.regexesRefCount
+	finder.regexTerms = newFinder.regexTerms
+	finder.regexLiterals = newFinder.regexLiterals
+	finder.regexesByLiteral = newFinder.regexesByLiteral
+	finder.wordKeywords = newFinder.wordKeywords
+	finder.wordKeywordsRefCount = newFinder.wordKeywordsRefCount
+	finder.wildcards = newFinder.wildcards
+	finder.wildcardsRefCount = newFinder.wildcardsRefCount
+	finder.wildcardsByAnchor = newFinder.wildcardsByAnchor
+	finder.fuzzyTerms = newFinder.fuzzyTerms
+	finder.fuzzyTermsRefCount = newFinder.fuzzyTermsRefCount
+	finder.caseKeywords = newFinder.caseKeywords
+	finder.caseKeywordsRefCount = newFinder.caseKeywordsRefCount
+	finder.caseTermsByKeyword = newFinder.caseTermsByKeyword
+	finder.wordDistanceExprs = newFinder.wordDistanceExprs
+	finder.dag = newFinder.dag
+}
+
+// SetNormalization sets the normalization forms that are applied to the terms, except
+// for the regexes, and to the texts before they are searched. If the finder is not case
+// sensitive the case folding is always applied. The expressions are parsed again with
+// the new normalization and the positions of the reported matches are mapped to the
+// original text. Setting no forms disables the normalization.
+// If any expression fails to be parsed returns an error and the finder is left unchanged.
+func (finder *Finder) SetNormalization(forms dsl.Normalization) error {
+	finder.settingsMu.Lock()
+	defer finder.settingsMu.Unlock()
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+
+	var normalizer, caseNormalizer *dsl.Normalizer
+	if forms != 0 {
+		if !finder.caseSensitive {
+			forms |= dsl.CaseFolding
+		}
+		normalizer = dsl.NewNormalizer(forms)
+		if caseForms := forms &^ dsl.CaseFolding; caseForms != 0 {
+			caseNormalizer = dsl.NewNormalizer(caseForms)
+		}
+	}
+
+	newFinder := NewFinder(finder.subEng, finder.rgxEng, finder.caseSensitive)
+	newFinder.normalizer = normalizer
+	newFinder.regexPrefilter = finder.regexPrefilter
+	for _, exp := range finder.expressions {
+		err := newFinder.AddExpressionWithTag(exp.exprString, exp.tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	finder.version++
+	finder.normalizer = normalizer
+	finder.caseNormalizer = caseNormalizer
+	finder.setTerms(newFinder)
+	finder.updatedSubMachine = false
+	finder.updatedRgxMachine = false
+	finder.updatedFuzzyMachine = false
+	return nil
+}
+
+// Version returns the version of the current set of expressions.
+// The version is incremented every time the set of expressions changes.
+func (finder *Finder) Version() uint64 {
+	finder.mu.RLock()
+	defer finder.mu.RUnlock()
+	return finder.version
+}
+
+// ProcessText uses all the unique terms to create the substring engine.
+// Searches for matching terms and solves the expressions.
+// and returns an array of ExpressionResult for the all expressions that were evaluetad as true.
+// If the finder is not case sensitive the text is changed to lowercase before it is searched,
+// unless the engines fold its case themselves (see CaseFoldingFeature).
+func (finder *Finder) ProcessText(text string) (expRes []ExpressionResult, err error) {
+	return finder.ProcessTextContext(context.Background(), text)
+}
+
+// ProcessTextContext works as ProcessText but stops the processing when the context is done.
+// The context is checked between the engines searches and between the expressions evaluations.
+// If the processing is stopped a *PartialResultError is returned along with the expressions
+// that were already evaluated as true.
+func (finder *Finder) ProcessTextContext(ctx context.Context, text string) (expRes []ExpressionResult, err error) {
+	err = finder.rLockBuilt()
+	if err != nil {
+		return
+	}
+	defer finder.mu.RUnlock()
+
+	if ctx.Err() != nil {
+		return nil, &PartialResultError{Stage: SubstringSearchStage, Total: len(finder.expressions), Err: ctx.Err()}
+	}
+
+	original := text
+	var positions *dsl.PositionMap
+	if finder.normalizer != nil {
+		text, positions = finder.normalizer.Normalize(text)
+	} else if finder.needsLowercaseText() {
+		text = strings.ToLower(text)
+	}
+
+	sortedMatchesByKeyword := make(map[string][]int)
+	var matchesByTerm map[string][]*Match
+	if finder.reportMatches {
+		matchesByTerm = make(map[string][]*Match)
+	}
+
+	var foundLiterals map[string]struct{}
+	if len(finder.keywords) > 0 || finder.usesRegexPrefilter() {
+		keyMaches, err := finder.subEng.FindSubstrings(text)
+		if err != nil {
+			return nil, err
+		}
+		if finder.usesRegexPrefilter() {
+			foundLiterals = make(map[string]struct{})
+			keyMaches = finder.splitLiteralMatches(keyMaches, func(match *Match) {
+				foundLiterals[match.Term] = struct{}{}
+			})
+		}
+		finder.addMatchesToSolverMap(keyMaches, sortedMatchesByKeyword)
+		finder.addMatchesToTermMap(keyMaches, matchesByTerm)
+
+		if len(finder.wordKeywords) > 0 {
+			wordMatches := finder.filterWordMatches(keyMaches, text, 0)
+			finder.addMatchesToSolverMap(wordMatches, sortedMatchesByKeyword)
+			finder.addMatchesToTermMap(wordMatches, matchesByTerm)
+		}
+
+		if len(finder.wildcards) > 0 {
+			wcMatches := finder.verifyWildcardMatches(keyMaches, text, 0, make(map[string]map[int]struct{}))
+			finder.addMatchesToSolverMap(wcMatches, sortedMatchesByKeyword)
+			finder.addMatchesToTermMap(wcMatches, matchesByTerm)
+		}
+
+		if len(finder.caseKeywords) > 0 {
+			caseMatches := finder.filterCaseMatches(keyMaches, original, 0, positions)
+			finder.addMatchesToSolverMap(caseMatches, sortedMatchesByKeyword)
+			finder.addMatchesToTermMap(caseMatches, matchesByTerm)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, &PartialResultError{Stage: RegexSearchStage, Total: len(finder.expressions), Err: ctx.Err()}
+	}
+
+	var wordIndex *dsl.WordIndex
+	if finder.wordDistanceExprs > 0 {
+		wordIndex = dsl.NewWordIndex(text)
+	}
+
+	// the regexes and the fuzzy terms are only searched if they may
+	// change the evaluation of an expression that is not decided yet.
+	if len(finder.regexes) > 0 {
+		needed, err := finder.neededTerms(sortedMatchesByKeyword, finder.unknownTerms(true), wordIndex)
+		if err != nil {
+			return nil, err
+		}
+		rgxMaches, err := finder.findRegexes(text, foundLiterals, needed)
+		if err != nil {
+			return nil, err
+		}
+		finder.addMatchesToSolverMap(rgxMaches, sortedMatchesByKeyword)
+		finder.addMatchesToTermMap(rgxMaches, matchesByTerm)
+	}
+
+	if len(finder.fuzzyTerms) > 0 {
+		if ctx.Err() != nil {
+			return nil, &PartialResultError{Stage: FuzzySearchStage, Total: len(finder.expressions), Err: ctx.Err()}
+		}
+
+		needed, err := finder.neededTerms(sortedMatchesByKeyword, finder.unknownTerms(false), wordIndex)
+		if err != nil {
+			return nil, err
+		}
+		if len(needed) > 0 {
+			fuzzyMatches, err := finder.fuzzyEng.FindFuzzy(text)
+			if err != nil {
+				return nil, err
+			}
+			finder.addMatchesToSolverMap(fuzzyMatches, sortedMatchesByKeyword)
+			finder.addMatchesToTermMap(fuzzyMatches, matchesByTerm)
+		}
+	}
+
+	mapMatchesToOriginal(matchesByTerm, positions)
+	return finder.solveMatches(ctx, sortedMatchesByKeyword, matchesByTerm, wordIndex)
+}
+
+// rLockBuilt builds the outdated engines and acquires the read lock of the finder.
+// If no error is returned the caller must release the read lock.
+func (finder *Finder) rLockBuilt() error {
+	finder.mu.RLock()
+	for finder.needsBuild() {
+		finder.mu.RUnlock()
+		err := finder.buildEngines()
+		if err != nil {
+			return err
+		}
+		finder.mu.RLock()
+	}
+	return nil
+}
+
+// solveMatches solves the expressions with the found matches and adds the
+// contributing matches to the results if the match reporting is enabled.
+// The wordIndex is only needed if there are expressions that use word distances.
+func (finder *Finder) solveMatches(
+	ctx context.Context,
+	sortedMatchesByKeyword map[string][]int,
+	matchesByTerm map[string][]*Match,
+	wordIndex *dsl.WordIndex,
+) (expRes []ExpressionResult, err error) {
+	expRes, solveErr := finder.solveExpressionsContext(ctx, sortedMatchesByKeyword, wordIndex)
+	if _, ok := solveErr.(*PartialResultError); solveErr != nil && !ok {
+		return nil, solveErr
+	}
+
+	if finder.reportMatches {
+		err = finder.addMatchesToResults(expRes, sortedMatchesByKeyword, matchesByTerm, wordIndex)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return expRes, solveErr
+}
+
+// needsBuild returns true if an engine that has terms to search is outdated.
+func (finder *Finder) needsBuild() bool {
+	return ((len(finder.keywords) > 0 || finder.usesRegexPrefilter()) && !finder.updatedSubMachine) ||
+		(len(finder.regexes) > 0 && !finder.updatedRgxMachine) ||
+		(len(finder.fuzzyTerms) > 0 && !finder.updatedFuzzyMachine)
+}
+
+// buildEngines builds the outdated engines that have terms to search.
+func (finder *Finder) buildEngines() (err error) {
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+	if atomic.LoadInt32(&finder.streams) > 0 {
+		finder.detachEngines()
+	}
+
+	if (len(finder.keywords) > 0 || finder.usesRegexPrefilter()) && !finder.updatedSubMachine {
+		err = finder.subEng.BuildEngine(finder.searchedKeywords(), finder.caseSensitive)
+		if err != nil {
+			return
+		}
+		finder.updatedSubMachine = true
+	}
+
+	if len(finder.regexes) > 0 && !finder.updatedRgxMachine {
+		err = finder.buildRegexEngine()
+		if err != nil {
+			return
+		}
+		finder.updatedRgxMachine = true
+	}
+
+	if len(finder.fuzzyTerms) > 0 && !finder.updatedFuzzyMachine {
+		err = finder.fuzzyEng.BuildEngine(finder.fuzzyTerms, finder.caseSensitive)
+		if err != nil {
+			return
+		}
+		finder.updatedFuzzyMachine = true
+	}
+	return
+}
+
+// matchTermKey returns the key of the term of a match on the maps of matches.
+// If the engine returns the substring that was actually matched we turn the key
+// to lower to avoid inconsistency, except for the keys of the case-sensitive terms
+// and of the regexes, that keep the case they were written with.
+func (finder *Finder) matchTermKey(term string) string {
+	if finder.caseSensitive || finder.isCaseTermKey(term) {
+		return term
+	}
+	if _, ok := finder.regexes[term]; ok {
+		return term
+	}
+	return strings.ToLower(term)
+}
+
+func (finder *Finder) addMatchesToSolverMap(matches []*Match, sortedMatchesByKeyword map[string][]int) {
+	for _, match := range matches {
+		term := finder.matchTermKey(match.Term)
+
+		if sortedMatches, ok := sortedMatchesByKeyword[term]; ok {
+			sortedMatchesByKeyword[term] = append(sortedMatches, match.Position)
+		} else {
+			sortedMatchesByKeyword[term] = []int{match.Position}
+		}
+	}
+}
+
+// addMatchesToTermMap groups the matches by term. If matchesByTerm is nil
+// the matches are ignored.
+func (finder *Finder) addMatchesToTermMap(matches []*Match, matchesByTerm map[string][]*Match) {
+	if matchesByTerm == nil {
+		return
+	}
+	for _, match := range matches {
+		term := finder.matchTermKey(match.Term)
+		matchesByTerm[term] = append(matchesByTerm[term], &Match{
+			Position: match.Position,
+			Length:   match.Length,
+			Term:     term,
+		})
+	}
+}
+
+// mapMatchesToOriginal maps the positions of the matches found on the normalized text
+// to the original text. If positions is nil the text was not normalized.
+func mapMatchesToOriginal(matchesByTerm map[string][]*Match, positions *dsl.PositionMap) {
+	if positions == nil {
+		return
+	}
+	for _, matches := range matchesByTerm {
+		for _, match := range matches {
+			start, end := positions.Original(match.Position, match.Position+match.Length)
+			match.Position = start
+			match.Length = end - start
+		}
+	}
+}
+
+// addMatchesToResults fills the Matches of each result with the matches of
+// the terms that contributed to the expression being evaluated as true.
+func (finder *Finder) addMatchesToResults(
+	expRes []ExpressionResult,
+	sortedMatchesByKeyword map[string][]int,
+	matchesByTerm map[string][]*Match,
+	wordIndex *dsl.WordIndex,
+) error {
+	for i := range expRes {
+		exp := finder.expressions[expRes[i].ExpresionIndex]
+		_, terms, err := exp.expression.SolveWithMatchedTerms(sortedMatchesByKeyword, wordIndex)
+		if err != nil {
+			return err
+		}
+		matches := make([]*Match, 0)
+		for term := range terms {
+			matches = append(matches, matchesByTerm[term]...)
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			if matches[i].Position == matches[j].Position {
+				return matches[i].Term < matches[j].Term
+			}
+			return matches[i].Position < matches[j].Position
+		})
+		expRes[i].Matches = matches
+	}
+	return nil
+}
+
+// solveExpressions returns all expressions that were true using the values of the solverMap
+func (finder *Finder) solveExpressions(sortedMatchesByKeyword map[string][]int) (expRes []ExpressionResult, err error) {
+	return finder.solveExpressionsContext(context.Background(), sortedMatchesByKeyword, nil)
+}
+
+// solveExpressionsContext works as solveExpressions but stops when the context is done,
+// returning the expressions that were already evaluated as true and a *PartialResultError.
+func (finder *Finder) solveExpressionsContext(
+	ctx context.Context,
+	sortedMatchesByKeyword map[string][]int,
+	wordIndex *dsl.WordIndex,
+) (expRes []ExpressionResult, err error) {
+	expRes = make([]ExpressionResult, 0)
+	cache := dsl.NewSolveCache()
+	for i, exp := range finder.expressions {
+		if ctx.Err() != nil {
+			return expRes, &PartialResultError{
+				Stage:     ExpressionsStage,
+				Processed: i,
+				Total:     len(finder.expressions),
+				Err:       ctx.Err(),
+			}
+		}
+		res, err := exp.expression.SolveWithCache(sortedMatchesByKeyword, wordIndex, cache)
+		if err != nil {
+			return nil, err
+		}
+		if res {
+			expRes = append(expRes, ExpressionResult{
+				Tag:            exp.tag,
+				ExpresionStr:   exp.exprString,
+				ExpresionIndex: i,
+				Version:        finder.version,
+			})
+		}
+	}
+	return
+}
+
+// detachEngines replaces the outdated engines that are ReplicableEngines with new instances,
+// so the engines searched by the readers that are being processed with a snapshot of
+// the finder are not rebuilt while they are used (see ProcessReaderContext).
+func (finder *Finder) detachEngines() {
+	if !finder.updatedSubMachine {
+		if subEng, ok := replicaOf(finder.subEng).(SubstringEngine); ok {
+			finder.subEng = subEng
+		}
+	}
+	if !finder.updatedRgxMachine {
+		if rgxEng, ok := replicaOf(finder.rgxEng).(RegexEngine); ok {
+			finder.rgxEng = rgxEng
+		}
+	}
+	if !finder.updatedFuzzyMachine {
+		if fuzzyEng, ok := replicaOf(finder.fuzzyEng).(FuzzyEngine); ok {
+			finder.fuzzyEng = fuzzyEng
+		}
+	}
+}
+
+// replicaOf returns a new instance of the engine, or nil if it can not be replicated.
+func replicaOf(engine interface{}) interface{} {
+	newEng, err := newEngineOf(engine)
+	if err != nil {
+		return nil
+	}
+	return newEng
+}
+
+// ForceBuild forces the outdated engines that have terms to search to be built,
+// so the next process does not need to build them. The engines without terms are
+// not built, as on the processes, so a finder without regexes needs no regex engine.
+func (finder *Finder) ForceBuild() (err error) {
+	return finder.buildEngines()
+}
+
+// buildRegexEngine builds the regex engine with the flags of the regexes if it
+// implements RegexFlagsEngine, or with the keys of the regexes otherwise.
+func (finder *Finder) buildRegexEngine() error {
+	if flagsEng, ok := finder.rgxEng.(RegexFlagsEngine); ok {
+		return flagsEng.BuildEngineWithFlags(finder.regexTerms, finder.caseSensitive)
+	}
+	return finder.rgxEng.BuildEngine(finder.regexes, finder.caseSensitive)
+}
+
+// GetRegexes returns a copy of all unique regexes found on the expressions
+func (finder *Finder) GetRegexes() map[string]struct{} {
+	finder.mu.RLock()
+	defer finder.mu.RUnlock()
+
+	regexes := make(map[string]struct{}, len(finder.regexes))
+	for rgx := range finder.regexes {
+		regexes[rgx] = struct{}{}
+	}
+	return regexes
+}
+
+// GetKeywords returns a copy of all unique terms found on the expressions
+func (finder *Finder) GetKeywords() map[string]struct{} {
+	finder.mu.RLock()
+	defer finder.mu.RUnlock()
+
+	keywords := make(map[string]struct{}, len(finder.keywords))
+	for key := range finder.keywords {
+		keywords[key] = struct{}{}
+	}
+	return keywords
+}
+
+// GetSharingStats returns the number of nodes of the expressions and the number of
+// unique nodes that are solved for each text, since the equal subtrees of the
+// expressions are shared. Eg: stats.DedupRatio() is 0.25 if a quarter of the nodes are duplicated.
+func (finder *Finder) GetSharingStats() dsl.DAGStats {
+	finder.mu.RLock()
+	defer finder.mu.RUnlock()
+	if finder.dag == nil {
+		return dsl.DAGStats{}
+	}
+	return finder.dag.Stats()
+}
+
+// shareExpression adds the expression to the shared DAG of the
+// expressions and returns its shared version.
+func (finder *Finder) shareExpression(exp *dsl.Expression) *dsl.Expression {
+	if finder.dag == nil {
+		finder.dag = dsl.NewExpressionDAG()
+	}
+	return finder.dag.Add(exp)
+}
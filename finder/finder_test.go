@@ -1,8 +1,13 @@
 package finder
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pedroegsilva/gofindthem/dsl"
 
@@ -10,6 +15,18 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+// newTestTerms returns the terms of an expression with only keywords and regexes.
+func newTestTerms(keywords map[string]struct{}, regexes map[string]dsl.RegexTerm) *expressionTerms {
+	return &expressionTerms{
+		keywords:     keywords,
+		regexes:      regexes,
+		wordKeywords: map[string]struct{}{},
+		wildcards:    map[string]struct{}{},
+		fuzzyTerms:   map[string]dsl.FuzzyTerm{},
+		caseKeywords: map[string]string{},
+	}
+}
+
 type expectedAddExpression struct {
 	exprs    []exprWrapper
 	keywords map[string]struct{}
@@ -39,11 +56,13 @@ func TestAddExpression(t *testing.T) {
 								Literal: "a",
 							},
 							RExpr: &dsl.Expression{
-								Type:    dsl.UNIT_EXPR,
-								Literal: "b",
+								Type:     dsl.UNIT_EXPR,
+								Literal:  "B",
+								TermType: dsl.REGEX_TERM,
 							},
 						},
 						"",
+						newTestTerms(map[string]struct{}{"a": {}}, map[string]dsl.RegexTerm{"B": {Pattern: "B"}}),
 					},
 					{
 						`not "C"`,
@@ -55,6 +74,7 @@ func TestAddExpression(t *testing.T) {
 							},
 						},
 						"",
+						newTestTerms(map[string]struct{}{"c": {}}, map[string]dsl.RegexTerm{}),
 					},
 				},
 				keywords: map[string]struct{}{
@@ -62,7 +82,7 @@ func TestAddExpression(t *testing.T) {
 					"c": {},
 				},
 				regexes: map[string]struct{}{
-					"b": {},
+					"B": {},
 				},
 				errors: []error{nil, nil},
 			},
@@ -80,6 +100,7 @@ func TestAddExpression(t *testing.T) {
 							Literal: "A",
 						},
 						"",
+						newTestTerms(map[string]struct{}{"A": {}}, map[string]dsl.RegexTerm{}),
 					},
 				},
 				keywords: map[string]struct{}{
@@ -102,6 +123,7 @@ func TestAddExpression(t *testing.T) {
 							Literal: "A",
 						},
 						"",
+						newTestTerms(map[string]struct{}{"A": {}}, map[string]dsl.RegexTerm{}),
 					},
 				},
 				keywords: map[string]struct{}{
@@ -180,11 +202,11 @@ func TestProcessText(t *testing.T) {
 	text := `text`
 
 	matches1 := []*Match{
-		{1, "sharpest"},
+		{Position: 1, Term: "sharpest"},
 	}
 
 	matches2 := []*Match{
-		{2, "words"},
+		{Position: 2, Term: "words"},
 	}
 
 	emptyMatches := []*Match{}
@@ -213,6 +235,7 @@ func TestProcessText(t *testing.T) {
 
 	finderFindErrRgx := NewFinder(subMock6, rgxMock6, true)
 	finderFindErrRgx.regexes = map[string]struct{}{"1": {}}
+	finderFindErrRgx.expressions = []exprWrapper{{expression: &dsl.Expression{Type: dsl.UNIT_EXPR, Literal: "1"}}}
 
 	tests := []struct {
 		finder                *Finder
@@ -237,6 +260,7 @@ func TestProcessText(t *testing.T) {
 							Literal: "sharpest",
 						},
 						"",
+						nil,
 					},
 					{
 						`r"words"`,
@@ -246,6 +270,7 @@ func TestProcessText(t *testing.T) {
 							Literal: "words",
 						},
 						"",
+						nil,
 					},
 				},
 				keywords:          map[string]struct{}{"sharpest": {}},
@@ -284,6 +309,7 @@ func TestProcessText(t *testing.T) {
 							Literal: "sharpest",
 						},
 						"",
+						nil,
 					},
 					{
 						`r"words"`,
@@ -292,6 +318,7 @@ func TestProcessText(t *testing.T) {
 							Literal: "words",
 						},
 						"",
+						nil,
 					},
 				},
 				keywords:          map[string]struct{}{"sharpest": {}},
@@ -407,21 +434,21 @@ func TestProcessText(t *testing.T) {
 func TestAddMatchesToSolverMap(t *testing.T) {
 	assert := assert.New(t)
 	matches1 := []*Match{
-		{1, "sharpest"},
-		{2, "sharpest"},
-		{3, "sharpest"},
-		{7, "words"},
-		{9, "Showman"},
-		{10, "showman"},
+		{Position: 1, Term: "sharpest"},
+		{Position: 2, Term: "sharpest"},
+		{Position: 3, Term: "sharpest"},
+		{Position: 7, Term: "words"},
+		{Position: 9, Term: "Showman"},
+		{Position: 10, Term: "showman"},
 	}
 
 	matches2 := []*Match{
-		{1, "sharpest"},
-		{2, "sharpest"},
-		{3, "sharpest"},
-		{7, "words"},
-		{9, "Showman"},
-		{10, "showman"},
+		{Position: 1, Term: "sharpest"},
+		{Position: 2, Term: "sharpest"},
+		{Position: 3, Term: "sharpest"},
+		{Position: 7, Term: "words"},
+		{Position: 9, Term: "Showman"},
+		{Position: 10, Term: "showman"},
 	}
 
 	tests := []struct {
@@ -488,6 +515,7 @@ func TestSolveExpressions(t *testing.T) {
 					RExpr: rexp1,
 				},
 				"",
+				nil,
 			},
 			{
 				`"no one" or "Can get in the way"`,
@@ -497,6 +525,7 @@ func TestSolveExpressions(t *testing.T) {
 					RExpr: rexp2,
 				},
 				"",
+				nil,
 			},
 		},
 	}
@@ -576,3 +605,1238 @@ func TestSolveExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestProcessTextWithMatchReporting(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpressionWithTag(`"foo" and (r"ba[rz]" or "qux")`, "tag1"))
+	assert.Nil(findthem.AddExpression(`"foo" and not "qux"`))
+
+	expRes, err := findthem.ProcessText("Foo bar foo baz")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{
+		{
+			ExpresionIndex: 0,
+			ExpresionStr:   `"foo" and (r"ba[rz]" or "qux")`,
+			Tag:            "tag1",
+			Matches: []*Match{
+				{Position: 0, Length: 3, Term: "foo"},
+				{Position: 4, Length: 3, Term: "ba[rz]"},
+				{Position: 8, Length: 3, Term: "foo"},
+				{Position: 12, Length: 3, Term: "ba[rz]"},
+			},
+			Version: 2,
+		},
+		{
+			ExpresionIndex: 1,
+			ExpresionStr:   `"foo" and not "qux"`,
+			Matches: []*Match{
+				{Position: 0, Length: 3, Term: "foo"},
+				{Position: 8, Length: 3, Term: "foo"},
+			},
+			Version: 2,
+		},
+	}, expRes)
+
+	findthem.SetMatchReporting(false)
+	expRes, err = findthem.ProcessText("foo bar")
+	assert.Nil(err)
+	assert.Nil(expRes[0].Matches)
+}
+
+func TestSubstringEnginesByteOffsets(t *testing.T) {
+	assert := assert.New(t)
+	text := "çã foo ação bar"
+	keywords := map[string]struct{}{"ação": {}, "bar": {}}
+	expected := []*Match{
+		{Position: 9, Length: 6, Term: "ação"},
+		{Position: 16, Length: 3, Term: "bar"},
+	}
+
+	engines := map[string]SubstringEngine{
+		"aho-corasick":    &AhoCorasickEngine{},
+		"anknown":         &AnknownEngine{},
+		"cloudflare fork": &CloudflareForkEngine{},
+	}
+	for name, eng := range engines {
+		assert.Nil(eng.BuildEngine(keywords, true), name)
+		matches, err := eng.FindSubstrings(text)
+		assert.Nil(err, name)
+		assert.Equal(expected, matches, name)
+	}
+}
+
+func TestProcessTextCaseFoldingEngines(t *testing.T) {
+	assert := assert.New(t)
+	// the kelvin sign is shorter on lowercase, so the positions show if the text was copied
+	text := "\u212Aey FOO BAR"
+
+	findthem := NewFinder(&AhoCorasickEngine{}, &RegexpEngine{}, false)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpression(`"key" and "foo" and r"ba[rz]"`))
+	assert.False(findthem.needsLowercaseText())
+	expRes, err := findthem.ProcessText(text)
+	assert.Nil(err)
+	assert.Len(expRes, 1)
+	assert.Equal([]*Match{
+		{Position: 0, Length: 5, Term: "key"},
+		{Position: 6, Length: 3, Term: "foo"},
+		{Position: 10, Length: 3, Term: "ba[rz]"},
+	}, expRes[0].Matches)
+
+	// the wildcards are compared to the lowercase text
+	assert.Nil(findthem.AddExpression(`"fo*"`))
+	assert.True(findthem.needsLowercaseText())
+
+	findthem = NewFinder(&AnknownEngine{}, &RegexpEngine{}, false)
+	assert.Nil(findthem.AddExpression(`"foo"`))
+	assert.True(findthem.needsLowercaseText())
+
+	findthem = NewFinder(&AhoCorasickEngine{}, &RegexpEngine{}, true)
+	assert.Nil(findthem.AddExpression(`"foo"`))
+	assert.False(findthem.needsLowercaseText())
+}
+
+func TestProcessTextConcurrently(t *testing.T) {
+	assert := assert.New(t)
+	engines := map[string]SubstringEngine{
+		"aho-corasick":    &AhoCorasickEngine{},
+		"anknown":         &AnknownEngine{},
+		"cloudflare":      &CloudflareEngine{},
+		"cloudflare fork": &CloudflareForkEngine{},
+	}
+
+	for name, eng := range engines {
+		findthem := NewFinder(eng, &RegexpEngine{}, false)
+		findthem.SetMatchReporting(true)
+		assert.Nil(findthem.AddExpressionWithTag(`"foo" and r"ba[rz]"`, "tag"), name)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					expRes, err := findthem.ProcessText("foo bar baz qux")
+					assert.Nil(err, name)
+					if assert.NotEmpty(expRes, name) {
+						assert.Equal(0, expRes[0].ExpresionIndex, name)
+						assert.Equal("tag", expRes[0].Tag, name)
+					}
+				}
+			}()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				assert.Nil(findthem.AddExpression(fmt.Sprintf(`"qux" or "term%d"`, j)), name)
+				assert.NotEmpty(findthem.GetKeywords(), name)
+			}
+		}()
+		wg.Wait()
+
+		expRes, err := findthem.ProcessText("foo bar baz qux")
+		assert.Nil(err, name)
+		assert.Len(expRes, 21, name)
+	}
+}
+
+func TestReplaceExpressions(t *testing.T) {
+	assert := assert.New(t)
+	findthem, err := NewFinderWithExpressions(
+		&CloudflareForkEngine{},
+		&RegexpEngine{},
+		false,
+		map[string][]string{"old": {`"foo"`}},
+	)
+	assert.Nil(err)
+	assert.Equal(uint64(1), findthem.Version())
+
+	_, err = findthem.ReplaceExpressions(map[string][]string{"new": {`"bar"`, `"foo`}})
+	assert.NotNil(err, "malformed expression")
+	assert.Equal(uint64(1), findthem.Version(), "finder unchanged after error")
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				expRes, err := findthem.ProcessText("foo bar")
+				assert.Nil(err)
+				if assert.Len(expRes, 1) {
+					switch expRes[0].Tag {
+					case "old":
+						assert.Equal(`"foo"`, expRes[0].ExpresionStr)
+					case "new":
+						assert.Equal(`"bar"`, expRes[0].ExpresionStr)
+						assert.Equal(uint64(2), expRes[0].Version)
+					default:
+						t.Errorf("unexpected tag %s", expRes[0].Tag)
+					}
+				}
+			}
+		}()
+	}
+
+	subEng, rgxEng := findthem.subEng, findthem.rgxEng
+	version, err := findthem.ReplaceExpressions(map[string][]string{"new": {`"bar"`}})
+	close(stop)
+	wg.Wait()
+	assert.Nil(err)
+	assert.Equal(uint64(2), version)
+	assert.Equal(map[string]struct{}{"bar": {}}, findthem.GetKeywords())
+
+	assert.IsType(&CloudflareForkEngine{}, findthem.subEng)
+	assert.IsType(&RegexpEngine{}, findthem.rgxEng)
+	assert.False(subEng == findthem.subEng, "the substring engine in use is not rebuilt")
+	assert.False(rgxEng == findthem.rgxEng, "the regex engine in use is not rebuilt")
+
+	expRes, err := findthem.ProcessText("foo bar")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{
+		{ExpresionIndex: 0, ExpresionStr: `"bar"`, Tag: "new", Version: 2},
+	}, expRes)
+}
+
+// prefixFuzzyEngine implements FuzzyEngine finding the
+// words of the text that start with the fuzzy terms.
+type prefixFuzzyEngine struct {
+	terms map[string]dsl.FuzzyTerm
+}
+
+func (pfe *prefixFuzzyEngine) NewEngine() interface{} {
+	return &prefixFuzzyEngine{}
+}
+
+func (pfe *prefixFuzzyEngine) BuildEngine(terms map[string]dsl.FuzzyTerm, caseSensitive bool) (err error) {
+	pfe.terms = terms
+	return
+}
+
+func (pfe *prefixFuzzyEngine) FindFuzzy(text string) (matches []*Match, err error) {
+	for _, span := range wordSpans(text) {
+		for key, ft := range pfe.terms {
+			if strings.HasPrefix(text[span[0]:span[1]], ft.Term) {
+				matches = append(matches, &Match{Position: span[0], Length: span[1] - span[0], Term: key})
+			}
+		}
+	}
+	return
+}
+
+func TestReplaceExpressionsEngines(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&AhoCorasickEngine{Mode: LeftmostLongestMatches}, NewPrefilterRegexEngine(nil), false)
+	findthem.SetFuzzyEngine(&prefixFuzzyEngine{})
+	assert.Nil(findthem.AddExpression(`F1"foo"`))
+
+	_, err := findthem.ReplaceExpressions(map[string][]string{"": {`F1"bar" and "baz" and R"qu+x"`}})
+	assert.Nil(err)
+	assert.Equal(&AhoCorasickEngine{Mode: LeftmostLongestMatches}, findthem.subEng.(*AhoCorasickEngine).NewEngine())
+	assert.IsType(&PrefilterRegexEngine{}, findthem.rgxEng)
+	assert.IsType(&prefixFuzzyEngine{}, findthem.fuzzyEng, "the fuzzy engine is kept")
+	expRes, err := findthem.ProcessText("barbecue baz quux")
+	assert.Nil(err)
+	assert.Len(expRes, 1)
+
+	findthem = NewFinder(new(SubstringEngineMock), &RegexpEngine{}, false)
+	_, err = findthem.ReplaceExpressions(map[string][]string{"": {`"a"`}})
+	assert.EqualError(err, "the engine *finder.SubstringEngineMock can not be replicated")
+
+	findthem = NewFinder(nil, nil, false)
+	_, err = findthem.ReplaceExpressions(map[string][]string{"": {`"a"`}})
+	assert.Nil(err, "an engine without terms is not built")
+	expRes, err = findthem.ProcessText("a")
+	assert.Nil(err)
+	assert.Len(expRes, 1)
+}
+
+// blockingFuzzyEngine implements FuzzyEngine as the prefixFuzzyEngine,
+// blocking the build of its replicas until release is closed.
+type blockingFuzzyEngine struct {
+	prefixFuzzyEngine
+	building chan struct{}
+	release  chan struct{}
+}
+
+func (bfe *blockingFuzzyEngine) NewEngine() interface{} {
+	return &blockingFuzzyEngine{building: bfe.building, release: bfe.release}
+}
+
+func (bfe *blockingFuzzyEngine) BuildEngine(terms map[string]dsl.FuzzyTerm, caseSensitive bool) (err error) {
+	select {
+	case <-bfe.building:
+	default:
+		close(bfe.building)
+	}
+	<-bfe.release
+	return bfe.prefixFuzzyEngine.BuildEngine(terms, caseSensitive)
+}
+
+func TestReplaceExpressionsSettings(t *testing.T) {
+	assert := assert.New(t)
+	fuzzyEng := &blockingFuzzyEngine{building: make(chan struct{}), release: make(chan struct{})}
+	findthem := NewFinder(&AhoCorasickEngine{}, &RegexpEngine{}, false)
+	findthem.SetFuzzyEngine(fuzzyEng)
+
+	replaced := make(chan error)
+	go func() {
+		_, err := findthem.ReplaceExpressions(map[string][]string{"": {`"café" or F1"foo"`}})
+		replaced <- err
+	}()
+	<-fuzzyEng.building
+
+	normalized := make(chan error, 1)
+	go func() {
+		normalized <- findthem.SetNormalization(dsl.DiacriticStripping)
+	}()
+	select {
+	case err := <-normalized:
+		assert.Fail("the normalization is set while the expressions are replaced")
+		normalized <- err
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(fuzzyEng.release)
+	assert.Nil(<-replaced)
+	assert.Nil(<-normalized)
+
+	assert.NotNil(findthem.normalizer, "the normalization is not reverted by the swap")
+	expRes, err := findthem.ProcessText("CAFE")
+	assert.Nil(err)
+	assert.Len(expRes, 1)
+}
+
+func TestRemoveAndUpdateExpressions(t *testing.T) {
+	assert := assert.New(t)
+	subMock := new(SubstringEngineMock)
+	rgxMock := new(RegexEngineMock)
+	findthem := NewFinder(subMock, rgxMock, true)
+	assert.Nil(findthem.AddExpressionWithTag(`"a" and "b"`, "t1"))
+	assert.Nil(findthem.AddExpressionWithTag(`"b" or r"c"`, "t2"))
+	assert.Nil(findthem.AddExpressionWithTag(`"d" and r"c"`, "t1"))
+
+	assert.Equal([]ExpressionInfo{
+		{Index: 0, Expression: `"a" and "b"`, Tag: "t1"},
+		{Index: 2, Expression: `"d" and r"c"`, Tag: "t1"},
+	}, findthem.GetExpressionsByTag("t1"))
+
+	removed, err := findthem.RemoveExpressionsByTag("t1")
+	assert.Nil(err)
+	assert.Equal(2, removed)
+	assert.Equal(map[string]struct{}{"b": {}}, findthem.GetKeywords())
+	assert.Equal(map[string]struct{}{"c": {}}, findthem.GetRegexes())
+	assert.Equal([]ExpressionInfo{
+		{Index: 0, Expression: `"b" or r"c"`, Tag: "t2"},
+	}, findthem.GetExpressions())
+
+	removed, err = findthem.RemoveExpressionsByTag("t1")
+	assert.Nil(err)
+	assert.Equal(0, removed)
+
+	assert.Nil(findthem.UpdateExpression(0, `"e" and not "b"`, "t3"))
+	assert.Equal(map[string]struct{}{"b": {}, "e": {}}, findthem.GetKeywords())
+	assert.Equal(map[string]struct{}{}, findthem.GetRegexes())
+	info, err := findthem.GetExpression(0)
+	assert.Nil(err)
+	assert.Equal(ExpressionInfo{Index: 0, Expression: `"e" and not "b"`, Tag: "t3"}, info)
+
+	assert.NotNil(findthem.UpdateExpression(0, `"e" and`, "t3"), "malformed expression")
+	assert.NotNil(findthem.UpdateExpression(1, `"e"`, "t3"), "index out of range")
+	assert.NotNil(findthem.RemoveExpression(1), "index out of range")
+	_, err = findthem.GetExpression(-1)
+	assert.NotNil(err, "index out of range")
+
+	subMock.On("BuildEngine", map[string]struct{}{"b": {}, "e": {}}).Return(nil)
+	subMock.On("FindSubstrings", "e").Return([]*Match{{Position: 0, Length: 1, Term: "e"}}, nil)
+	expRes, err := findthem.ProcessText("e")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{
+		{ExpresionIndex: 0, ExpresionStr: `"e" and not "b"`, Tag: "t3", Version: 5},
+	}, expRes)
+	subMock.AssertNumberOfCalls(t, "BuildEngine", 1)
+	rgxMock.AssertNotCalled(t, "BuildEngine", mock.Anything)
+
+	assert.Nil(findthem.UpdateExpression(0, `"e" and not "b"`, "t4"))
+	assert.True(findthem.updatedSubMachine, "the terms kept by the update do not outdate the engine")
+	expRes, err = findthem.ProcessText("e")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{
+		{ExpresionIndex: 0, ExpresionStr: `"e" and not "b"`, Tag: "t4", Version: 6},
+	}, expRes)
+	subMock.AssertNumberOfCalls(t, "BuildEngine", 1)
+
+	assert.Nil(findthem.RemoveExpression(0))
+	assert.Empty(findthem.GetExpressions())
+	assert.Equal(map[string]struct{}{}, findthem.GetKeywords())
+}
+
+// assertSameTerms asserts that the finders have the same terms and reference counts.
+func assertSameTerms(assert *assert.Assertions, expected *Finder, actual *Finder, message string) {
+	refCounts := func(finder *Finder) []map[string]int {
+		counts := []map[string]int{
+			finder.keywordsRefCount,
+			finder.regexesRefCount,
+			finder.wordKeywordsRefCount,
+			finder.wildcardsRefCount,
+			finder.fuzzyTermsRefCount,
+			finder.caseKeywordsRefCount,
+		}
+		// the maps are only created when a term is added
+		for i := range counts {
+			if len(counts[i]) == 0 {
+				counts[i] = nil
+			}
+		}
+		return counts
+	}
+	assert.Equal(refCounts(expected), refCounts(actual), message)
+	assert.Equal(len(expected.regexLiterals), len(actual.regexLiterals), message)
+	for rgx, lits := range expected.regexLiterals {
+		assert.Equal(lits, actual.regexLiterals[rgx], message)
+	}
+	assert.Equal(expected.wordDistanceExprs, actual.wordDistanceExprs, message)
+}
+
+func TestRemoveExpressionsTerms(t *testing.T) {
+	assert := assert.New(t)
+	expressions := []struct {
+		expression string
+		tag        string
+	}{
+		{`"a" and R"order #[0-9]+" and W"cat"`, "t1"},
+		{`"a" NEAR/2 F1"refund" and "b*c"`, "t2"},
+		{`C"US" or W"cat" or R"order #[0-9]+"`, "t1"},
+		{`INORD/2("a" and "d") and F1"refund"`, "t1"},
+		{`"b*c" and C"US"`, "t2"},
+	}
+
+	newFinder := func(skipTag string) *Finder {
+		findthem := NewFinder(nil, &RegexpEngine{}, false)
+		findthem.SetRegexPrefilter(true)
+		for _, exp := range expressions {
+			if exp.tag != skipTag {
+				assert.Nil(findthem.AddExpressionWithTag(exp.expression, exp.tag))
+			}
+		}
+		return findthem
+	}
+
+	for _, tag := range []string{"t1", "t2"} {
+		findthem := newFinder("")
+		removed, err := findthem.RemoveExpressionsByTag(tag)
+		assert.Nil(err)
+		assert.Greater(removed, 1)
+		assertSameTerms(assert, newFinder(tag), findthem, "removed "+tag)
+	}
+
+	findthem := newFinder("")
+	for i := len(expressions) - 1; i >= 0; i-- {
+		assert.Nil(findthem.UpdateExpression(i, `"z"`, ""))
+		assert.Nil(findthem.RemoveExpression(i))
+	}
+	assertSameTerms(assert, NewFinder(nil, &RegexpEngine{}, false), findthem, "removed all")
+}
+
+// countdownContext is a context that is done after its Err method is called n times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (cc *countdownContext) Err() error {
+	if cc.n <= 0 {
+		return context.Canceled
+	}
+	cc.n--
+	return nil
+}
+
+func TestProcessTextContext(t *testing.T) {
+	assert := assert.New(t)
+	findthem, err := NewFinderWithExpressions(
+		&CloudflareForkEngine{},
+		&RegexpEngine{},
+		false,
+		map[string][]string{"test": {`"a"`, `"b"`, `r"c+"`}},
+	)
+	assert.Nil(err)
+
+	tests := []struct {
+		ctxErrCalls   int
+		expectedResp  []ExpressionResult
+		expectedStage string
+		processed     int
+		message       string
+	}{
+		{
+			ctxErrCalls:   0,
+			expectedResp:  nil,
+			expectedStage: SubstringSearchStage,
+			message:       "done before the substring search",
+		},
+		{
+			ctxErrCalls:   1,
+			expectedResp:  nil,
+			expectedStage: RegexSearchStage,
+			message:       "done before the regex search",
+		},
+		{
+			ctxErrCalls: 3,
+			expectedResp: []ExpressionResult{
+				{ExpresionIndex: 0, ExpresionStr: `"a"`, Tag: "test", Version: 3},
+			},
+			expectedStage: ExpressionsStage,
+			processed:     1,
+			message:       "done on the expressions evaluation",
+		},
+	}
+
+	for _, tc := range tests {
+		ctx := &countdownContext{Context: context.Background(), n: tc.ctxErrCalls}
+		expRes, err := findthem.ProcessTextContext(ctx, "a b cc")
+		assert.Equal(tc.expectedResp, expRes, tc.message)
+		var partialErr *PartialResultError
+		if assert.True(errors.As(err, &partialErr), tc.message) {
+			assert.Equal(tc.expectedStage, partialErr.Stage, tc.message)
+			assert.Equal(tc.processed, partialErr.Processed, tc.message)
+			assert.Equal(3, partialErr.Total, tc.message)
+			assert.True(errors.Is(err, context.Canceled), tc.message)
+		}
+	}
+
+	expRes, err := findthem.ProcessTextContext(context.Background(), "a b cc")
+	assert.Nil(err)
+	assert.Len(expRes, 3)
+}
+
+func TestProcessTextNear(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	assert.Nil(findthem.AddExpressionWithTag(`"account" NEAR/3 "suspended"`, "word"))
+	assert.Nil(findthem.AddExpressionWithTag(`r"acc[a-z]+" ONEAR/20c "suspended"`, "char"))
+	assert.Equal(1, findthem.wordDistanceExprs)
+
+	expRes, err := findthem.ProcessText("Your Account was Suspended")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{
+		{ExpresionIndex: 0, ExpresionStr: `"account" NEAR/3 "suspended"`, Tag: "word", Version: 2},
+		{ExpresionIndex: 1, ExpresionStr: `r"acc[a-z]+" ONEAR/20c "suspended"`, Tag: "char", Version: 2},
+	}, expRes)
+
+	expRes, err = findthem.ProcessText("suspended: the account of the user was not found")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{
+		{ExpresionIndex: 0, ExpresionStr: `"account" NEAR/3 "suspended"`, Tag: "word", Version: 2},
+	}, expRes)
+
+	expRes, err = findthem.ProcessText("the account of the user that was created yesterday is suspended")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{}, expRes)
+
+	assert.Nil(findthem.UpdateExpression(0, `"account" NEAR/3c "suspended"`, "char"))
+	assert.Equal(0, findthem.wordDistanceExprs)
+	assert.Nil(findthem.UpdateExpression(0, `"account" NEAR/3 "suspended"`, "word"))
+	assert.Equal(1, findthem.wordDistanceExprs)
+	assert.Nil(findthem.RemoveExpression(0))
+	assert.Equal(0, findthem.wordDistanceExprs)
+}
+
+func TestProcessTextInordGap(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&AhoCorasickEngine{}, &RegexpEngine{}, false)
+	assert.Nil(findthem.AddExpression(`INORD/2("alpha" and "beta")`))
+	assert.Equal(1, findthem.wordDistanceExprs)
+
+	expected := []ExpressionResult{
+		{ExpresionIndex: 0, ExpresionStr: `INORD/2("alpha" and "beta")`, Version: 1},
+	}
+	for _, text := range []string{"alpha beta", "alpha, the beta", "Alpha the BETA"} {
+		expRes, err := findthem.ProcessText(text)
+		assert.Nil(err, text)
+		assert.Equal(expected, expRes, text)
+	}
+	for _, text := range []string{"alpha and the beta", "beta alpha"} {
+		expRes, err := findthem.ProcessText(text)
+		assert.Nil(err, text)
+		assert.Equal([]ExpressionResult{}, expRes, text)
+	}
+
+	assert.Nil(findthem.UpdateExpression(0, `INORD/2c("alpha" and "beta")`, ""))
+	assert.Equal(0, findthem.wordDistanceExprs)
+	assert.Nil(findthem.UpdateExpression(0, `INORD("alpha" and "beta")`, ""))
+	assert.Equal(0, findthem.wordDistanceExprs)
+}
+
+func TestProcessTextCount(t *testing.T) {
+	assert := assert.New(t)
+	expressions := []string{
+		`COUNT("aa") >= 3`,
+		`COUNT("refund") == 2`,
+		`COUNT(r"a+") == 1`,
+	}
+	for name, eng := range map[string]SubstringEngine{
+		"anknown":         &AnknownEngine{},
+		"cloudflare fork": &CloudflareForkEngine{},
+	} {
+		findthem := NewFinder(eng, &RegexpEngine{}, false)
+		assert.Nil(findthem.AddExpressions(expressions), name)
+
+		expRes, err := findthem.ProcessText("AAAA refund Refund")
+		assert.Nil(err, name)
+		assert.Equal([]ExpressionResult{
+			{ExpresionIndex: 0, ExpresionStr: expressions[0], Version: 3},
+			{ExpresionIndex: 1, ExpresionStr: expressions[1], Version: 3},
+			{ExpresionIndex: 2, ExpresionStr: expressions[2], Version: 3},
+		}, expRes, name+": overlapping keyword matches are counted")
+
+		expRes, err = findthem.ProcessText("aaa refund a")
+		assert.Nil(err, name)
+		assert.Equal([]ExpressionResult{}, expRes, name)
+	}
+}
+
+func TestProcessTextWholeWord(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpressionWithTag(`W"cat"`, "word"))
+	assert.Nil(findthem.AddExpressionWithTag(`"cat" and not W"cat"`, "substring"))
+
+	tests := []struct {
+		text         string
+		expectedResp []ExpressionResult
+		message      string
+	}{
+		{
+			text: "the Cat, the category",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 0, ExpresionStr: `W"cat"`, Tag: "word", Version: 2,
+					Matches: []*Match{{Position: 4, Length: 3, Term: dsl.WordTermKey("cat")}},
+				},
+			},
+			message: "whole word found",
+		},
+		{
+			text: "category, bobcat, cat_1, cat2, çcatã",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 1, ExpresionStr: `"cat" and not W"cat"`, Tag: "substring", Version: 2,
+					Matches: []*Match{
+						{Position: 0, Length: 3, Term: "cat"},
+						{Position: 13, Length: 3, Term: "cat"},
+						{Position: 18, Length: 3, Term: "cat"},
+						{Position: 25, Length: 3, Term: "cat"},
+						{Position: 33, Length: 3, Term: "cat"},
+					},
+				},
+			},
+			message: "only substrings found",
+		},
+		{
+			text: "çat cat",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 0, ExpresionStr: `W"cat"`, Tag: "word", Version: 2,
+					Matches: []*Match{{Position: 5, Length: 3, Term: dsl.WordTermKey("cat")}},
+				},
+			},
+			message: "whole word at the end of the text",
+		},
+	}
+
+	for _, tc := range tests {
+		expRes, err := findthem.ProcessText(tc.text)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, expRes, tc.message)
+	}
+
+	assert.Equal(map[string]struct{}{"cat": {}}, findthem.wordKeywords)
+	_, err := findthem.RemoveExpressionsByTag("word")
+	assert.Nil(err)
+	assert.Equal(map[string]struct{}{"cat": {}}, findthem.wordKeywords)
+	_, err = findthem.RemoveExpressionsByTag("substring")
+	assert.Nil(err)
+	assert.Equal(map[string]struct{}{}, findthem.wordKeywords)
+	assert.Equal(map[string]struct{}{}, findthem.GetKeywords())
+}
+
+func TestProcessTextTermKeys(t *testing.T) {
+	assert := assert.New(t)
+	// the keys of the prefixed terms do not collide with the keywords and regexes written as them
+	findthem := NewFinder(&AhoCorasickEngine{}, &RegexpEngine{}, false)
+	assert.Nil(findthem.AddExpression(`W"cat"`))
+	assert.Nil(findthem.AddExpression(`"w\"cat\""`))
+	assert.Nil(findthem.AddExpression(`R"w\"cat\""`))
+	assert.Nil(findthem.AddExpression(`C"Cat"`))
+	assert.Nil(findthem.AddExpression(`"c\"cat\""`))
+	assert.Nil(findthem.AddExpression(`"\"ca*\""`))
+
+	expRes, err := findthem.ProcessText("the Cat")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{
+		{ExpresionIndex: 0, ExpresionStr: `W"cat"`, Version: 6},
+		{ExpresionIndex: 3, ExpresionStr: `C"Cat"`, Version: 6},
+	}, expRes)
+
+	expRes, err = findthem.ProcessText(`w"cat" c"cat" "ca*"`)
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{
+		{ExpresionIndex: 0, ExpresionStr: `W"cat"`, Version: 6},
+		{ExpresionIndex: 1, ExpresionStr: `"w\"cat\""`, Version: 6},
+		{ExpresionIndex: 2, ExpresionStr: `R"w\"cat\""`, Version: 6},
+		{ExpresionIndex: 4, ExpresionStr: `"c\"cat\""`, Version: 6},
+		{ExpresionIndex: 5, ExpresionStr: `"\"ca*\""`, Version: 6},
+	}, expRes)
+}
+
+func TestProcessTextWildcard(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpressionWithTag(`"refund*" and not "colo?r"`, "refund"))
+	assert.Nil(findthem.AddExpressionWithTag(`"colo?r"`, "colour"))
+	assert.Equal(map[string]struct{}{"refund": {}, "colo": {}}, findthem.GetKeywords())
+
+	tests := []struct {
+		text         string
+		expectedResp []ExpressionResult
+		message      string
+	}{
+		{
+			text: "Refunded and refund, the color",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 0, ExpresionStr: `"refund*" and not "colo?r"`, Tag: "refund", Version: 2,
+					Matches: []*Match{
+						{Position: 0, Length: 8, Term: dsl.WildcardTermKey("refund*")},
+						{Position: 13, Length: 6, Term: dsl.WildcardTermKey("refund*")},
+					},
+				},
+			},
+			message: "star matches zero or more characters",
+		},
+		{
+			text: "the refund colour",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 1, ExpresionStr: `"colo?r"`, Tag: "colour", Version: 2,
+					Matches: []*Match{{Position: 11, Length: 6, Term: dsl.WildcardTermKey("colo?r")}},
+				},
+			},
+			message: "question mark matches a single character",
+		},
+		{
+			text: "colour-less",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 1, ExpresionStr: `"colo?r"`, Tag: "colour", Version: 2,
+					Matches: []*Match{{Position: 0, Length: 6, Term: dsl.WildcardTermKey("colo?r")}},
+				},
+			},
+			message: "wildcards do not cross non word characters",
+		},
+	}
+
+	for _, tc := range tests {
+		expRes, err := findthem.ProcessText(tc.text)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, expRes, tc.message)
+	}
+
+	assert.Nil(findthem.RemoveExpression(1))
+	assert.Equal(2, len(findthem.wildcards))
+	assert.Nil(findthem.RemoveExpression(0))
+	assert.Equal(0, len(findthem.wildcards))
+	assert.Equal(map[string]struct{}{}, findthem.GetKeywords())
+}
+
+func TestProcessTextFuzzy(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpressionWithTag(`F1"password" and not W"password"`, "misspelled"))
+	assert.Nil(findthem.AddExpressionWithTag(`INORD("user" and F2"credit card")`, "card"))
+
+	tests := []struct {
+		text         string
+		expectedResp []ExpressionResult
+		message      string
+	}{
+		{
+			text: "reset the Pasword, passwords and pass word",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 0, ExpresionStr: `F1"password" and not W"password"`, Tag: "misspelled", Version: 2,
+					Matches: []*Match{
+						{Position: 10, Length: 7, Term: dsl.FuzzyTerm{Term: "password", Distance: 1}.Key()},
+						{Position: 19, Length: 9, Term: dsl.FuzzyTerm{Term: "password", Distance: 1}.Key()},
+						{Position: 33, Length: 9, Term: dsl.FuzzyTerm{Term: "password", Distance: 1}.Key()},
+					},
+				},
+			},
+			message: "deletions, insertions and split words",
+		},
+		{
+			text: "the user's kredit-card",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 1, ExpresionStr: `INORD("user" and F2"credit card")`, Tag: "card", Version: 2,
+					Matches: []*Match{
+						{Position: 4, Length: 4, Term: "user"},
+						{Position: 11, Length: 11, Term: dsl.FuzzyTerm{Term: "credit card", Distance: 2}.Key()},
+					},
+				},
+			},
+			message: "multiple words",
+		},
+		{
+			text:         "kredit-card of the user, paswrd",
+			expectedResp: []ExpressionResult{},
+			message:      "out of order and too distant",
+		},
+	}
+
+	for _, tc := range tests {
+		expRes, err := findthem.ProcessText(tc.text)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, expRes, tc.message)
+	}
+
+	assert.Nil(findthem.RemoveExpression(0))
+	assert.Equal(map[string]dsl.FuzzyTerm{dsl.FuzzyTerm{Term: "credit card", Distance: 2}.Key(): {Term: "credit card", Distance: 2}}, findthem.fuzzyTerms)
+	assert.False(findthem.updatedFuzzyMachine)
+}
+
+func TestProcessTextNormalization(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpressionWithTag(`"café" and W"strasse"`, "cafe"))
+	assert.Nil(findthem.AddExpressionWithTag(`"istanbul" or F1"naive"`, "city"))
+
+	expRes, err := findthem.ProcessText("CAFÉ in der Straße")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{}, expRes, "lowercase only")
+
+	assert.Nil(findthem.SetNormalization(dsl.NFKC | dsl.DiacriticStripping | dsl.WidthFolding))
+	assert.Equal(map[string]struct{}{"cafe": {}, "strasse": {}, "istanbul": {}}, findthem.GetKeywords())
+	assert.Equal(uint64(3), findthem.Version())
+	findthem.SetStreamOptions(3, 8)
+
+	tests := []struct {
+		text         string
+		expectedResp []ExpressionResult
+		message      string
+	}{
+		{
+			text: "Ｃafé in der Straße",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 0, ExpresionStr: `"café" and W"strasse"`, Tag: "cafe", Version: 3,
+					Matches: []*Match{
+						{Position: 0, Length: 7, Term: "cafe"},
+						{Position: 15, Length: 7, Term: dsl.WordTermKey("strasse")},
+					},
+				},
+			},
+			message: "positions of the original text",
+		},
+		{
+			text: "İstanbul, naïve",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 1, ExpresionStr: `"istanbul" or F1"naive"`, Tag: "city", Version: 3,
+					Matches: []*Match{
+						{Position: 0, Length: 9, Term: "istanbul"},
+						{Position: 11, Length: 6, Term: dsl.FuzzyTerm{Term: "naive", Distance: 1}.Key()},
+					},
+				},
+			},
+			message: "case folding and fuzzy terms",
+		},
+	}
+
+	for _, tc := range tests {
+		expRes, err := findthem.ProcessText(tc.text)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, expRes, tc.message)
+
+		expRes, err = findthem.ProcessReader(strings.NewReader(tc.text))
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, expRes, tc.message+" reader")
+	}
+
+	assert.Nil(findthem.SetNormalization(0))
+	assert.Equal(map[string]struct{}{"café": {}, "strasse": {}, "istanbul": {}}, findthem.GetKeywords())
+}
+
+func TestProcessTextCaseTerms(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpressionWithTag(`C"US" and "visa"`, "acronym"))
+	assert.Nil(findthem.AddExpressionWithTag(`"us" and not C"US"`, "word"))
+	assert.Equal(map[string]struct{}{"us": {}, "visa": {}}, findthem.GetKeywords())
+
+	tests := []struct {
+		text         string
+		expectedResp []ExpressionResult
+		message      string
+	}{
+		{
+			text: "US VISA for us",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 0, ExpresionStr: `C"US" and "visa"`, Tag: "acronym", Version: 2,
+					Matches: []*Match{
+						{Position: 0, Length: 2, Term: dsl.CaseTermKey("US")},
+						{Position: 3, Length: 4, Term: "visa"},
+					},
+				},
+			},
+			message: "exact case found",
+		},
+		{
+			text: "a Visa for Us",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 1, ExpresionStr: `"us" and not C"US"`, Tag: "word", Version: 2,
+					Matches: []*Match{{Position: 11, Length: 2, Term: "us"}},
+				},
+			},
+			message: "exact case not found",
+		},
+	}
+
+	for _, tc := range tests {
+		expRes, err := findthem.ProcessText(tc.text)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, expRes, tc.message)
+	}
+
+	assert.Nil(findthem.SetNormalization(dsl.WidthFolding))
+	findthem.SetStreamOptions(1, 8)
+	expRes, err := findthem.ProcessText("ＵＳ Visa")
+	assert.Nil(err)
+	expected := []ExpressionResult{
+		{
+			ExpresionIndex: 0, ExpresionStr: `C"US" and "visa"`, Tag: "acronym", Version: 3,
+			Matches: []*Match{
+				{Position: 0, Length: 6, Term: dsl.CaseTermKey("US")},
+				{Position: 7, Length: 4, Term: "visa"},
+			},
+		},
+	}
+	assert.Equal(expected, expRes, "normalized text")
+	expRes, err = findthem.ProcessReader(strings.NewReader("ＵＳ Visa"))
+	assert.Nil(err)
+	assert.Equal(expected, expRes, "normalized reader")
+
+	assert.Nil(findthem.RemoveExpression(0))
+	assert.Equal(map[string]string{"US": "us"}, findthem.caseKeywords)
+	assert.Nil(findthem.RemoveExpression(0))
+	assert.Equal(map[string]string{}, findthem.caseKeywords)
+}
+
+func TestProcessTextRegexFlags(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpression(`R"\\S+@\\S+" and not R"\\W{3}"`))
+	assert.Nil(findthem.AddExpression(`R"[A-Z]{3}-\\d+"`))
+	assert.Equal(map[string]struct{}{`\S+@\S+`: {}, `\W{3}`: {}, `[A-Z]{3}-\d+`: {}}, findthem.GetRegexes())
+
+	expRes, err := findthem.ProcessText("Mail Bob@Example.com about ABC-123")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{
+		{
+			ExpresionIndex: 0, ExpresionStr: `R"\\S+@\\S+" and not R"\\W{3}"`, Version: 2,
+			Matches: []*Match{{Position: 5, Length: 15, Term: `\S+@\S+`}},
+		},
+		{
+			ExpresionIndex: 1, ExpresionStr: `R"[A-Z]{3}-\\d+"`, Version: 2,
+			Matches: []*Match{{Position: 27, Length: 7, Term: `[A-Z]{3}-\d+`}},
+		},
+	}, expRes, "character classes on case insensitive finder")
+
+	expRes, err = findthem.ProcessText("Mail Bob@Example.com ... ")
+	assert.Nil(err)
+	assert.Equal([]ExpressionResult{}, expRes, "non word characters")
+
+	findthem = NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, true)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpression(`R"total: \\d+"/i and R"^end$"/m`))
+	assert.Nil(findthem.AddExpression(`R"begin.+end"/s`))
+	assert.Equal(map[string]struct{}{`(?i)total: \d+`: {}, `(?m)^end$`: {}, `(?s)begin.+end`: {}}, findthem.GetRegexes())
+
+	tests := []struct {
+		text         string
+		expectedResp []ExpressionResult
+		message      string
+	}{
+		{
+			text: "TOTAL: 42\nend\n",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 0, ExpresionStr: `R"total: \\d+"/i and R"^end$"/m`, Version: 2,
+					Matches: []*Match{
+						{Position: 0, Length: 9, Term: `(?i)total: \d+`},
+						{Position: 10, Length: 3, Term: `(?m)^end$`},
+					},
+				},
+			},
+			message: "case insensitive and multiline flags",
+		},
+		{
+			text: "begin\nend",
+			expectedResp: []ExpressionResult{
+				{
+					ExpresionIndex: 1, ExpresionStr: `R"begin.+end"/s`, Version: 2,
+					Matches: []*Match{{Position: 0, Length: 9, Term: `(?s)begin.+end`}},
+				},
+			},
+			message: "dot all flag",
+		},
+		{
+			text:         "Total: 1 end",
+			expectedResp: []ExpressionResult{},
+			message:      "multiline anchors not found",
+		},
+	}
+
+	for _, tc := range tests {
+		expRes, err := findthem.ProcessText(tc.text)
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, expRes, tc.message)
+
+		expRes, err = findthem.ProcessReader(strings.NewReader(tc.text))
+		assert.Nil(err, tc.message)
+		assert.Equal(tc.expectedResp, expRes, tc.message)
+	}
+}
+
+func TestPrefilterLiteral(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		finder   *Finder
+		lit      string
+		foldCase bool
+		expected string
+		ok       bool
+		message  string
+	}{
+		{finder: NewFinder(&EmptyEngine{}, &RegexpEngine{}, true), lit: "Order #", expected: "Order #", ok: true, message: "case sensitive"},
+		{finder: NewFinder(&EmptyEngine{}, &RegexpEngine{}, true), lit: "Order #", foldCase: true, ok: false, message: "case insensitive literal on case sensitive finder"},
+		{finder: NewFinder(&EmptyEngine{}, &RegexpEngine{}, true), lit: "Order #123", foldCase: true, expected: " #123", ok: true, message: "caseless runes of a case insensitive literal on case sensitive finder"},
+		{finder: NewFinder(&EmptyEngine{}, &RegexpEngine{}, true), lit: "ab", ok: false, message: "short literal"},
+		{finder: NewFinder(&EmptyEngine{}, &RegexpEngine{}, false), lit: "Order #", foldCase: true, expected: "order #", ok: true, message: "case insensitive"},
+		{finder: NewFinder(&EmptyEngine{}, &RegexpEngine{}, false), lit: "Orders #", foldCase: true, expected: "order", ok: true, message: "rune with two lowercase forms"},
+		{finder: NewFinder(&EmptyEngine{}, &RegexpEngine{}, false), lit: "ÇÃO", foldCase: true, expected: "ção", ok: true, message: "non ASCII runes"},
+	}
+
+	for _, tc := range tests {
+		lit, ok := tc.finder.prefilterLiteral([]rune(tc.lit), tc.foldCase)
+		assert.Equal(tc.ok, ok, tc.message)
+		if ok {
+			assert.Equal(tc.expected, lit, tc.message)
+		}
+	}
+
+	findthem := NewFinder(&EmptyEngine{}, &RegexpEngine{}, false)
+	assert.Nil(findthem.SetNormalization(dsl.DiacriticStripping))
+	lit, ok := findthem.prefilterLiteral([]rune("Orders #"), true)
+	assert.True(ok)
+	assert.Equal("orders #", lit, "normalized with case folding")
+	lit, ok = findthem.prefilterLiteral([]rune("café bar"), true)
+	assert.True(ok)
+	assert.Equal(" bar", lit, "combining rune")
+}
+
+func TestProcessTextRegexPrefilter(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, true)
+	findthem.SetMatchReporting(true)
+	assert.Nil(findthem.AddExpression(`R"order #[0-9]+" and "refund"`))
+	assert.Nil(findthem.AddExpression(`R"[0-9]{3}-[0-9]{4}" or R"(chargeback|dispute)s?"/i`))
+	assert.Nil(findthem.AddExpression(`R"Total: \\d+"`))
+	assert.Equal(map[string][]string{
+		`order #[0-9]+`: {"order #"},
+		`Total: \d+`:    {"Total: "},
+	}, findthem.regexLiterals)
+	assert.Equal(map[string]struct{}{"refund": {}}, findthem.GetKeywords())
+
+	texts := []string{
+		"refund for order #12, call 555-1234",
+		"Chargebacks on Total: 30",
+		"nothing here",
+		"refund order #",
+	}
+	expected := make([][]ExpressionResult, len(texts))
+	for i, text := range texts {
+		expRes, err := findthem.ProcessText(text)
+		assert.Nil(err)
+		expected[i] = expRes
+	}
+	// the first regex is not needed on the texts without "refund"
+	assert.Equal(RegexPrefilterStats{Executed: 14, Skipped: 2}, findthem.GetRegexPrefilterStats())
+
+	findthem.SetRegexPrefilter(true)
+	for i, text := range texts {
+		expRes, err := findthem.ProcessText(text)
+		assert.Nil(err)
+		assert.Equal(expected[i], expRes, text)
+	}
+	// the regexes without literals always run and the ones with literals only
+	// run on the texts with their literals: 3 + 3 + 2 + 3 executions
+	assert.Equal(RegexPrefilterStats{Executed: 14 + 11, Skipped: 2 + 5}, findthem.GetRegexPrefilterStats())
+	assert.Equal(map[string]struct{}{"refund": {}}, findthem.GetKeywords())
+
+	findthem.SetStreamOptions(4, 10)
+	for i, text := range texts {
+		expRes, err := findthem.ProcessReader(strings.NewReader(text))
+		assert.Nil(err)
+		assert.Equal(expected[i], expRes, text)
+	}
+
+	assert.Nil(findthem.RemoveExpression(2))
+	assert.Equal(map[string][]string{`order #[0-9]+`: {"order #"}}, findthem.regexLiterals)
+	assert.Equal(map[string][]string{"order #": {`order #[0-9]+`}}, findthem.regexesByLiteral)
+}
+
+func TestProcessTextRegexPrefilterCaseInsensitive(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	findthem.SetRegexPrefilter(true)
+	assert.Nil(findthem.AddExpression(`R"ORDER #\\d+"`))
+	assert.Nil(findthem.AddExpression(`R"Straße \\d+"`))
+
+	tests := []struct {
+		text          string
+		expectedIndex []int
+	}{
+		{text: "Order #12", expectedIndex: []int{0}},
+		{text: "STRASSE 1 or straße 2", expectedIndex: []int{1}},
+		{text: "orders", expectedIndex: []int{}},
+	}
+	for _, tc := range tests {
+		expRes, err := findthem.ProcessText(tc.text)
+		assert.Nil(err, tc.text)
+		indexes := make([]int, 0)
+		for _, res := range expRes {
+			indexes = append(indexes, res.ExpresionIndex)
+		}
+		assert.Equal(tc.expectedIndex, indexes, tc.text)
+	}
+	assert.Equal(RegexPrefilterStats{Executed: 2, Skipped: 4}, findthem.GetRegexPrefilterStats())
+}
+
+// countingFuzzyEngine counts the searches of a FuzzyEngine.
+type countingFuzzyEngine struct {
+	FuzzyEngine
+	searches int
+}
+
+func (cfe *countingFuzzyEngine) FindFuzzy(text string) (matches []*Match, err error) {
+	cfe.searches++
+	return cfe.FuzzyEngine.FindFuzzy(text)
+}
+
+func TestProcessTextSkipsUnneededTerms(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, true)
+	fuzzyEng := &countingFuzzyEngine{FuzzyEngine: &LevenshteinEngine{}}
+	findthem.SetFuzzyEngine(fuzzyEng)
+	assert.Nil(findthem.AddExpression(`"refund" and R"order #[0-9]+"`))
+	assert.Nil(findthem.AddExpression(`"invoice" or R"[0-9]{3}-[0-9]{4}"`))
+	assert.Nil(findthem.AddExpression(`"user" and F1"password"`))
+
+	tests := []struct {
+		text          string
+		expectedIndex []int
+		expectedStats RegexPrefilterStats
+		searches      int
+		message       string
+	}{
+		{
+			text:          "order #12, call 555-1234",
+			expectedIndex: []int{1},
+			expectedStats: RegexPrefilterStats{Executed: 1, Skipped: 1},
+			searches:      0,
+			message:       "decided by the missing keywords",
+		},
+		{
+			text:          "invoice of the user passwrd: refund order #3",
+			expectedIndex: []int{0, 1, 2},
+			expectedStats: RegexPrefilterStats{Executed: 2, Skipped: 2},
+			searches:      1,
+			message:       "decided by the found keyword",
+		},
+		{
+			text:          "refund for the user",
+			expectedIndex: []int{},
+			expectedStats: RegexPrefilterStats{Executed: 4, Skipped: 2},
+			searches:      2,
+			message:       "all terms needed",
+		},
+	}
+
+	for _, tc := range tests {
+		expRes, err := findthem.ProcessText(tc.text)
+		assert.Nil(err, tc.message)
+		indexes := make([]int, 0)
+		for _, res := range expRes {
+			indexes = append(indexes, res.ExpresionIndex)
+		}
+		assert.Equal(tc.expectedIndex, indexes, tc.message)
+		assert.Equal(tc.expectedStats, findthem.GetRegexPrefilterStats(), tc.message)
+		assert.Equal(tc.searches, fuzzyEng.searches, tc.message)
+	}
+
+	// the matches of the regexes of the true expressions are reported
+	findthem.SetMatchReporting(true)
+	expRes, err := findthem.ProcessText("invoice 555-1234")
+	assert.Nil(err)
+	assert.Equal([]*Match{
+		{Position: 0, Length: 7, Term: "invoice"},
+		{Position: 8, Length: 8, Term: "[0-9]{3}-[0-9]{4}"},
+	}, expRes[0].Matches)
+	assert.Equal(RegexPrefilterStats{Executed: 5, Skipped: 3}, findthem.GetRegexPrefilterStats())
+	assert.Equal(2, fuzzyEng.searches)
+}
+
+func TestSharingStats(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, true)
+	assert.Nil(findthem.AddExpression(`("a" and "b") or "c"`))
+	assert.Nil(findthem.AddExpression(`("a" and "b") and "d"`))
+	assert.Nil(findthem.AddExpression(`("a" and "b") or "c"`))
+	assert.Equal(dsl.DAGStats{Nodes: 15, UniqueNodes: 7}, findthem.GetSharingStats())
+
+	expRes, err := findthem.ProcessText("a b d")
+	assert.Nil(err)
+	indexes := make([]int, 0)
+	for _, res := range expRes {
+		indexes = append(indexes, res.ExpresionIndex)
+	}
+	assert.Equal([]int{0, 1, 2}, indexes)
+
+	assert.Nil(findthem.UpdateExpression(2, `"c" or "d"`, ""))
+	assert.Equal(dsl.DAGStats{Nodes: 13, UniqueNodes: 8}, findthem.GetSharingStats())
+	assert.Nil(findthem.RemoveExpression(1))
+	assert.Equal(dsl.DAGStats{Nodes: 8, UniqueNodes: 7}, findthem.GetSharingStats())
+
+	_, err = findthem.ReplaceExpressions(map[string][]string{"": {`"a"`}})
+	assert.Nil(err)
+	assert.Equal(dsl.DAGStats{Nodes: 1, UniqueNodes: 1}, findthem.GetSharingStats())
+}
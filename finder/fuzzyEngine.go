@@ -0,0 +1,165 @@
+package finder
+
+import (
+	"unicode/utf8"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+)
+
+// FuzzyEngine interface that finder uses to search
+// for the fuzzy terms on a given text
+type FuzzyEngine interface {
+	// BuildEngine receive the unique fuzzy terms by their keys
+	// to create the engine support structures
+	BuildEngine(terms map[string]dsl.FuzzyTerm, caseSensitive bool) (err error)
+	// FindFuzzy receive the text and searchs for the feeded fuzzy terms.
+	// The Term of the matches must be the key of the fuzzy term.
+	// The matches must start and end on word boundaries (see dsl.IsWordRune) and
+	// have at most the number of words of the term plus its distance, so the text
+	// can be searched in chunks by ProcessReader. It must be safe to call
+	// FindFuzzy from multiple goroutines at once.
+	FindFuzzy(text string) (matches []*Match, err error)
+}
+
+// LevenshteinEngine implements FuzzyEngine comparing the sequences of words of
+// the text with the terms using the Levenshtein distance. A sequence of words
+// is found if it has up to the distance of the term edits, so "pasword" and
+// "pass word" are found by F1"password". When more than one sequence of words
+// starting on the same word is found, only the closest one is reported.
+type LevenshteinEngine struct {
+	terms []levenshteinTerm
+}
+
+// levenshteinTerm holds the fuzzy term and the
+// information needed to compare it with the text.
+type levenshteinTerm struct {
+	key      string
+	runes    []rune
+	words    int
+	distance int
+}
+
+// BuildEngine implements BuildEngine
+func (le *LevenshteinEngine) BuildEngine(terms map[string]dsl.FuzzyTerm, caseSensitive bool) (err error) {
+	le.terms = make([]levenshteinTerm, 0, len(terms))
+	for key, ft := range terms {
+		le.terms = append(le.terms, levenshteinTerm{
+			key:      key,
+			runes:    []rune(ft.Term),
+			words:    len(wordSpans(ft.Term)),
+			distance: ft.Distance,
+		})
+	}
+	return
+}
+
+// NewEngine implements ReplicableEngine.
+func (le *LevenshteinEngine) NewEngine() interface{} {
+	return &LevenshteinEngine{}
+}
+
+// FindFuzzy implements FindFuzzy
+func (le *LevenshteinEngine) FindFuzzy(text string) (matches []*Match, err error) {
+	spans := wordSpans(text)
+	for _, term := range le.terms {
+		minWords := term.words - term.distance
+		if minWords < 1 {
+			minWords = 1
+		}
+		maxWords := term.words + term.distance
+
+		for i := range spans {
+			best := -1
+			bestEnd := 0
+			for words := minWords; words <= maxWords && i+words <= len(spans); words++ {
+				end := spans[i+words-1][1]
+				dist := boundedLevenshtein(term.runes, text[spans[i][0]:end], term.distance)
+				if dist >= 0 && (best < 0 || dist < best) {
+					best = dist
+					bestEnd = end
+				}
+			}
+			if best >= 0 {
+				matches = append(matches, &Match{
+					Term:     term.key,
+					Position: spans[i][0],
+					Length:   bestEnd - spans[i][0],
+				})
+			}
+		}
+	}
+	return
+}
+
+// boundedLevenshtein returns the Levenshtein distance between the term and the
+// text, or -1 if the distance is greater than maxDist.
+func boundedLevenshtein(term []rune, text string, maxDist int) int {
+	textLen := utf8.RuneCountInString(text)
+	if textLen-len(term) > maxDist || len(term)-textLen > maxDist {
+		return -1
+	}
+
+	prev := make([]int, len(term)+1)
+	curr := make([]int, len(term)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	i := 0
+	for _, ch := range text {
+		i++
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(term); j++ {
+			cost := 1
+			if term[j-1] == ch {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > maxDist {
+			return -1
+		}
+		prev, curr = curr, prev
+	}
+
+	if prev[len(term)] > maxDist {
+		return -1
+	}
+	return prev[len(term)]
+}
+
+// min3 returns the minimum of the three values
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// wordSpans returns the start and the end of each word of the text.
+func wordSpans(text string) (spans [][2]int) {
+	start := -1
+	for i, ch := range text {
+		if dsl.IsWordRune(ch) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return
+}
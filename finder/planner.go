@@ -0,0 +1,47 @@
+package finder
+
+import (
+	"github.com/pedroegsilva/gofindthem/dsl"
+)
+
+// neededTerms returns the terms of unknownTerms that must be searched, that are the ones whose
+// matches may change the evaluation of an expression given the matches that were already found
+// (see dsl.Expression.SolvePartially). If the match reporting is enabled the unknown terms of
+// the expressions that are already true are needed as well, since their matches are reported.
+func (finder *Finder) neededTerms(
+	sortedMatchesByKeyword map[string][]int,
+	unknownTerms map[string]struct{},
+	wordIndex *dsl.WordIndex,
+) (map[string]struct{}, error) {
+	needed := make(map[string]struct{})
+	for _, exp := range finder.expressions {
+		res, err := exp.expression.SolvePartially(sortedMatchesByKeyword, unknownTerms, wordIndex)
+		if err != nil {
+			return nil, err
+		}
+		if res.Decided && res.Value && finder.reportMatches {
+			res.Needed = exp.expression.Terms()
+		}
+		for term := range res.Needed {
+			if _, ok := unknownTerms[term]; ok {
+				needed[term] = struct{}{}
+			}
+		}
+	}
+	return needed, nil
+}
+
+// unknownTerms returns the keys of the fuzzy terms and, if withRegexes is set, of the
+// regexes, that are the terms whose matches are not known before their engines run.
+func (finder *Finder) unknownTerms(withRegexes bool) map[string]struct{} {
+	terms := make(map[string]struct{}, len(finder.regexes)+len(finder.fuzzyTerms))
+	if withRegexes {
+		for rgx := range finder.regexes {
+			terms[rgx] = struct{}{}
+		}
+	}
+	for key := range finder.fuzzyTerms {
+		terms[key] = struct{}{}
+	}
+	return terms
+}
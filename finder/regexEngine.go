@@ -1,51 +1,249 @@
 package finder
 
 import (
-	"fmt"
 	"regexp"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
 )
 
 type RegexEngine interface {
 	// BuildEngine receive the unique terms that need
-	// to be searched to create the engine support structures
+	// to be searched to create the engine support structures.
+	// The terms are the keys of the regexes (see dsl.RegexTerm.Key),
+	// so the flags of a regex are written as a Go flags group. Eg: (?i)\S+
+	// If caseSensitive is not set the text is changed to lowercase
+	// before it is searched, but the regexes are not.
 	BuildEngine(regexes map[string]struct{}, caseSensitive bool) (err error)
 	// FindRegexes receive the text and searchs for the feeded
-	// regexes
+	// regexes. It must be safe to call FindRegexes from multiple
+	// goroutines at once.
 	FindRegexes(text string) (matches []*Match, err error)
 }
 
+// RegexFlagsEngine is a RegexEngine that receives the regexes with their
+// flags instead of their keys. If the regex engine of the finder implements
+// it, BuildEngineWithFlags is used instead of BuildEngine.
+type RegexFlagsEngine interface {
+	RegexEngine
+	// BuildEngineWithFlags receive the unique regexes by their keys with their
+	// flags to create the engine support structures. The Term of the matches
+	// must be the key of the regex.
+	BuildEngineWithFlags(regexes map[string]dsl.RegexTerm, caseSensitive bool) (err error)
+}
+
+// SelectiveRegexEngine is a RegexEngine that is able to search the text for some
+// of its regexes. If the regex engine of the finder implements it, the regexes whose
+// literals were not found by the regex prefilter (see Finder.SetRegexPrefilter) are skipped.
+type SelectiveRegexEngine interface {
+	RegexEngine
+	// FindSelectedRegexes works as FindRegexes but only searchs for the
+	// regexes whose keys are on selected.
+	FindSelectedRegexes(text string, selected map[string]struct{}) (matches []*Match, err error)
+}
+
+// RegexpEngine implements RegexFlagsEngine and SelectiveRegexEngine with the regexp package.
+// If the finder is not case sensitive the regexes are compiled as
+// case insensitive, so their patterns are found on the lowercase text.
 type RegexpEngine struct {
 	compiledRegexes []*regexp.Regexp
+	// terms are the keys of the compiled regexes, that are the Term of their matches.
+	terms []string
 }
 
+// BuildEngine implements BuildEngine
 func (re *RegexpEngine) BuildEngine(regexes map[string]struct{}, caseSensitive bool) (err error) {
-	if len(re.compiledRegexes) > 0 {
-		re.compiledRegexes = re.compiledRegexes[:0]
+	terms := make(map[string]dsl.RegexTerm, len(regexes))
+	for rgx := range regexes {
+		terms[rgx] = dsl.RegexTerm{Pattern: rgx}
 	}
+	return re.BuildEngineWithFlags(terms, caseSensitive)
+}
 
-	for rgx := range regexes {
-		r, err := regexp.Compile(rgx)
+// BuildEngineWithFlags implements BuildEngineWithFlags
+func (re *RegexpEngine) BuildEngineWithFlags(regexes map[string]dsl.RegexTerm, caseSensitive bool) (err error) {
+	re.compiledRegexes = re.compiledRegexes[:0]
+	re.terms = re.terms[:0]
+
+	for key, rt := range regexes {
+		if !caseSensitive {
+			rt.Flags |= dsl.RegexCaseInsensitive
+		}
+		r, err := regexp.Compile(rt.Key())
 		if err != nil {
 			return err
 		}
 		re.compiledRegexes = append(re.compiledRegexes, r)
+		re.terms = append(re.terms, key)
 	}
 	return
 }
 
+// NewEngine implements ReplicableEngine.
+func (re *RegexpEngine) NewEngine() interface{} {
+	return &RegexpEngine{}
+}
+
+// Features implements FeaturesEngine. The engine reports the positions of the leftmost
+// non-overlapping matches of each regex and folds the case of the regexes.
+func (re *RegexpEngine) Features() EngineFeatures {
+	return PositionsFeature | CaseFoldingFeature
+}
+
+// FindRegexes implements FindRegexes
 func (re *RegexpEngine) FindRegexes(text string) (matches []*Match, err error) {
-	for _, rgx := range re.compiledRegexes {
+	return re.find(text, func(i int) bool { return true }), nil
+}
+
+// FindSelectedRegexes implements FindSelectedRegexes
+func (re *RegexpEngine) FindSelectedRegexes(text string, selected map[string]struct{}) (matches []*Match, err error) {
+	return re.find(text, func(i int) bool {
+		_, ok := selected[re.terms[i]]
+		return ok
+	}), nil
+}
+
+// find searchs the text for the compiled regexes whose indexes are selected.
+func (re *RegexpEngine) find(text string, selected func(i int) bool) (matches []*Match) {
+	for i, rgx := range re.compiledRegexes {
+		if !selected(i) {
+			continue
+		}
 		positions := rgx.FindAllStringIndex(text, -1)
 		for _, pos := range positions {
 			matches = append(matches, &Match{
-				Term:     fmt.Sprintf("%v", rgx),
+				Term:     re.terms[i],
 				Position: pos[0],
+				Length:   pos[1] - pos[0],
 			})
 		}
 	}
 	return
 }
 
+// PrefilterRegexEngine implements RegexFlagsEngine and SelectiveRegexEngine searching the literals that are
+// required by the regexes (eg: "order #" on "order #[0-9]+") with a SubstringEngine
+// before running them, so each regex only runs on the texts where at least one of its
+// literals was found. With many regexes the cost of a search is close to one pass of the
+// substring engine plus the regexes that may match. The regexes without literals
+// (eg: "[0-9]+") always run. It finds the same matches as the RegexpEngine.
+type PrefilterRegexEngine struct {
+	regexes RegexpEngine
+	// subEng searches the folded literals on the folded text (see foldString).
+	subEng SubstringEngine
+	// regexesByLiteral maps each literal to the indexes of the regexes that require it.
+	regexesByLiteral map[string][]int
+	// alwaysRun are the indexes of the regexes that have no literals.
+	alwaysRun []int
+}
+
+// NewPrefilterRegexEngine returns a new PrefilterRegexEngine that searches the literals
+// of the regexes with subEng, that must not be used by anything else. If subEng is nil
+// a CloudflareEngine is used, since only the found literals are needed and not their positions.
+func NewPrefilterRegexEngine(subEng SubstringEngine) *PrefilterRegexEngine {
+	if subEng == nil {
+		subEng = &CloudflareEngine{}
+	}
+	return &PrefilterRegexEngine{subEng: subEng}
+}
+
+// BuildEngine implements BuildEngine
+func (pre *PrefilterRegexEngine) BuildEngine(regexes map[string]struct{}, caseSensitive bool) (err error) {
+	terms := make(map[string]dsl.RegexTerm, len(regexes))
+	for rgx := range regexes {
+		terms[rgx] = dsl.RegexTerm{Pattern: rgx}
+	}
+	return pre.BuildEngineWithFlags(terms, caseSensitive)
+}
+
+// BuildEngineWithFlags implements BuildEngineWithFlags
+func (pre *PrefilterRegexEngine) BuildEngineWithFlags(regexes map[string]dsl.RegexTerm, caseSensitive bool) (err error) {
+	if pre.subEng == nil {
+		pre.subEng = &CloudflareEngine{}
+	}
+	err = pre.regexes.BuildEngineWithFlags(regexes, caseSensitive)
+	if err != nil {
+		return
+	}
+
+	pre.regexesByLiteral = make(map[string][]int)
+	pre.alwaysRun = pre.alwaysRun[:0]
+	for i, rgx := range pre.regexes.compiledRegexes {
+		lits, ok := regexLiterals(rgx.String(), foldedLiteral)
+		if !ok {
+			pre.alwaysRun = append(pre.alwaysRun, i)
+			continue
+		}
+		for _, lit := range lits {
+			pre.regexesByLiteral[lit] = append(pre.regexesByLiteral[lit], i)
+		}
+	}
+
+	literals := make(map[string]struct{}, len(pre.regexesByLiteral))
+	for lit := range pre.regexesByLiteral {
+		literals[lit] = struct{}{}
+	}
+	if len(literals) > 0 {
+		err = pre.subEng.BuildEngine(literals, true)
+	}
+	return
+}
+
+// NewEngine implements ReplicableEngine. The literals of the new engine are searched by a new
+// instance of the substring engine, so it returns nil if the substring engine can not be replicated.
+func (pre *PrefilterRegexEngine) NewEngine() interface{} {
+	if pre.subEng == nil {
+		return NewPrefilterRegexEngine(nil)
+	}
+	subEng, err := newEngineOf(pre.subEng)
+	if err != nil {
+		return nil
+	}
+	if subEng, ok := subEng.(SubstringEngine); ok {
+		return NewPrefilterRegexEngine(subEng)
+	}
+	return nil
+}
+
+// Features implements FeaturesEngine with the features of the RegexpEngine.
+func (pre *PrefilterRegexEngine) Features() EngineFeatures {
+	return pre.regexes.Features()
+}
+
+// FindRegexes implements FindRegexes
+func (pre *PrefilterRegexEngine) FindRegexes(text string) (matches []*Match, err error) {
+	return pre.FindSelectedRegexes(text, nil)
+}
+
+// FindSelectedRegexes implements FindSelectedRegexes. If selected is nil all the regexes are selected.
+func (pre *PrefilterRegexEngine) FindSelectedRegexes(text string, selected map[string]struct{}) (matches []*Match, err error) {
+	candidates := make([]bool, len(pre.regexes.compiledRegexes))
+	for _, i := range pre.alwaysRun {
+		candidates[i] = true
+	}
+	if len(pre.regexesByLiteral) > 0 {
+		litMatches, err := pre.subEng.FindSubstrings(foldString(text))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range litMatches {
+			for _, i := range pre.regexesByLiteral[match.Term] {
+				candidates[i] = true
+			}
+		}
+	}
+
+	return pre.regexes.find(text, func(i int) bool {
+		if !candidates[i] {
+			return false
+		}
+		if selected == nil {
+			return true
+		}
+		_, ok := selected[pre.regexes.terms[i]]
+		return ok
+	}), nil
+}
+
 type EmptyRgxEngine struct {
 }
 
@@ -54,6 +252,11 @@ func (pdm *EmptyRgxEngine) BuildEngine(regexes map[string]struct{}, caseSensitiv
 	return
 }
 
+// NewEngine implements ReplicableEngine.
+func (pdm *EmptyRgxEngine) NewEngine() interface{} {
+	return &EmptyRgxEngine{}
+}
+
 // BuildEngine implements FindSubstrings with an nop
 func (pdm *EmptyRgxEngine) FindRegexes(text string) (matches []*Match, err error) {
 	return
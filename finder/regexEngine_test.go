@@ -0,0 +1,75 @@
+package finder
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexLiterals(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		pattern  string
+		expected []string
+		ok       bool
+		message  string
+	}{
+		{pattern: `order #[0-9]+`, expected: []string{"ORDER #"}, ok: true, message: "literal prefix"},
+		{pattern: `\d+ refunds?`, expected: []string{" REFUND"}, ok: true, message: "literal after class"},
+		{pattern: `(?i)Café`, expected: []string{"CAFÉ"}, ok: true, message: "case insensitive"},
+		{pattern: `(chargeback|dispute)s`, expected: []string{"CHARGEBACK", "DISPUTE"}, ok: true, message: "alternation"},
+		{pattern: `(ab){2,}c`, expected: []string{"AB"}, ok: true, message: "repetition"},
+		{pattern: `x+y*z`, expected: []string{"X"}, ok: true, message: "longest literal"},
+		{pattern: `[0-9]+`, ok: false, message: "no literal"},
+		{pattern: `foo|[0-9]`, ok: false, message: "alternation without literal"},
+		{pattern: `(foo)?bar*`, expected: []string{"BA"}, ok: true, message: "optional group"},
+		{pattern: `(`, ok: false, message: "invalid regex"},
+	}
+
+	for _, tc := range tests {
+		lits, ok := regexLiterals(tc.pattern, foldedLiteral)
+		sort.Strings(lits)
+		assert.Equal(tc.ok, ok, tc.message)
+		assert.Equal(tc.expected, lits, tc.message)
+	}
+}
+
+func TestPrefilterRegexEngine(t *testing.T) {
+	assert := assert.New(t)
+	regexes := map[string]dsl.RegexTerm{
+		`order #[0-9]+`:       {Pattern: `order #[0-9]+`},
+		`(?i)STRAßE`:          {Pattern: `STRAßE`, Flags: dsl.RegexCaseInsensitive},
+		`(chargeback|refund)`: {Pattern: `(chargeback|refund)`},
+		`\d{3}-\d{4}`:         {Pattern: `\d{3}-\d{4}`},
+		`(?m)^end$`:           {Pattern: `^end$`, Flags: dsl.RegexMultiline},
+	}
+	texts := []string{
+		"order #123 and order #4",
+		"strasse Straße STRASSE straße",
+		"call 555-1234 for a refund",
+		"no regex here\nend",
+		"nothing",
+		"",
+	}
+
+	for _, caseSensitive := range []bool{true, false} {
+		expectedEng := &RegexpEngine{}
+		assert.Nil(expectedEng.BuildEngineWithFlags(regexes, caseSensitive))
+		for _, subEng := range []SubstringEngine{nil, &CloudflareForkEngine{}, &AnknownEngine{}} {
+			prefilterEng := NewPrefilterRegexEngine(subEng)
+			assert.Nil(prefilterEng.BuildEngineWithFlags(regexes, caseSensitive))
+			for _, text := range texts {
+				expected, err := expectedEng.FindRegexes(text)
+				assert.Nil(err)
+				matches, err := prefilterEng.FindRegexes(text)
+				assert.Nil(err)
+				assert.ElementsMatch(expected, matches, text)
+			}
+		}
+	}
+
+	prefilterEng := NewPrefilterRegexEngine(nil)
+	assert.NotNil(prefilterEng.BuildEngine(map[string]struct{}{`(`: {}}, true))
+}
@@ -0,0 +1,191 @@
+package finder
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxRegexLiterals is the maximum number of alternative literals kept for a
+// regex. A regex with more alternatives is considered to have no literals.
+const maxRegexLiterals = 64
+
+// literalFunc returns the form of a literal of a regex that is searched on the text, or
+// false if the literal can not be searched. foldCase is set if the literal is case insensitive.
+type literalFunc func(runes []rune, foldCase bool) (string, bool)
+
+// regexLiterals returns the literals of the regex pattern, where every match of the
+// regex contains at least one of them, in the form returned by literal.
+// Returns false if the regex has no such literals (eg: "[0-9]+" or "a|b*")
+// or if the pattern is invalid.
+func regexLiterals(pattern string, literal literalFunc) ([]string, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+	return requiredLiterals(re.Simplify(), literal)
+}
+
+// foldedLiteral is a literalFunc that returns the literal folded by foldString,
+// that must be searched on the folded text.
+var foldedLiteral = textLiteral(textForms{
+	form:   func(r rune) (string, bool) { return string(foldRune(r)), true },
+	minLen: 1,
+})
+
+// textForms describes how the runes of the literals are written on the searched text.
+type textForms struct {
+	// form returns the form of the rune on the text, or false if it has more than one.
+	form func(r rune) (string, bool)
+	// composes returns true if the rune may be composed with the previous one on the text.
+	// If it is nil the runes are never composed.
+	composes func(r rune) bool
+	// minLen is the minimum length of the literals, since the shorter
+	// ones are found on almost every text.
+	minLen int
+}
+
+// textLiteral returns a literalFunc that returns the literal as it is written on the text
+// described by forms. The runes of the case insensitive literals must have the same form as
+// all their equivalent runes under the simple case folding. The runes without a single form
+// (eg: 's' and 'ſ' on a lowercase text) split the literal, as the runes that may be composed
+// with the previous one along with it, and the longest part of the literal is returned.
+func textLiteral(forms textForms) literalFunc {
+	return func(runes []rune, foldCase bool) (string, bool) {
+		best := ""
+		part := ""
+		split := func() {
+			if len(part) > len(best) {
+				best = part
+			}
+			part = ""
+		}
+		for _, r := range runes {
+			if forms.composes != nil && forms.composes(r) {
+				_, size := utf8.DecodeLastRuneInString(part)
+				part = part[:len(part)-size]
+				split()
+				continue
+			}
+			form, ok := runeForm(r, foldCase, forms.form)
+			if !ok {
+				split()
+				continue
+			}
+			part += form
+		}
+		split()
+		return best, best != "" && len(best) >= forms.minLen
+	}
+}
+
+// runeForm returns the form of r on the text. If foldCase is set, all the equivalent
+// runes of r under the simple case folding must have the same form.
+func runeForm(r rune, foldCase bool, form func(r rune) (string, bool)) (string, bool) {
+	rForm, ok := form(r)
+	if !ok || !foldCase {
+		return rForm, ok
+	}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if fForm, ok := form(f); !ok || fForm != rForm {
+			return "", false
+		}
+	}
+	return rForm, true
+}
+
+// requiredLiterals returns the literals of the regex node, where every match
+// of the node contains at least one of them.
+func requiredLiterals(re *syntax.Regexp, literal literalFunc) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if len(re.Rune) == 0 {
+			return nil, false
+		}
+		lit, ok := literal(re.Rune, re.Flags&syntax.FoldCase != 0)
+		if !ok || lit == "" {
+			return nil, false
+		}
+		return []string{lit}, true
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0], literal)
+	case syntax.OpRepeat:
+		if re.Min < 1 {
+			return nil, false
+		}
+		return requiredLiterals(re.Sub[0], literal)
+	case syntax.OpConcat:
+		// the sub expression with the longest shortest literal is the most selective
+		var best []string
+		bestLen := 0
+		for _, sub := range re.Sub {
+			lits, ok := requiredLiterals(sub, literal)
+			if !ok {
+				continue
+			}
+			if shortest := shortestLen(lits); shortest > bestLen {
+				best = lits
+				bestLen = shortest
+			}
+		}
+		return best, best != nil
+	case syntax.OpAlternate:
+		set := make(map[string]struct{})
+		for _, sub := range re.Sub {
+			lits, ok := requiredLiterals(sub, literal)
+			if !ok {
+				return nil, false
+			}
+			for _, lit := range lits {
+				set[lit] = struct{}{}
+			}
+		}
+		if len(set) > maxRegexLiterals {
+			return nil, false
+		}
+		lits := make([]string, 0, len(set))
+		for lit := range set {
+			lits = append(lits, lit)
+		}
+		return lits, true
+	}
+	return nil, false
+}
+
+// shortestLen returns the length of the shortest literal.
+func shortestLen(lits []string) int {
+	shortest := -1
+	for _, lit := range lits {
+		if shortest < 0 || len(lit) < shortest {
+			shortest = len(lit)
+		}
+	}
+	return shortest
+}
+
+// foldString maps every rune of s to the smallest rune that is equivalent to it
+// under the Unicode simple case folding, so a literal that is found by a case
+// insensitive regex is always found on the folded text by its folded form.
+func foldString(s string) string {
+	return strings.Map(foldRune, s)
+}
+
+// foldRune returns the smallest rune that is equivalent to r
+// under the Unicode simple case folding.
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		// the smallest equivalent rune of an ASCII letter is its uppercase
+		if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
@@ -0,0 +1,251 @@
+package finder
+
+import (
+	"sort"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+	"golang.org/x/text/unicode/norm"
+)
+
+// minPrefilterLiteralLen is the minimum length of the literals used by the regex
+// prefilter. Shorter literals are found on almost every text, so they are not searched.
+const minPrefilterLiteralLen = 3
+
+// RegexPrefilterStats holds the number of regex executions of the finder, that is
+// the number of regexes run on each text (or each window of ProcessReader).
+type RegexPrefilterStats struct {
+	// Executed is the number of regex executions.
+	Executed uint64
+	// Skipped is the number of regex executions that were skipped because
+	// none of the literals of the regex were found or because the regex
+	// could not change the evaluation of any expression (see Finder.ProcessText).
+	Skipped uint64
+}
+
+// SetRegexPrefilter enables or disables the literal prefilter of the regexes. When enabled,
+// the literals that every match of a regex must contain (eg: "order #" on R"order #[0-9]+")
+// are searched by the substring engine along with the keywords, and the regex only runs on
+// the texts (or the windows of ProcessReader) where at least one of its literals was found.
+// The regexes without literals always run. If the regex engine does not implement
+// SelectiveRegexEngine the regexes are only skipped when none of them can match.
+func (finder *Finder) SetRegexPrefilter(enabled bool) {
+	finder.settingsMu.Lock()
+	defer finder.settingsMu.Unlock()
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+	if finder.regexPrefilter != enabled {
+		finder.regexPrefilter = enabled
+		finder.updatedSubMachine = false
+	}
+}
+
+// GetRegexPrefilterStats returns the number of regex executions
+// that were executed and skipped by the finder.
+func (finder *Finder) GetRegexPrefilterStats() RegexPrefilterStats {
+	return RegexPrefilterStats{
+		Executed: atomic.LoadUint64(&finder.regexExecuted),
+		Skipped:  atomic.LoadUint64(&finder.regexSkipped),
+	}
+}
+
+// addRegexLiterals extracts the literals of the new regexes and adds them to the literals
+// searched by the prefilter. It must be called before the regexes are added to the finder.
+func (finder *Finder) addRegexLiterals(regexes map[string]dsl.RegexTerm) {
+	if finder.regexLiterals == nil {
+		finder.regexLiterals = make(map[string][]string)
+	}
+
+	changed := false
+	for rgx, rt := range regexes {
+		if _, ok := finder.regexes[rgx]; ok {
+			continue
+		}
+		if !finder.caseSensitive {
+			rt.Flags |= dsl.RegexCaseInsensitive
+		}
+		if lits, ok := regexLiterals(rt.Key(), finder.prefilterLiteral); ok {
+			finder.regexLiterals[rgx] = lits
+			changed = true
+		}
+	}
+	if changed {
+		finder.indexRegexLiterals()
+	}
+}
+
+// removeRegexLiterals removes the literals of the regexes that are no longer used.
+// It must be called after the regexes are removed from the finder.
+func (finder *Finder) removeRegexLiterals(regexes map[string]dsl.RegexTerm) {
+	changed := false
+	for rgx := range regexes {
+		if _, ok := finder.regexes[rgx]; ok {
+			continue
+		}
+		if _, ok := finder.regexLiterals[rgx]; ok {
+			delete(finder.regexLiterals, rgx)
+			changed = true
+		}
+	}
+	if changed {
+		finder.indexRegexLiterals()
+	}
+}
+
+// indexRegexLiterals maps the literals to the regexes that require them and marks the
+// substring engine as outdated if the literals are searched by the prefilter.
+func (finder *Finder) indexRegexLiterals() {
+	finder.regexesByLiteral = make(map[string][]string)
+	for rgx, lits := range finder.regexLiterals {
+		for _, lit := range lits {
+			finder.regexesByLiteral[lit] = append(finder.regexesByLiteral[lit], rgx)
+		}
+	}
+	for _, regexes := range finder.regexesByLiteral {
+		sort.Strings(regexes)
+	}
+	if finder.regexPrefilter {
+		finder.updatedSubMachine = false
+	}
+}
+
+// prefilterLiteral is the literalFunc of the regex prefilter, that returns the literal as it
+// is found on the searched text (see textLiteral). If the finder is not case sensitive the text
+// is changed to lowercase, or folded by its engines in the same way, and if it is normalized
+// the runes are normalized as well, so the runes that combine with the previous one split
+// the literal, since the normalization may compose them.
+func (finder *Finder) prefilterLiteral(runes []rune, foldCase bool) (string, bool) {
+	forms := textForms{form: finder.literalForm, minLen: minPrefilterLiteralLen}
+	if finder.normalizer != nil {
+		forms.composes = func(r rune) bool {
+			return !norm.NFKC.PropertiesString(string(r)).BoundaryBefore()
+		}
+	}
+	return textLiteral(forms)(runes, foldCase)
+}
+
+// literalForm returns the form of the rune of a literal on the searched text.
+func (finder *Finder) literalForm(r rune) (string, bool) {
+	if finder.normalizer != nil {
+		form := finder.normalizer.NormalizeString(string(r))
+		return form, utf8.RuneCountInString(form) == 1
+	}
+	if finder.caseSensitive {
+		return string(r), true
+	}
+	return string(unicode.ToLower(r)), true
+}
+
+// usesRegexPrefilter returns true if the regexes are prefiltered by their literals.
+func (finder *Finder) usesRegexPrefilter() bool {
+	return finder.regexPrefilter && len(finder.regexesByLiteral) > 0
+}
+
+// searchedKeywords returns the keywords that are searched by the substring
+// engine, that are the keywords of the expressions and the literals of the
+// regexes if they are prefiltered.
+func (finder *Finder) searchedKeywords() map[string]struct{} {
+	if !finder.usesRegexPrefilter() {
+		return finder.keywords
+	}
+	keywords := make(map[string]struct{}, len(finder.keywords)+len(finder.regexesByLiteral))
+	for key := range finder.keywords {
+		keywords[key] = struct{}{}
+	}
+	for lit := range finder.regexesByLiteral {
+		keywords[lit] = struct{}{}
+	}
+	return keywords
+}
+
+// splitLiteralMatches returns the matches of the keywords and calls found with the
+// matches of the literals of the regexes. A match may be of both.
+func (finder *Finder) splitLiteralMatches(matches []*Match, found func(match *Match)) []*Match {
+	keyMatches := make([]*Match, 0, len(matches))
+	for _, match := range matches {
+		if _, ok := finder.regexesByLiteral[match.Term]; ok {
+			found(match)
+		}
+		if _, ok := finder.keywords[finder.matchTermKey(match.Term)]; ok {
+			keyMatches = append(keyMatches, match)
+		}
+	}
+	return keyMatches
+}
+
+// findRegexes searches the regexes on the text. Only the regexes on needed are run,
+// or all of them if needed is nil. If the regexes are prefiltered, the regexes with
+// literals are only run if at least one of their literals is on foundLiterals.
+func (finder *Finder) findRegexes(
+	text string,
+	foundLiterals map[string]struct{},
+	needed map[string]struct{},
+) ([]*Match, error) {
+	total := uint64(len(finder.regexes))
+	if !finder.usesRegexPrefilter() && needed == nil {
+		atomic.AddUint64(&finder.regexExecuted, total)
+		return finder.rgxEng.FindRegexes(text)
+	}
+
+	selected := make(map[string]struct{}, len(finder.regexes))
+	for rgx := range finder.regexes {
+		if _, ok := needed[rgx]; !ok && needed != nil {
+			continue
+		}
+		if !finder.usesRegexPrefilter() {
+			selected[rgx] = struct{}{}
+			continue
+		}
+		lits, ok := finder.regexLiterals[rgx]
+		if !ok {
+			selected[rgx] = struct{}{}
+			continue
+		}
+		for _, lit := range lits {
+			if _, ok := foundLiterals[lit]; ok {
+				selected[rgx] = struct{}{}
+				break
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		atomic.AddUint64(&finder.regexSkipped, total)
+		return nil, nil
+	}
+	if selEng, ok := finder.rgxEng.(SelectiveRegexEngine); ok {
+		atomic.AddUint64(&finder.regexExecuted, uint64(len(selected)))
+		atomic.AddUint64(&finder.regexSkipped, total-uint64(len(selected)))
+		return selEng.FindSelectedRegexes(text, selected)
+	}
+	atomic.AddUint64(&finder.regexExecuted, total)
+	return finder.rgxEng.FindRegexes(text)
+}
+
+// streamRegexPrefilter holds the position of the last match of each literal
+// of the regexes on a text that is processed in chunks.
+type streamRegexPrefilter struct {
+	lastStarts map[string]int
+}
+
+// add records the matches of the literals, whose positions must
+// be offsets from the start of the text.
+func (srp *streamRegexPrefilter) add(match *Match) {
+	if start, ok := srp.lastStarts[match.Term]; !ok || match.Position > start {
+		srp.lastStarts[match.Term] = match.Position
+	}
+}
+
+// found returns the literals that have a match starting
+// at or after offset, that are the ones on the window.
+func (srp *streamRegexPrefilter) found(offset int) map[string]struct{} {
+	literals := make(map[string]struct{})
+	for lit, start := range srp.lastStarts {
+		if start >= offset {
+			literals[lit] = struct{}{}
+		}
+	}
+	return literals
+}
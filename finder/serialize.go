@@ -0,0 +1,355 @@
+package finder
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+)
+
+const (
+	// finderFileMagic identifies the files written by Finder.WriteTo.
+	finderFileMagic = "GOFINDTHEM"
+	// FinderFileVersion is the version of the format written by Finder.WriteTo.
+	// LoadFinder only reads the files of this version.
+	FinderFileVersion uint32 = 1
+)
+
+// WriteTo writes the finder to w on a versioned binary format, that is read by LoadFinder.
+// The file holds the settings of the finder (case sensitivity, normalization, regex prefilter,
+// match reporting and stream options), the expressions with their tags, the keywords and the
+// regexes. If the substring engine implements encoding.BinaryMarshaler (as the AhoCorasickEngine
+// does) its compiled structures are also written, so they are not built again when the finder is
+// loaded. The outdated engines are built before the finder is written.
+// WriteTo implements io.WriterTo.
+func (finder *Finder) WriteTo(w io.Writer) (n int64, err error) {
+	err = finder.rLockBuilt()
+	if err != nil {
+		return
+	}
+	defer finder.mu.RUnlock()
+
+	bw := &binaryWriter{w: w}
+	bw.writeBytes([]byte(finderFileMagic))
+	bw.writeUint(uint64(FinderFileVersion))
+	bw.writeBool(finder.caseSensitive)
+	var forms dsl.Normalization
+	if finder.normalizer != nil {
+		forms = finder.normalizer.Forms()
+	}
+	bw.writeUint(uint64(forms))
+	bw.writeBool(finder.regexPrefilter)
+	bw.writeBool(finder.reportMatches)
+	bw.writeInt(int64(finder.streamChunkSize))
+	bw.writeInt(int64(finder.streamRegexWindow))
+	bw.writeUint(finder.version)
+
+	bw.writeUint(uint64(len(finder.expressions)))
+	for _, exp := range finder.expressions {
+		bw.writeString(exp.exprString)
+		bw.writeString(exp.tag)
+	}
+	bw.writeStringSet(finder.searchedKeywords())
+	bw.writeStringSet(finder.regexes)
+
+	marshaler, ok := finder.subEng.(encoding.BinaryMarshaler)
+	if !ok || !finder.updatedSubMachine {
+		bw.writeString("")
+		return bw.n, bw.err
+	}
+	engine, err := marshaler.MarshalBinary()
+	if err != nil {
+		return bw.n, err
+	}
+	bw.writeString(fmt.Sprintf("%T", finder.subEng))
+	bw.writeBytes(engine)
+	return bw.n, bw.err
+}
+
+// LoadFinder returns a new Finder with the expressions and the settings that were written by
+// Finder.WriteTo. If subEng is nil an AhoCorasickEngine is used. If subEng has the type of the
+// written substring engine and implements encoding.BinaryUnmarshaler its compiled structures are
+// loaded, otherwise it is built on the first process. The regex and fuzzy engines are always built
+// on the first process. Returns an error if the file has another version or is malformed, or if the
+// expressions of the file do not produce the written keywords and regexes (eg: the file was written
+// by a finder with a different DSL), since the loaded engine would not match the expressions.
+func LoadFinder(r io.Reader, subEng SubstringEngine, rgxEng RegexEngine) (finder *Finder, err error) {
+	br := &binaryReader{r: bufio.NewReader(r)}
+	magic := br.readBytes()
+	if br.err == nil && string(magic) != finderFileMagic {
+		return nil, errors.New("invalid finder file: unexpected header")
+	}
+	version := br.readUint()
+	if br.err == nil && version != uint64(FinderFileVersion) {
+		return nil, fmt.Errorf("invalid finder file: version %d is not supported, expected version %d", version, FinderFileVersion)
+	}
+	caseSensitive := br.readBool()
+	forms := dsl.Normalization(br.readUint())
+	regexPrefilter := br.readBool()
+	reportMatches := br.readBool()
+	chunkSize := int(br.readInt())
+	regexWindow := int(br.readInt())
+	expVersion := br.readUint()
+	if br.err != nil {
+		return nil, br.fileError()
+	}
+
+	finder = NewFinder(subEng, rgxEng, caseSensitive)
+	if forms != 0 {
+		err = finder.SetNormalization(forms)
+		if err != nil {
+			return nil, err
+		}
+	}
+	finder.SetRegexPrefilter(regexPrefilter)
+	finder.SetMatchReporting(reportMatches)
+	finder.SetStreamOptions(chunkSize, regexWindow)
+
+	count := br.readUint()
+	for i := uint64(0); i < count && br.err == nil; i++ {
+		expression := br.readString()
+		tag := br.readString()
+		if br.err != nil {
+			break
+		}
+		err = finder.AddExpressionWithTag(expression, tag)
+		if err != nil {
+			return nil, err
+		}
+	}
+	keywords := br.readStringSet()
+	regexes := br.readStringSet()
+	engineType := br.readString()
+	if br.err != nil {
+		return nil, br.fileError()
+	}
+	if !equalSets(keywords, finder.searchedKeywords()) || !equalSets(regexes, finder.regexes) {
+		return nil, errors.New("invalid finder file: the expressions do not match the written keywords and regexes")
+	}
+	finder.version = expVersion
+
+	if engineType == "" {
+		return finder, nil
+	}
+	engine := br.readBytes()
+	if br.err != nil {
+		return nil, br.fileError()
+	}
+	unmarshaler, ok := finder.subEng.(encoding.BinaryUnmarshaler)
+	if !ok || fmt.Sprintf("%T", finder.subEng) != engineType {
+		return finder, nil
+	}
+	err = unmarshaler.UnmarshalBinary(engine)
+	if err != nil {
+		return nil, err
+	}
+	finder.updatedSubMachine = true
+	return finder, nil
+}
+
+// equalSets returns true if both sets have the same elements.
+func equalSets(a map[string]struct{}, b map[string]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// binaryWriter writes the values of the binary format, keeping the first
+// error, so the writes can be checked once after all of them.
+type binaryWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (bw *binaryWriter) write(p []byte) {
+	if bw.err != nil {
+		return
+	}
+	n, err := bw.w.Write(p)
+	bw.n += int64(n)
+	bw.err = err
+}
+
+func (bw *binaryWriter) writeUint(v uint64) {
+	bw.write(bw.buf[:binary.PutUvarint(bw.buf[:], v)])
+}
+
+func (bw *binaryWriter) writeInt(v int64) {
+	bw.write(bw.buf[:binary.PutVarint(bw.buf[:], v)])
+}
+
+func (bw *binaryWriter) writeBool(v bool) {
+	if v {
+		bw.writeUint(1)
+	} else {
+		bw.writeUint(0)
+	}
+}
+
+func (bw *binaryWriter) writeBytes(p []byte) {
+	bw.writeUint(uint64(len(p)))
+	bw.write(p)
+}
+
+func (bw *binaryWriter) writeString(s string) {
+	bw.writeUint(uint64(len(s)))
+	if bw.err == nil {
+		var n int
+		n, bw.err = io.WriteString(bw.w, s)
+		bw.n += int64(n)
+	}
+}
+
+// writeStringSet writes the elements of the set sorted, so the
+// same set is always written with the same bytes.
+func (bw *binaryWriter) writeStringSet(set map[string]struct{}) {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	bw.writeStrings(keys)
+}
+
+func (bw *binaryWriter) writeStrings(values []string) {
+	bw.writeUint(uint64(len(values)))
+	for _, v := range values {
+		bw.writeString(v)
+	}
+}
+
+// writeInt32s writes the values as fixed size little endian integers.
+func (bw *binaryWriter) writeInt32s(values []int32) {
+	bw.writeUint(uint64(len(values)))
+	buf := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(buf[4*i:], uint32(v))
+	}
+	bw.write(buf)
+}
+
+// binaryReader reads the values written by a binaryWriter, keeping the first
+// error, so the reads can be checked once after all of them. After an
+// error the reads return zero values.
+type binaryReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+// fileError returns the error of the reader as an error of the file.
+func (br *binaryReader) fileError() error {
+	if br.err == io.EOF || br.err == io.ErrUnexpectedEOF {
+		return errors.New("invalid finder file: unexpected end of file")
+	}
+	return br.err
+}
+
+func (br *binaryReader) readUint() uint64 {
+	if br.err != nil {
+		return 0
+	}
+	var v uint64
+	v, br.err = binary.ReadUvarint(br.r)
+	return v
+}
+
+func (br *binaryReader) readInt() int64 {
+	if br.err != nil {
+		return 0
+	}
+	var v int64
+	v, br.err = binary.ReadVarint(br.r)
+	return v
+}
+
+func (br *binaryReader) readBool() bool {
+	return br.readUint() != 0
+}
+
+// readLen reads the length of a sequence, that must have at least
+// size bytes per element, so a corrupted length does not allocate
+// more than the bytes that are left on the reader.
+func (br *binaryReader) readLen(size int) int {
+	n := br.readUint()
+	if br.err != nil {
+		return 0
+	}
+	if size > 0 && n > uint64(^uint(0)>>1)/uint64(size) {
+		br.err = errors.New("invalid finder file: invalid length")
+		return 0
+	}
+	return int(n)
+}
+
+// readN reads n bytes, growing the buffer as they are read.
+func (br *binaryReader) readN(n int) []byte {
+	if br.err != nil {
+		return nil
+	}
+	const chunk = 64 * 1024
+	var buf []byte
+	for len(buf) < n && br.err == nil {
+		size := n - len(buf)
+		if size > chunk {
+			size = chunk
+		}
+		start := len(buf)
+		buf = append(buf, make([]byte, size)...)
+		_, br.err = io.ReadFull(br.r, buf[start:])
+	}
+	if br.err == io.EOF {
+		br.err = io.ErrUnexpectedEOF
+	}
+	return buf
+}
+
+func (br *binaryReader) readBytes() []byte {
+	return br.readN(br.readLen(1))
+}
+
+func (br *binaryReader) readString() string {
+	return string(br.readBytes())
+}
+
+func (br *binaryReader) readStrings() []string {
+	n := br.readLen(1)
+	var values []string
+	for i := 0; i < n && br.err == nil; i++ {
+		values = append(values, br.readString())
+	}
+	return values
+}
+
+func (br *binaryReader) readStringSet() map[string]struct{} {
+	values := br.readStrings()
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+func (br *binaryReader) readInt32s() []int32 {
+	buf := br.readN(4 * br.readLen(4))
+	if br.err != nil {
+		return nil
+	}
+	values := make([]int32, len(buf)/4)
+	for i := range values {
+		values[i] = int32(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return values
+}
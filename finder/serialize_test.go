@@ -0,0 +1,192 @@
+package finder
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSerializedFinder returns a finder with all the settings that are written by WriteTo.
+func newSerializedFinder(assert *assert.Assertions) *Finder {
+	findthem := NewFinder(nil, &RegexpEngine{}, false)
+	assert.Nil(findthem.SetNormalization(dsl.DiacriticStripping))
+	findthem.SetRegexPrefilter(true)
+	findthem.SetMatchReporting(true)
+	findthem.SetStreamOptions(8, 16)
+	assert.Nil(findthem.AddExpressionWithTag(`"café" and not "tea"`, "drinks"))
+	assert.Nil(findthem.AddExpressionWithTag(`INORD("order" and R"#[0-9]+")`, "orders"))
+	assert.Nil(findthem.AddExpressionWithTag(`W"cat" or F1"refund"`, ""))
+	assert.Nil(findthem.AddExpressionWithTag(`COUNT("na") > 2`, "songs"))
+	assert.Nil(findthem.RemoveExpression(2))
+	assert.Nil(findthem.AddExpressionWithTag(`"Error" NEAR/2 "disk"`, "logs"))
+	return findthem
+}
+
+var serializedTexts = []string{
+	"a CAFE with no tea",
+	"a cafe, please",
+	"the order #1234 was sent",
+	"#12 is the order",
+	"na na na na batman",
+	"error: the disk is full",
+	"nothing here",
+}
+
+func TestFinderWriteToLoadFinder(t *testing.T) {
+	assert := assert.New(t)
+	findthem := newSerializedFinder(assert)
+	var buf bytes.Buffer
+	n, err := findthem.WriteTo(&buf)
+	assert.Nil(err)
+	assert.Equal(int64(buf.Len()), n)
+	data := buf.Bytes()
+
+	tests := []struct {
+		subEng         SubstringEngine
+		loadsAutomaton bool
+		message        string
+	}{
+		{subEng: nil, loadsAutomaton: true, message: "default engine"},
+		{subEng: &AhoCorasickEngine{Mode: OverlappingMatches}, loadsAutomaton: true, message: "aho-corasick"},
+		{subEng: &AnknownEngine{}, loadsAutomaton: false, message: "another engine"},
+	}
+	for _, tc := range tests {
+		loaded, err := LoadFinder(bytes.NewReader(data), tc.subEng, &RegexpEngine{})
+		if !assert.Nil(err, tc.message) {
+			continue
+		}
+		assert.Equal(findthem.GetExpressions(), loaded.GetExpressions(), tc.message)
+		assert.Equal(findthem.Version(), loaded.Version(), tc.message)
+		assert.Equal(findthem.GetKeywords(), loaded.GetKeywords(), tc.message)
+		assert.Equal(findthem.GetRegexes(), loaded.GetRegexes(), tc.message)
+		assert.Equal(tc.loadsAutomaton, loaded.updatedSubMachine, tc.message)
+
+		for _, text := range serializedTexts {
+			expected, err := findthem.ProcessText(text)
+			assert.Nil(err)
+			expRes, err := loaded.ProcessText(text)
+			assert.Nil(err, tc.message)
+			assert.Equal(expected, expRes, "%s: %s", tc.message, text)
+
+			expRes, err = loaded.ProcessReader(strings.NewReader(text))
+			assert.Nil(err, tc.message)
+			assert.Equal(expected, expRes, "%s: %s", tc.message, text)
+		}
+
+		// the loaded finder writes the same file
+		var rewritten bytes.Buffer
+		_, err = loaded.WriteTo(&rewritten)
+		assert.Nil(err, tc.message)
+		if tc.loadsAutomaton {
+			assert.Equal(data, rewritten.Bytes(), tc.message)
+		}
+	}
+}
+
+func TestFinderWriteToWithoutAutomaton(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, true)
+	assert.Nil(findthem.AddExpression(`"a" and R"b+"`))
+	var buf bytes.Buffer
+	_, err := findthem.WriteTo(&buf)
+	assert.Nil(err)
+
+	loaded, err := LoadFinder(&buf, nil, &RegexpEngine{})
+	assert.Nil(err)
+	assert.False(loaded.updatedSubMachine)
+	expRes, err := loaded.ProcessText("a bb")
+	assert.Nil(err)
+	assert.Len(expRes, 1)
+
+	empty := NewFinder(nil, nil, false)
+	buf.Reset()
+	_, err = empty.WriteTo(&buf)
+	assert.Nil(err)
+	loaded, err = LoadFinder(&buf, nil, nil)
+	assert.Nil(err)
+	assert.Empty(loaded.GetExpressions())
+}
+
+func TestLoadFinderErrors(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	_, err := newSerializedFinder(assert).WriteTo(&buf)
+	assert.Nil(err)
+	data := buf.Bytes()
+
+	_, err = LoadFinder(strings.NewReader("\x0aNOTAFINDER"), nil, &RegexpEngine{})
+	assert.EqualError(err, "invalid finder file: unexpected header")
+
+	wrongVersion := append([]byte{}, data...)
+	wrongVersion[1+len(finderFileMagic)] = 9
+	_, err = LoadFinder(bytes.NewReader(wrongVersion), nil, &RegexpEngine{})
+	assert.EqualError(err, "invalid finder file: version 9 is not supported, expected version 1")
+
+	// the files written by a finder with other keywords are rejected
+	other := NewFinder(nil, &RegexpEngine{}, true)
+	assert.Nil(other.AddExpression(`"café" and not "tea"`))
+	var otherBuf bytes.Buffer
+	_, err = other.WriteTo(&otherBuf)
+	assert.Nil(err)
+	tampered := bytes.Replace(otherBuf.Bytes(), []byte("\x03tea\x00"), []byte("\x03TEA\x00"), 1)
+	assert.NotEqual(otherBuf.Bytes(), tampered)
+	_, err = LoadFinder(bytes.NewReader(tampered), nil, &RegexpEngine{})
+	assert.EqualError(err, "invalid finder file: the expressions do not match the written keywords and regexes")
+
+	for size := 0; size < len(data); size++ {
+		_, err = LoadFinder(bytes.NewReader(data[:size]), nil, &RegexpEngine{})
+		assert.NotNil(err, "truncated at %d", size)
+	}
+}
+
+func TestAhoCorasickEngineMarshalBinary(t *testing.T) {
+	assert := assert.New(t)
+	rnd := rand.New(rand.NewSource(3))
+	alphabet := []rune("abAB cÇç")
+	randomString := func(maxLen int) string {
+		runes := make([]rune, 1+rnd.Intn(maxLen))
+		for i := range runes {
+			runes[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		return string(runes)
+	}
+
+	for i := 0; i < 100; i++ {
+		keywords := make(map[string]struct{})
+		for j := rnd.Intn(8); j >= 0; j-- {
+			keywords[randomString(4)] = struct{}{}
+		}
+		caseSensitive := rnd.Intn(2) == 0
+		built := &AhoCorasickEngine{}
+		assert.Nil(built.BuildEngine(keywords, caseSensitive))
+		data, err := built.MarshalBinary()
+		assert.Nil(err)
+
+		loaded := &AhoCorasickEngine{}
+		assert.Nil(loaded.UnmarshalBinary(data))
+		assert.Equal(built.automaton, loaded.automaton)
+		text := randomString(30)
+		expected, err := built.FindSubstrings(text)
+		assert.Nil(err)
+		matches, err := loaded.FindSubstrings(text)
+		assert.Nil(err)
+		assert.Equal(expected, matches, text)
+
+		// a corrupted automaton is rejected instead of failing on the searches
+		corrupted := append([]byte{}, data...)
+		corrupted[len(corrupted)-1] ^= 0x7f
+		assert.NotNil(loaded.UnmarshalBinary(corrupted))
+		assert.NotNil(loaded.UnmarshalBinary(data[:len(data)-1]))
+	}
+
+	empty := &AhoCorasickEngine{}
+	data, err := empty.MarshalBinary()
+	assert.Nil(err)
+	loaded := &AhoCorasickEngine{}
+	assert.Nil(loaded.UnmarshalBinary(data))
+	assert.Nil(loaded.automaton)
+}
@@ -0,0 +1,483 @@
+package finder
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+)
+
+const (
+	// DefaultStreamChunkSize is the default number of bytes that
+	// ProcessReader reads from the reader on each step.
+	DefaultStreamChunkSize = 64 * 1024
+	// DefaultStreamRegexWindow is the default number of bytes of the previous
+	// chunks that are searched again by the regex engine on each step of ProcessReader.
+	DefaultStreamRegexWindow = 4 * 1024
+)
+
+// SetStreamOptions sets the size of the chunks read by ProcessReader and the
+// size of the window of previous bytes that is searched again by the regex
+// engine, so regex matches that cross the chunk boundaries can be found.
+// Regex matches longer than the window may not be found when they cross a boundary.
+// The window is also used to verify the wildcard terms around their anchors.
+// Values lower or equal to zero set the default values.
+func (finder *Finder) SetStreamOptions(chunkSize int, regexWindow int) {
+	finder.mu.Lock()
+	defer finder.mu.Unlock()
+	finder.streamChunkSize = chunkSize
+	finder.streamRegexWindow = regexWindow
+}
+
+// ProcessReader searches for matching terms on the text read from the reader and solves
+// the expressions, returning an array of ExpressionResult for all expressions that were
+// evaluated as true. The text is read in chunks, so the whole document is never kept on memory.
+// If the substring engine supports the StreamingFeature the state of the search is carried
+// between the chunks (see StreamSubstringEngine), otherwise the last bytes of the previous chunk
+// are searched again so the terms that cross the chunk boundaries are found. The positions of
+// the matches are offsets from the start of the text, so INORD works as it does on ProcessText.
+// The engines must report the positions of the matches, otherwise an *UnsupportedFeatureError
+// is returned. The last words of each chunk are searched again by the fuzzy engine, so the
+// fuzzy terms are found as they would be on ProcessText.
+// The reader is processed with a copy of the expressions and terms taken when it starts, so
+// the expressions can be changed and other texts processed while it is read. If an engine in
+// use is not a ReplicableEngine the expressions can not be changed until the reader is processed.
+func (finder *Finder) ProcessReader(reader io.Reader) (expRes []ExpressionResult, err error) {
+	return finder.ProcessReaderContext(context.Background(), reader)
+}
+
+// ProcessReaderContext works as ProcessReader but stops the processing when the context is done.
+// The context is checked before each chunk is searched and between the evaluation of the
+// expressions. If the processing is stopped a *PartialResultError is returned, with the results
+// of the expressions that were evaluated if the reader was already processed (see ProcessTextContext).
+func (finder *Finder) ProcessReaderContext(ctx context.Context, reader io.Reader) (expRes []ExpressionResult, err error) {
+	err = finder.rLockBuilt()
+	if err != nil {
+		return
+	}
+
+	snapshot, detached := finder.streamSnapshot()
+	if detached {
+		atomic.AddInt32(&finder.streams, 1)
+		defer atomic.AddInt32(&finder.streams, -1)
+		finder.mu.RUnlock()
+	} else {
+		defer finder.mu.RUnlock()
+	}
+	defer func() {
+		atomic.AddUint64(&finder.regexExecuted, snapshot.regexExecuted)
+		atomic.AddUint64(&finder.regexSkipped, snapshot.regexSkipped)
+	}()
+	return snapshot.processReader(ctx, reader)
+}
+
+// processReader implements ProcessReaderContext on a snapshot of the finder.
+func (finder *Finder) processReader(ctx context.Context, reader io.Reader) (expRes []ExpressionResult, err error) {
+	chunkSize := finder.streamChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultStreamChunkSize
+	}
+
+	window := finder.streamRegexWindow
+	if window <= 0 {
+		window = DefaultStreamRegexWindow
+	}
+	var wildcardFilter *streamWildcardFilter
+	if len(finder.wildcards) > 0 {
+		wildcardFilter = finder.newStreamWildcardFilter(window)
+	}
+	if len(finder.regexes) == 0 {
+		window = 0
+	}
+
+	searchKeywords := len(finder.keywords) > 0 || finder.usesRegexPrefilter()
+	if searchKeywords && !engineFeatures(finder.subEng).Has(PositionsFeature) {
+		return nil, &UnsupportedFeatureError{Subject: "ProcessReader", Engine: "substring engine", Missing: PositionsFeature}
+	}
+	if len(finder.regexes) > 0 && !engineFeatures(finder.rgxEng).Has(PositionsFeature) {
+		return nil, &UnsupportedFeatureError{Subject: "ProcessReader", Engine: "regex engine", Missing: PositionsFeature}
+	}
+	var stream SubstringStream
+	streamEng, ok := finder.subEng.(StreamSubstringEngine)
+	if ok && searchKeywords && engineFeatures(finder.subEng).Has(StreamingFeature) {
+		stream = streamEng.NewStream()
+	} else {
+		for key := range finder.searchedKeywords() {
+			if len(key)-1 > window {
+				window = len(key) - 1
+			}
+		}
+	}
+
+	sortedMatchesByKeyword := make(map[string][]int)
+	var matchesByTerm map[string][]*Match
+	if finder.reportMatches {
+		matchesByTerm = make(map[string][]*Match)
+	}
+	lastRgxEndByTerm := make(map[string]int)
+	var wordIndex *dsl.WordIndex
+	if finder.wordDistanceExprs > 0 {
+		wordIndex = dsl.NewWordIndex("")
+	}
+	var wordFilter *streamWordFilter
+	if len(finder.wordKeywords) > 0 {
+		wordFilter = finder.newStreamWordFilter()
+	}
+	var caseFilter *streamCaseFilter
+	if len(finder.caseKeywords) > 0 {
+		caseFilter = finder.newStreamCaseFilter()
+	}
+	var rgxPrefilter *streamRegexPrefilter
+	if finder.usesRegexPrefilter() {
+		rgxPrefilter = &streamRegexPrefilter{lastStarts: make(map[string]int)}
+	}
+	var fuzzySearch *streamFuzzySearch
+	if len(finder.fuzzyTerms) > 0 {
+		fuzzySearch = finder.newStreamFuzzySearch()
+	}
+
+	var normStream *dsl.NormalizerStream
+	if finder.normalizer != nil {
+		normStream = finder.normalizer.NewStream()
+	}
+
+	chunks := &chunkReader{reader: reader, size: chunkSize}
+	// tail holds the last bytes that were already searched and
+	// offset is the position of the first byte of tail on the text.
+	tail := ""
+	offset := 0
+	for {
+		if ctx.Err() != nil {
+			return nil, &PartialResultError{Stage: SubstringSearchStage, Total: len(finder.expressions), Err: ctx.Err()}
+		}
+
+		chunk, eof, err := chunks.next()
+		if err != nil {
+			return nil, err
+		}
+
+		original := chunk
+		if normStream != nil {
+			chunk = normStream.Next(chunk, eof)
+		} else if finder.needsLowercaseText() {
+			chunk = strings.ToLower(chunk)
+		}
+		buf := tail + chunk
+		if wordIndex != nil {
+			wordIndex.Append(chunk)
+		}
+
+		// the bytes after tailStart will be searched again on the next step
+		tailStart := len(buf) - window
+		if tailStart < 0 {
+			tailStart = 0
+		}
+		for tailStart > 0 && !utf8.RuneStart(buf[tailStart]) {
+			tailStart--
+		}
+
+		if searchKeywords {
+			var keyMatches []*Match
+			if stream != nil {
+				keyMatches, err = stream.FindSubstrings(chunk)
+			} else {
+				keyMatches, err = finder.subEng.FindSubstrings(buf)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			newMatches := make([]*Match, 0, len(keyMatches))
+			for _, match := range keyMatches {
+				if stream != nil {
+					newMatches = append(newMatches, match)
+					continue
+				}
+				// matches that end on tail were found on the previous step
+				if match.Position+match.Length <= len(tail) {
+					continue
+				}
+				newMatches = append(newMatches, &Match{
+					Position: match.Position + offset,
+					Length:   match.Length,
+					Term:     match.Term,
+				})
+			}
+			if rgxPrefilter != nil {
+				newMatches = finder.splitLiteralMatches(newMatches, rgxPrefilter.add)
+			}
+			finder.addMatchesToSolverMap(newMatches, sortedMatchesByKeyword)
+			finder.addMatchesToTermMap(newMatches, matchesByTerm)
+
+			if wordFilter != nil {
+				wordMatches := wordFilter.filter(chunk, newMatches, eof)
+				finder.addMatchesToSolverMap(wordMatches, sortedMatchesByKeyword)
+				finder.addMatchesToTermMap(wordMatches, matchesByTerm)
+			}
+
+			if wildcardFilter != nil {
+				wcMatches := wildcardFilter.filter(chunk, newMatches, eof)
+				finder.addMatchesToSolverMap(wcMatches, sortedMatchesByKeyword)
+				finder.addMatchesToTermMap(wcMatches, matchesByTerm)
+			}
+
+			if caseFilter != nil {
+				var positions *dsl.PositionMap
+				if normStream != nil {
+					positions = normStream.Positions()
+				}
+				caseMatches := caseFilter.filter(original, newMatches, offset+len(buf), positions)
+				finder.addMatchesToSolverMap(caseMatches, sortedMatchesByKeyword)
+				finder.addMatchesToTermMap(caseMatches, matchesByTerm)
+			}
+		}
+
+		if len(finder.regexes) > 0 {
+			var foundLiterals map[string]struct{}
+			if rgxPrefilter != nil {
+				foundLiterals = rgxPrefilter.found(offset)
+			}
+			rgxMatches, err := finder.findRegexes(buf, foundLiterals, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			newMatches := make([]*Match, 0, len(rgxMatches))
+			for _, match := range rgxMatches {
+				position := match.Position + offset
+				// matches that start on the bytes that will be searched again are left
+				// to the next step, where they can be found with more context, and
+				// matches that start inside a previous match of the same regex are skipped
+				// to keep the matches without overlaps as it would be on a single search.
+				if (!eof && match.Position >= tailStart) || position < lastRgxEndByTerm[match.Term] {
+					continue
+				}
+				lastRgxEndByTerm[match.Term] = position + match.Length
+				newMatches = append(newMatches, &Match{
+					Position: position,
+					Length:   match.Length,
+					Term:     match.Term,
+				})
+			}
+			finder.addMatchesToSolverMap(newMatches, sortedMatchesByKeyword)
+			finder.addMatchesToTermMap(newMatches, matchesByTerm)
+		}
+
+		if fuzzySearch != nil {
+			fuzzyMatches, err := fuzzySearch.search(chunk, eof)
+			if err != nil {
+				return nil, err
+			}
+			finder.addMatchesToSolverMap(fuzzyMatches, sortedMatchesByKeyword)
+			finder.addMatchesToTermMap(fuzzyMatches, matchesByTerm)
+		}
+
+		if eof {
+			break
+		}
+
+		offset += tailStart
+		tail = buf[tailStart:]
+	}
+
+	if normStream != nil {
+		mapMatchesToOriginal(matchesByTerm, normStream.Positions())
+	}
+	return finder.solveMatches(ctx, sortedMatchesByKeyword, matchesByTerm, wordIndex)
+}
+
+// streamSnapshot returns a copy of the finder with the expressions, terms, settings and engines
+// that are used to process a reader. The maps and slices of the terms are copied, since they are
+// changed in place when the expressions change. detached reports if all the engines in use are
+// ReplicableEngines, so they are replaced instead of rebuilt while the reader is processed
+// (see detachEngines) and the lock of the finder can be released.
+func (finder *Finder) streamSnapshot() (snapshot *Finder, detached bool) {
+	snapshot = &Finder{
+		expressions:        append([]exprWrapper(nil), finder.expressions...),
+		keywords:           copyStringSet(finder.keywords),
+		regexes:            copyStringSet(finder.regexes),
+		regexTerms:         make(map[string]dsl.RegexTerm, len(finder.regexTerms)),
+		regexLiterals:      copyStringSlices(finder.regexLiterals),
+		regexesByLiteral:   copyStringSlices(finder.regexesByLiteral),
+		regexPrefilter:     finder.regexPrefilter,
+		wordKeywords:       copyStringSet(finder.wordKeywords),
+		wildcards:          make(map[string]*dsl.Wildcard, len(finder.wildcards)),
+		wildcardsByAnchor:  make(map[string][]*dsl.Wildcard, len(finder.wildcardsByAnchor)),
+		fuzzyTerms:         make(map[string]dsl.FuzzyTerm, len(finder.fuzzyTerms)),
+		caseKeywords:       make(map[string]string, len(finder.caseKeywords)),
+		caseTermsByKeyword: copyStringSlices(finder.caseTermsByKeyword),
+		subEng:             finder.subEng,
+		rgxEng:             finder.rgxEng,
+		fuzzyEng:           finder.fuzzyEng,
+		caseSensitive:      finder.caseSensitive,
+		normalizer:         finder.normalizer,
+		caseNormalizer:     finder.caseNormalizer,
+		reportMatches:      finder.reportMatches,
+		version:            finder.version,
+		streamChunkSize:    finder.streamChunkSize,
+		streamRegexWindow:  finder.streamRegexWindow,
+		wordDistanceExprs:  finder.wordDistanceExprs,
+	}
+	for key, rt := range finder.regexTerms {
+		snapshot.regexTerms[key] = rt
+	}
+	for key, wc := range finder.wildcards {
+		snapshot.wildcards[key] = wc
+	}
+	for key, wcs := range finder.wildcardsByAnchor {
+		snapshot.wildcardsByAnchor[key] = append([]*dsl.Wildcard(nil), wcs...)
+	}
+	for key, ft := range finder.fuzzyTerms {
+		snapshot.fuzzyTerms[key] = ft
+	}
+	for key, keyword := range finder.caseKeywords {
+		snapshot.caseKeywords[key] = keyword
+	}
+
+	detached = true
+	if len(finder.keywords) > 0 || finder.usesRegexPrefilter() {
+		_, err := newEngineOf(finder.subEng)
+		detached = detached && err == nil
+	}
+	if len(finder.regexes) > 0 {
+		_, err := newEngineOf(finder.rgxEng)
+		detached = detached && err == nil
+	}
+	if len(finder.fuzzyTerms) > 0 {
+		_, err := newEngineOf(finder.fuzzyEng)
+		detached = detached && err == nil
+	}
+	return
+}
+
+// copyStringSet returns a copy of the set.
+func copyStringSet(set map[string]struct{}) map[string]struct{} {
+	newSet := make(map[string]struct{}, len(set))
+	for key := range set {
+		newSet[key] = struct{}{}
+	}
+	return newSet
+}
+
+// copyStringSlices returns a copy of the map and of its slices.
+func copyStringSlices(slices map[string][]string) map[string][]string {
+	newSlices := make(map[string][]string, len(slices))
+	for key, values := range slices {
+		newSlices[key] = append([]string(nil), values...)
+	}
+	return newSlices
+}
+
+// streamFuzzySearch searches the fuzzy terms on the chunks of a text. The last words
+// of each step are searched again on the next one, so only the matches that start on
+// words followed by enough complete words to hold any match are reported on each step.
+type streamFuzzySearch struct {
+	finder *Finder
+	// text holds the words that will be searched again and offset
+	// is the position of the first byte of text.
+	text   string
+	offset int
+	// maxWords is the maximum number of words of a match.
+	maxWords int
+}
+
+// newStreamFuzzySearch returns a new streamFuzzySearch for the fuzzy terms of the finder.
+func (finder *Finder) newStreamFuzzySearch() *streamFuzzySearch {
+	maxWords := 1
+	for _, ft := range finder.fuzzyTerms {
+		if words := len(wordSpans(ft.Term)) + ft.Distance; words > maxWords {
+			maxWords = words
+		}
+	}
+	return &streamFuzzySearch{finder: finder, maxWords: maxWords}
+}
+
+// search receives the next chunk and returns the matches of the fuzzy terms that start on
+// the words that will not be searched again. If eof is set all the matches are returned.
+func (sfs *streamFuzzySearch) search(chunk string, eof bool) ([]*Match, error) {
+	sfs.text += chunk
+	matches, err := sfs.finder.fuzzyEng.FindFuzzy(sfs.text)
+	if err != nil {
+		return nil, err
+	}
+
+	keepStart := len(sfs.text)
+	if !eof {
+		spans := wordSpans(sfs.text)
+		complete := len(spans)
+		// the last word may continue on the next chunk
+		if complete > 0 && spans[complete-1][1] == len(sfs.text) {
+			complete--
+		}
+		first := complete - sfs.maxWords + 1
+		if first < 0 {
+			first = 0
+		}
+		if first < len(spans) {
+			keepStart = spans[first][0]
+		}
+	}
+
+	newMatches := make([]*Match, 0, len(matches))
+	for _, match := range matches {
+		if match.Position >= keepStart {
+			continue
+		}
+		newMatches = append(newMatches, &Match{
+			Position: match.Position + sfs.offset,
+			Length:   match.Length,
+			Term:     match.Term,
+		})
+	}
+
+	sfs.text = sfs.text[keepStart:]
+	sfs.offset += keepStart
+	return newMatches, nil
+}
+
+// chunkReader reads a reader in chunks that do not split runes.
+type chunkReader struct {
+	reader  io.Reader
+	size    int
+	pending []byte
+}
+
+// next returns the next chunk and if the end of the reader was reached.
+// The bytes of an incomplete rune at the end of the chunk are kept to be
+// returned at the beginning of the next chunk.
+func (cr *chunkReader) next() (chunk string, eof bool, err error) {
+	data := make([]byte, len(cr.pending)+cr.size)
+	copy(data, cr.pending)
+	n, err := io.ReadFull(cr.reader, data[len(cr.pending):])
+	data = data[:len(cr.pending)+n]
+	cr.pending = nil
+
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		return string(data), true, nil
+	default:
+		return "", false, err
+	}
+
+	cut := incompleteRuneStart(data)
+	cr.pending = append(cr.pending, data[cut:]...)
+	return string(data[:cut]), false, nil
+}
+
+// incompleteRuneStart returns the position of the first byte of the
+// incomplete rune at the end of data, or len(data) if there is none.
+func incompleteRuneStart(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
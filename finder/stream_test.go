@@ -0,0 +1,208 @@
+package finder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fullTextStreamEngine implements StreamSubstringEngine by searching the
+// whole text received so far and returning only the matches that end on the last chunk.
+type fullTextStreamEngine struct {
+	CloudflareForkEngine
+}
+
+type fullTextStream struct {
+	eng  *fullTextStreamEngine
+	text string
+}
+
+func (eng *fullTextStreamEngine) Features() EngineFeatures {
+	return PositionsFeature | OverlappingFeature | StreamingFeature
+}
+
+func (eng *fullTextStreamEngine) NewStream() SubstringStream {
+	return &fullTextStream{eng: eng}
+}
+
+// unusedStreamEngine implements StreamSubstringEngine without
+// supporting the StreamingFeature, so its streams must not be used.
+type unusedStreamEngine struct {
+	CloudflareForkEngine
+}
+
+func (eng *unusedStreamEngine) NewStream() SubstringStream {
+	panic("the stream of an engine without the streaming feature was used")
+}
+
+func (stream *fullTextStream) FindSubstrings(chunk string) (matches []*Match, err error) {
+	start := len(stream.text)
+	stream.text += chunk
+	all, err := stream.eng.FindSubstrings(stream.text)
+	for _, match := range all {
+		if match.Position+match.Length > start {
+			matches = append(matches, match)
+		}
+	}
+	return
+}
+
+func TestProcessReader(t *testing.T) {
+	assert := assert.New(t)
+	expressions := []string{
+		`"foo bar"`,
+		`INORD("lorem" and "ipsum")`,
+		`INORD("ipsum" and "lorem")`,
+		`"ação" and not "missing"`,
+		`r"ba[rz] qux"`,
+		`r"[0-9]+"`,
+		`"foo" NEAR/1 "lorem"`,
+		`"lorem" ONEAR/6c ("bar" or "ipsum")`,
+		`W"bar" or W"ção"`,
+		`W"foo bar" and not W"lorem"`,
+		`"lor*" or "ba?"`,
+		`"*ç?o" and not "f*r"`,
+		`F1"lorem" or F1"foo bar"`,
+		`F2"acao" and not F1"ipsum"`,
+		`C"Foo" and not C"AÇÃO"`,
+		`C"ação" and C"lorem"`,
+	}
+	texts := []string{
+		"foo bar lorem ipsum",
+		"ipsum foo lorem bar baz qux",
+		"Foo Bar ação AÇÃO 12345 ipsum lorem",
+		"çççççççç foo barlorem ção ação",
+		"foo bar_ foo bar",
+		"barlorem lorem ação",
+		"",
+	}
+
+	engines := map[string]SubstringEngine{
+		"anknown":         &AnknownEngine{},
+		"cloudflare fork": &CloudflareForkEngine{},
+		"stream":          &fullTextStreamEngine{},
+		"unused stream":   &unusedStreamEngine{},
+		"aho-corasick":    &AhoCorasickEngine{},
+	}
+
+	for name, eng := range engines {
+		for _, caseSensitive := range []bool{true, false} {
+			findthem := NewFinder(eng, &RegexpEngine{}, caseSensitive)
+			findthem.SetMatchReporting(true)
+			assert.Nil(findthem.AddExpressions(expressions))
+			for _, chunkSize := range []int{1, 3, 4, 7, 1024} {
+				findthem.SetStreamOptions(chunkSize, 8)
+				for _, text := range texts {
+					message := fmt.Sprintf("%s case sensitive %v chunk %d text %q", name, caseSensitive, chunkSize, text)
+					expected, err := findthem.ProcessText(text)
+					assert.Nil(err, message)
+
+					expRes, err := findthem.ProcessReader(strings.NewReader(text))
+					assert.Nil(err, message)
+					assert.Equal(expected, expRes, message)
+
+					expRes, err = findthem.ProcessReader(iotest.OneByteReader(strings.NewReader(text)))
+					assert.Nil(err, message+" one byte reader")
+					assert.Equal(expected, expRes, message+" one byte reader")
+				}
+			}
+		}
+	}
+}
+
+func TestProcessReaderError(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, true)
+	assert.Nil(findthem.AddExpression(`"foo"`))
+
+	readErr := fmt.Errorf("read error")
+	_, err := findthem.ProcessReader(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("foo"))))
+	assert.Equal(iotest.ErrTimeout, err)
+
+	_, err = findthem.ProcessReader(iotest.ErrReader(readErr))
+	assert.Equal(readErr, err)
+}
+
+func TestProcessReaderSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&AhoCorasickEngine{}, &RegexpEngine{}, false)
+	findthem.SetStreamOptions(4, 0)
+	assert.Nil(findthem.AddExpression(`"foo" and R"ba[rz]"`))
+
+	reader, writer := io.Pipe()
+	streamed := make(chan []ExpressionResult)
+	go func() {
+		expRes, err := findthem.ProcessReader(reader)
+		assert.Nil(err)
+		streamed <- expRes
+	}()
+	// the first chunk is read after the snapshot is taken
+	_, err := writer.Write([]byte("abc "))
+	assert.Nil(err)
+
+	changed := make(chan struct{})
+	go func() {
+		defer close(changed)
+		assert.Nil(findthem.UpdateExpression(0, `"qux" and R"qu+x"`, ""))
+		assert.Nil(findthem.AddExpression(`"bar"`))
+		expRes, err := findthem.ProcessText("qux bar")
+		assert.Nil(err)
+		assert.Len(expRes, 2)
+	}()
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		assert.Fail("the expressions are not changed while the reader is processed")
+	}
+
+	// the terms of the reader are only on the chunks read after the change
+	_, err = writer.Write([]byte("foo bar qux"))
+	assert.Nil(err)
+	assert.Nil(writer.Close())
+	assert.Equal([]ExpressionResult{
+		{ExpresionIndex: 0, ExpresionStr: `"foo" and R"ba[rz]"`, Version: 1},
+	}, <-streamed, "the reader is processed with the expressions of when it started")
+	assert.Equal(int32(0), findthem.streams)
+
+	_, detached := NewFinder(new(SubstringEngineMock), &RegexpEngine{}, false).streamSnapshot()
+	assert.True(detached, "engines without terms are not searched")
+	mockFinder := NewFinder(new(SubstringEngineMock), &RegexpEngine{}, false)
+	assert.Nil(mockFinder.AddExpression(`"foo"`))
+	_, detached = mockFinder.streamSnapshot()
+	assert.False(detached, "the engine can not be replicated")
+}
+
+func TestProcessReaderContext(t *testing.T) {
+	assert := assert.New(t)
+	findthem := NewFinder(&CloudflareForkEngine{}, &RegexpEngine{}, false)
+	assert.Nil(findthem.AddExpression(`"foo"`))
+	assert.Nil(findthem.AddExpression(`R"ba[rz]"`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	expRes, err := findthem.ProcessReaderContext(ctx, strings.NewReader("foo bar"))
+	assert.Nil(err)
+	assert.Len(expRes, 2)
+
+	cancel()
+	expRes, err = findthem.ProcessReaderContext(ctx, strings.NewReader("foo bar"))
+	assert.Nil(expRes)
+	assert.Equal(&PartialResultError{Stage: SubstringSearchStage, Total: 2, Err: context.Canceled}, err)
+	assert.True(errors.Is(err, context.Canceled))
+}
+
+func TestIncompleteRuneStart(t *testing.T) {
+	assert := assert.New(t)
+	data := []byte("aç")
+	assert.Equal(3, incompleteRuneStart(data))
+	assert.Equal(1, incompleteRuneStart(data[:2]))
+	assert.Equal(1, incompleteRuneStart(data[:1]))
+	assert.Equal(0, incompleteRuneStart([]byte{}))
+	assert.Equal(2, incompleteRuneStart([]byte("a\xff")))
+}
@@ -1,133 +1,214 @@
-package finder
-
-import (
-	goahocorasick "github.com/anknown/ahocorasick"
-	cfahocorasick "github.com/cloudflare/ahocorasick"
-	forkahocorasick "github.com/pedroegsilva/ahocorasick/ahocorasick"
-)
-
-// SubstringEngine interface that finder
-// uses to search for substrings on a given text
-type SubstringEngine interface {
-	// BuildEngine receive the unique terms that need
-	// to be searched to create the engine support structures
-	BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error)
-	// FindSubstrings receive the text and searchs for the feeded
-	// terms
-	FindSubstrings(text string) (matches []*Match, err error)
-}
-
-// AnknownEngine implements SubstringEngine using the
-// github.com/anknown/ahocorasick package
-type AnknownEngine struct {
-	AhoEngine *goahocorasick.Machine
-}
-
-// BuildEngine implements BuildEngine using the
-// github.com/anknown/ahocorasick package
-func (am *AnknownEngine) BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error) {
-	dict := [][]rune{}
-	for key := range keywords {
-		dict = append(dict, []rune(key))
-	}
-	ahoMachine := new(goahocorasick.Machine)
-	err = ahoMachine.Build(dict)
-	if err != nil {
-		return
-	}
-	am.AhoEngine = ahoMachine
-	return
-}
-
-// FindSubstrings implements FindSubstrings using the
-// github.com/anknown/ahocorasick package
-func (am *AnknownEngine) FindSubstrings(text string) (matches []*Match, err error) {
-	ms := am.AhoEngine.MultiPatternSearch([]rune(text), false)
-	for _, m := range ms {
-		matches = append(matches, &Match{
-			Term:     string(m.Word),
-			Position: m.Pos,
-		})
-	}
-	return
-}
-
-// CloudflareEngine implements SubstringEngine using the
-// github.com/cloudflare/ahocorasick package. This engine
-// does not support the use of INORD operator
-type CloudflareEngine struct {
-	Matcher *cfahocorasick.Matcher
-	Dict    []string
-}
-
-// BuildEngine implements BuildEngine using the
-// github.com/cloudflare/ahocorasick package
-func (cfm *CloudflareEngine) BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error) {
-	dict := []string{}
-	for key := range keywords {
-		dict = append(dict, key)
-	}
-	cfm.Matcher = cfahocorasick.NewStringMatcher(dict)
-	cfm.Dict = dict
-	return
-}
-
-// FindSubstrings implements FindSubstrings using the
-// github.com/cloudflare/ahocorasick package
-func (cfm *CloudflareEngine) FindSubstrings(text string) (matches []*Match, err error) {
-	ms := cfm.Matcher.Match([]byte(text))
-	for _, m := range ms {
-		matches = append(matches, &Match{
-			Term:     cfm.Dict[m],
-			Position: 0,
-		})
-	}
-	return
-}
-
-// CloudflareEngine implements SubstringEngine using the
-// github.com/pedroegsilva/ahocorasick package. This engine
-// does not support the use of INORD operator
-type CloudflareForkEngine struct {
-	Matcher *forkahocorasick.Matcher
-	Dict    []string
-}
-
-// BuildEngine implements BuildEngine using the
-// github.com/pedroegsilva/ahocorasick package
-func (cffm *CloudflareForkEngine) BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error) {
-	dict := []string{}
-	for key := range keywords {
-		dict = append(dict, key)
-	}
-	cffm.Matcher = forkahocorasick.NewStringMatcher(dict)
-	cffm.Dict = dict
-	return
-}
-
-// FindSubstrings implements FindSubstrings using the
-// github.com/pedroegsilva/ahocorasick package
-func (cffm *CloudflareForkEngine) FindSubstrings(text string) (matches []*Match, err error) {
-	ms := cffm.Matcher.MatchAll([]byte(text))
-	for _, hit := range ms {
-		matches = append(matches, &Match{
-			Term:     cffm.Dict[hit.DictIndex],
-			Position: hit.Position,
-		})
-	}
-	return
-}
-
-// EmptyEngine implements SubstringEngine with an nop
-type EmptyEngine struct {
-}
-
-// BuildEngine implements BuildEngine with an nop
-func (pdm *EmptyEngine) BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error) {
-	return
-}
-
-// BuildEngine implements FindSubstrings with an nop
-func (pdm *EmptyEngine) FindSubstrings(text string) (matches []*Match, err error) {
-	return
-}
+package finder
+
+import (
+	"sync"
+
+	goahocorasick "github.com/anknown/ahocorasick"
+	cfahocorasick "github.com/cloudflare/ahocorasick"
+	forkahocorasick "github.com/pedroegsilva/ahocorasick/ahocorasick"
+)
+
+// SubstringEngine interface that finder
+// uses to search for substrings on a given text
+type SubstringEngine interface {
+	// BuildEngine receive the unique terms that need
+	// to be searched to create the engine support structures
+	BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error)
+	// FindSubstrings receive the text and searchs for the feeded
+	// terms. It must be safe to call FindSubstrings from multiple
+	// goroutines at once.
+	FindSubstrings(text string) (matches []*Match, err error)
+}
+
+// StreamSubstringEngine is a SubstringEngine that is able to search a text
+// that is split in chunks, keeping the state of the search between the chunks.
+// It is used by the finder ProcessReader when available.
+type StreamSubstringEngine interface {
+	SubstringEngine
+	// NewStream returns a new SubstringStream that searches
+	// for the terms used on the last BuildEngine call.
+	NewStream() SubstringStream
+}
+
+// SubstringStream searches the terms on consecutive chunks of a text.
+type SubstringStream interface {
+	// FindSubstrings receive the next chunk of the text and returns the
+	// matches that end on it. The positions are relative to the start of
+	// the first chunk.
+	FindSubstrings(chunk string) (matches []*Match, err error)
+}
+
+// AnknownEngine implements SubstringEngine using the
+// github.com/anknown/ahocorasick package
+type AnknownEngine struct {
+	AhoEngine *goahocorasick.Machine
+}
+
+// BuildEngine implements BuildEngine using the
+// github.com/anknown/ahocorasick package
+func (am *AnknownEngine) BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error) {
+	dict := [][]rune{}
+	for key := range keywords {
+		dict = append(dict, []rune(key))
+	}
+	ahoMachine := new(goahocorasick.Machine)
+	err = ahoMachine.Build(dict)
+	if err != nil {
+		return
+	}
+	am.AhoEngine = ahoMachine
+	return
+}
+
+// NewEngine implements ReplicableEngine.
+func (am *AnknownEngine) NewEngine() interface{} {
+	return &AnknownEngine{}
+}
+
+// Features implements FeaturesEngine. The engine reports the
+// positions of every match, including the overlapping ones.
+func (am *AnknownEngine) Features() EngineFeatures {
+	return PositionsFeature | OverlappingFeature
+}
+
+// FindSubstrings implements FindSubstrings using the
+// github.com/anknown/ahocorasick package. The package returns
+// the positions as rune indexes, so they are converted to byte offsets.
+func (am *AnknownEngine) FindSubstrings(text string) (matches []*Match, err error) {
+	runes := []rune(text)
+	ms := am.AhoEngine.MultiPatternSearch(runes, false)
+	if len(ms) == 0 {
+		return
+	}
+
+	byteOffsets := make([]int, 0, len(runes)+1)
+	for offset := range text {
+		byteOffsets = append(byteOffsets, offset)
+	}
+	byteOffsets = append(byteOffsets, len(text))
+
+	for _, m := range ms {
+		matches = append(matches, &Match{
+			Term:     string(m.Word),
+			Position: byteOffsets[m.Pos],
+			Length:   byteOffsets[m.Pos+len(m.Word)] - byteOffsets[m.Pos],
+		})
+	}
+	return
+}
+
+// CloudflareEngine implements SubstringEngine using the
+// github.com/cloudflare/ahocorasick package. This engine reports each
+// found term once without its position, so it does not support the
+// operators that need positions (INORD, NEAR, ONEAR) nor COUNT.
+type CloudflareEngine struct {
+	Matcher *cfahocorasick.Matcher
+	Dict    []string
+}
+
+// BuildEngine implements BuildEngine using the
+// github.com/cloudflare/ahocorasick package
+func (cfm *CloudflareEngine) BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error) {
+	dict := []string{}
+	for key := range keywords {
+		dict = append(dict, key)
+	}
+	cfm.Matcher = cfahocorasick.NewStringMatcher(dict)
+	cfm.Dict = dict
+	return
+}
+
+// NewEngine implements ReplicableEngine.
+func (cfm *CloudflareEngine) NewEngine() interface{} {
+	return &CloudflareEngine{}
+}
+
+// Features implements FeaturesEngine. The engine does not report
+// the positions of the matches nor the repeated matches of a term.
+func (cfm *CloudflareEngine) Features() EngineFeatures {
+	return 0
+}
+
+// FindSubstrings implements FindSubstrings using the
+// github.com/cloudflare/ahocorasick package
+func (cfm *CloudflareEngine) FindSubstrings(text string) (matches []*Match, err error) {
+	ms := cfm.Matcher.MatchThreadSafe([]byte(text))
+	for _, m := range ms {
+		matches = append(matches, &Match{
+			Term:     cfm.Dict[m],
+			Position: 0,
+			Length:   len(cfm.Dict[m]),
+		})
+	}
+	return
+}
+
+// CloudflareForkEngine implements SubstringEngine using the
+// github.com/pedroegsilva/ahocorasick package. The matcher of this
+// package is not safe for concurrent use, so the searches are serialized.
+type CloudflareForkEngine struct {
+	Matcher *forkahocorasick.Matcher
+	Dict    []string
+	mu      sync.Mutex
+}
+
+// BuildEngine implements BuildEngine using the
+// github.com/pedroegsilva/ahocorasick package
+func (cffm *CloudflareForkEngine) BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error) {
+	dict := []string{}
+	for key := range keywords {
+		dict = append(dict, key)
+	}
+	cffm.Matcher = forkahocorasick.NewStringMatcher(dict)
+	cffm.Dict = dict
+	return
+}
+
+// NewEngine implements ReplicableEngine.
+func (cffm *CloudflareForkEngine) NewEngine() interface{} {
+	return &CloudflareForkEngine{}
+}
+
+// Features implements FeaturesEngine. The engine reports the
+// positions of every match, including the overlapping ones.
+func (cffm *CloudflareForkEngine) Features() EngineFeatures {
+	return PositionsFeature | OverlappingFeature
+}
+
+// FindSubstrings implements FindSubstrings using the
+// github.com/pedroegsilva/ahocorasick package. The package returns
+// the position of the last byte of the match, so it is converted to
+// the position of the first byte.
+func (cffm *CloudflareForkEngine) FindSubstrings(text string) (matches []*Match, err error) {
+	cffm.mu.Lock()
+	ms := cffm.Matcher.MatchAll([]byte(text))
+	cffm.mu.Unlock()
+	for _, hit := range ms {
+		term := cffm.Dict[hit.DictIndex]
+		matches = append(matches, &Match{
+			Term:     term,
+			Position: hit.Position - len(term) + 1,
+			Length:   len(term),
+		})
+	}
+	return
+}
+
+// EmptyEngine implements SubstringEngine with an nop
+type EmptyEngine struct {
+}
+
+// BuildEngine implements BuildEngine with an nop
+func (pdm *EmptyEngine) BuildEngine(keywords map[string]struct{}, caseSensitive bool) (err error) {
+	return
+}
+
+// NewEngine implements ReplicableEngine.
+func (pdm *EmptyEngine) NewEngine() interface{} {
+	return &EmptyEngine{}
+}
+
+// BuildEngine implements FindSubstrings with an nop
+func (pdm *EmptyEngine) FindSubstrings(text string) (matches []*Match, err error) {
+	return
+}
@@ -0,0 +1,167 @@
+package finder
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+)
+
+// addWildcardTerms increments the reference count of the wildcard
+// patterns and adds the new ones to the wildcards of the finder.
+func (finder *Finder) addWildcardTerms(patterns map[string]struct{}) {
+	if len(patterns) == 0 {
+		return
+	}
+	if finder.wildcards == nil {
+		finder.wildcards = make(map[string]*dsl.Wildcard)
+	}
+	if finder.wildcardsRefCount == nil {
+		finder.wildcardsRefCount = make(map[string]int)
+	}
+
+	for pattern := range patterns {
+		finder.wildcardsRefCount[pattern]++
+		if _, ok := finder.wildcards[pattern]; ok {
+			continue
+		}
+		// the pattern was already validated by the parser
+		wc, err := dsl.NewWildcard(pattern)
+		if err != nil {
+			continue
+		}
+		finder.wildcards[pattern] = wc
+	}
+	finder.indexWildcards()
+}
+
+// removeWildcardTerms decrements the reference count of the wildcard
+// patterns and removes the ones that are no longer used.
+func (finder *Finder) removeWildcardTerms(patterns map[string]struct{}) {
+	if len(patterns) == 0 {
+		return
+	}
+	for pattern := range patterns {
+		finder.wildcardsRefCount[pattern]--
+		if finder.wildcardsRefCount[pattern] <= 0 {
+			delete(finder.wildcardsRefCount, pattern)
+			delete(finder.wildcards, pattern)
+		}
+	}
+	finder.indexWildcards()
+}
+
+// indexWildcards groups the wildcards by their anchors.
+func (finder *Finder) indexWildcards() {
+	finder.wildcardsByAnchor = make(map[string][]*dsl.Wildcard)
+	for _, wc := range finder.wildcards {
+		finder.wildcardsByAnchor[wc.Anchor()] = append(finder.wildcardsByAnchor[wc.Anchor()], wc)
+	}
+	for _, wcs := range finder.wildcardsByAnchor {
+		sort.Slice(wcs, func(i, j int) bool { return wcs[i].Pattern < wcs[j].Pattern })
+	}
+}
+
+// verifyWildcardMatches returns the matches of the wildcard terms, verifying the patterns around
+// each match of their anchors. The returned matches have the dsl.WildcardTermKey of the pattern
+// as Term. textOffset is the position of the first byte of text and seenStarts holds the start
+// of the matches already found for each term, so a match is not reported twice when it is
+// verified from more than one anchor.
+func (finder *Finder) verifyWildcardMatches(
+	matches []*Match,
+	text string,
+	textOffset int,
+	seenStarts map[string]map[int]struct{},
+) []*Match {
+	wcMatches := make([]*Match, 0)
+	for _, match := range matches {
+		term := match.Term
+		if !finder.caseSensitive {
+			term = strings.ToLower(term)
+		}
+		for _, wc := range finder.wildcardsByAnchor[term] {
+			start, end, ok := wc.MatchAt(text, match.Position-textOffset)
+			if !ok {
+				continue
+			}
+
+			key := dsl.WildcardTermKey(wc.Pattern)
+			start += textOffset
+			if _, ok := seenStarts[key]; !ok {
+				seenStarts[key] = make(map[int]struct{})
+			}
+			if _, ok := seenStarts[key][start]; ok {
+				continue
+			}
+			seenStarts[key][start] = struct{}{}
+
+			wcMatches = append(wcMatches, &Match{
+				Position: start,
+				Length:   end + textOffset - start,
+				Term:     key,
+			})
+		}
+	}
+
+	sort.SliceStable(wcMatches, func(i, j int) bool {
+		return wcMatches[i].Position < wcMatches[j].Position
+	})
+	return wcMatches
+}
+
+// streamWildcardFilter verifies the wildcard terms on a text that is processed in chunks.
+// The anchor matches are only verified when there are at least window bytes after them,
+// or the end of the text was reached, and the last bytes of the text are kept to verify
+// the patterns before the anchors. So, as the regexes, the wildcard matches that extend
+// more than window bytes from the anchor may not be found completely.
+type streamWildcardFilter struct {
+	finder     *Finder
+	text       string
+	offset     int
+	window     int
+	keep       int
+	pending    []*Match
+	seenStarts map[string]map[int]struct{}
+}
+
+// newStreamWildcardFilter returns a streamWildcardFilter with the given window.
+func (finder *Finder) newStreamWildcardFilter(window int) *streamWildcardFilter {
+	longestAnchor := 0
+	for anchor := range finder.wildcardsByAnchor {
+		if len(anchor) > longestAnchor {
+			longestAnchor = len(anchor)
+		}
+	}
+	return &streamWildcardFilter{
+		finder:     finder,
+		window:     window,
+		keep:       2*window + longestAnchor,
+		seenStarts: make(map[string]map[int]struct{}),
+	}
+}
+
+// filter appends the chunk to the text and returns the wildcard matches verified from the
+// given anchor matches and from the pending ones. The positions of the matches must be
+// offsets from the start of the text and the matches must end on the chunk.
+func (swf *streamWildcardFilter) filter(chunk string, matches []*Match, eof bool) []*Match {
+	swf.text += chunk
+	textEnd := swf.offset + len(swf.text)
+
+	candidates := append(swf.pending, matches...)
+	swf.pending = nil
+	checked := make([]*Match, 0, len(candidates))
+	for _, match := range candidates {
+		if !eof && match.Position+match.Length+swf.window > textEnd {
+			swf.pending = append(swf.pending, match)
+			continue
+		}
+		checked = append(checked, match)
+	}
+	wcMatches := swf.finder.verifyWildcardMatches(checked, swf.text, swf.offset, swf.seenStarts)
+
+	if cut := len(swf.text) - swf.keep; cut > 0 {
+		swf.offset += cut
+		swf.text = swf.text[cut:]
+	}
+	return wcMatches
+}
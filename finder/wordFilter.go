@@ -0,0 +1,105 @@
+package finder
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pedroegsilva/gofindthem/dsl"
+)
+
+// filterWordMatches returns the matches of the whole word terms, that are the matches
+// of their keywords that are not preceded nor followed by a word rune (see dsl.IsWordRune).
+// The returned matches have the dsl.WordTermKey of the keyword as Term. textOffset is the
+// position of the first byte of text, that must have the rune before each match unless
+// the match is at the start of the whole text.
+func (finder *Finder) filterWordMatches(matches []*Match, text string, textOffset int) []*Match {
+	wordMatches := make([]*Match, 0)
+	for _, match := range matches {
+		term := match.Term
+		if !finder.caseSensitive {
+			term = strings.ToLower(term)
+		}
+		if _, ok := finder.wordKeywords[term]; !ok {
+			continue
+		}
+		start := match.Position - textOffset
+		end := start + match.Length
+		if start < 0 || end > len(text) || !isWholeWord(text, start, end) {
+			continue
+		}
+		wordMatches = append(wordMatches, &Match{
+			Position: match.Position,
+			Length:   match.Length,
+			Term:     dsl.WordTermKey(term),
+		})
+	}
+	return wordMatches
+}
+
+// isWholeWord returns true if the bytes between start and end of the
+// text are not preceded nor followed by a word rune.
+func isWholeWord(text string, start int, end int) bool {
+	if start > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(text[:start])
+		if dsl.IsWordRune(prev) {
+			return false
+		}
+	}
+	if end < len(text) {
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		if dsl.IsWordRune(next) {
+			return false
+		}
+	}
+	return true
+}
+
+// streamWordFilter filters the matches of the whole word terms on a text that is
+// processed in chunks. It keeps the last bytes of the text to check the rune before
+// the matches and holds the matches that end on the last chunk until the next one is read.
+type streamWordFilter struct {
+	finder  *Finder
+	text    string
+	offset  int
+	keep    int
+	pending []*Match
+}
+
+// newStreamWordFilter returns a streamWordFilter that keeps enough bytes
+// to check the rune before the longest whole word keyword.
+func (finder *Finder) newStreamWordFilter() *streamWordFilter {
+	keep := 0
+	for key := range finder.wordKeywords {
+		if len(key) > keep {
+			keep = len(key)
+		}
+	}
+	return &streamWordFilter{finder: finder, keep: keep + utf8.UTFMax}
+}
+
+// filter appends the chunk to the text and returns the whole word matches of the given
+// keyword matches and of the pending matches that could be checked. The positions of
+// the matches must be offsets from the start of the text and the matches must end on the chunk.
+func (swf *streamWordFilter) filter(chunk string, matches []*Match, eof bool) []*Match {
+	swf.text += chunk
+	textEnd := swf.offset + len(swf.text)
+
+	candidates := append(swf.pending, matches...)
+	swf.pending = nil
+	checked := make([]*Match, 0, len(candidates))
+	for _, match := range candidates {
+		// the rune after the match is on the next chunk
+		if !eof && match.Position+match.Length == textEnd {
+			swf.pending = append(swf.pending, match)
+			continue
+		}
+		checked = append(checked, match)
+	}
+	wordMatches := swf.finder.filterWordMatches(checked, swf.text, swf.offset)
+
+	if cut := len(swf.text) - swf.keep; cut > 0 {
+		swf.offset += cut
+		swf.text = swf.text[cut:]
+	}
+	return wordMatches
+}
@@ -8,4 +8,5 @@ require (
 	github.com/cloudflare/ahocorasick v0.0.0-20210425175752-730270c3e184
 	github.com/pedroegsilva/ahocorasick v0.1.0
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/text v0.3.7
 )
@@ -0,0 +1,90 @@
+package finder
+
+import (
+	"context"
+
+	"github.com/pedroegsilva/gofindthem/internal/batch"
+)
+
+// BatchResult holds the result of a single document of a batch.
+// Index is the position of the document on the input, Version is the version of
+// the set of rules that was used to process it and Err is the error that
+// happened while processing that document, if any.
+type BatchResult struct {
+	Index             int
+	ExpressionsByRule map[string][]string
+	Version           uint64
+	Err               error
+}
+
+// ProcessBatch extract all tags and evaluate all rules for each of the given objects
+// using the given number of workers. Returns one BatchResult for each object, ordered
+// by the index of the object. includePaths and excludePaths work as on ProcessObject.
+// An error on an object does not stop the processing of the others, it is set on its BatchResult.
+// If workers is lower than 1, a single worker is used.
+func (rf *GroupFinder) ProcessBatch(
+	objs []interface{},
+	includePaths []string,
+	excludePaths []string,
+	workers int,
+) []BatchResult {
+	input := make(chan interface{})
+	go func() {
+		for _, obj := range objs {
+			input <- obj
+		}
+		close(input)
+	}()
+
+	results := make([]BatchResult, len(objs))
+	for res := range rf.ProcessChannel(context.Background(), input, includePaths, excludePaths, workers, false) {
+		results[res.Index] = res
+	}
+	return results
+}
+
+// ProcessChannel extract all tags and evaluate all rules for each object received from the
+// channel using the given number of workers and sends a BatchResult for each object on the
+// returned channel. Index is the order in which the object was received. If preserveOrder is
+// set the results are sent in the same order as the objects were received, otherwise they are
+// sent as soon as they are ready. The returned channel is closed after the objs channel is closed
+// and all objects are processed. If the consumer stops reading the results it must cancel the
+// context, that stops the workers and closes the returned channel. The objects are processed
+// with ProcessObjectContext and includePaths and excludePaths work as on ProcessObject.
+// An error on an object does not stop the processing of the others, it is set on its BatchResult.
+// If workers is lower than 1, a single worker is used.
+func (rf *GroupFinder) ProcessChannel(
+	ctx context.Context,
+	objs <-chan interface{},
+	includePaths []string,
+	excludePaths []string,
+	workers int,
+	preserveOrder bool,
+) <-chan BatchResult {
+	next := func(ctx context.Context) (interface{}, bool) {
+		select {
+		case obj, ok := <-objs:
+			return obj, ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	process := func(obj interface{}) interface{} {
+		expressionsByRule, version, err := rf.ProcessObjectContext(ctx, obj, includePaths, excludePaths)
+		return BatchResult{ExpressionsByRule: expressionsByRule, Version: version, Err: err}
+	}
+
+	results := make(chan BatchResult)
+	go func() {
+		defer close(results)
+		for res := range batch.Process(ctx, workers, preserveOrder, next, process) {
+			batchRes := res.Value.(BatchResult)
+			batchRes.Index = res.Index
+			select {
+			case results <- batchRes:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return results
+}
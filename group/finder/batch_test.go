@@ -0,0 +1,128 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	gofindthem "github.com/pedroegsilva/gofindthem/finder"
+	"github.com/stretchr/testify/assert"
+)
+
+// errorEngine fails to search any text that contains "bad"
+type errorEngine struct {
+	gofindthem.CloudflareForkEngine
+}
+
+func (eng *errorEngine) FindSubstrings(text string) (matches []*gofindthem.Match, err error) {
+	if text == "bad" {
+		return nil, fmt.Errorf("error on bad")
+	}
+	return eng.CloudflareForkEngine.FindSubstrings(text)
+}
+
+func newBatchTestGroupFinder(assert *assert.Assertions) *GroupFinder {
+	gft, err := gofindthem.NewFinderWithExpressions(
+		&errorEngine{},
+		&gofindthem.EmptyRgxEngine{},
+		false,
+		map[string][]string{"tag1": {`"foo"`}},
+	)
+	assert.Nil(err)
+	gftg, err := NewFinderWithRules(gft, map[string][]string{"rule1": {`"tag1:Field"`}})
+	assert.Nil(err)
+	return gftg
+}
+
+type batchTestObj struct {
+	Field string
+	Other string
+}
+
+func TestProcessBatch(t *testing.T) {
+	assert := assert.New(t)
+	gftg := newBatchTestGroupFinder(assert)
+
+	objs := []interface{}{
+		batchTestObj{Field: "foo"},
+		batchTestObj{Other: "foo"},
+		batchTestObj{Field: "bad"},
+		map[string]interface{}{"Field": "some foo"},
+	}
+	expected := []BatchResult{
+		{Index: 0, ExpressionsByRule: map[string][]string{"rule1": {`"tag1:Field"`}}, Version: 1},
+		{Index: 1, ExpressionsByRule: map[string][]string{}, Version: 1},
+		{Index: 2, Version: 1, Err: fmt.Errorf("error on bad")},
+		{Index: 3, ExpressionsByRule: map[string][]string{"rule1": {`"tag1:Field"`}}, Version: 1},
+	}
+
+	for _, workers := range []int{0, 1, 2, 8} {
+		message := fmt.Sprintf("%d workers", workers)
+		assert.Equal(expected, gftg.ProcessBatch(objs, nil, nil, workers), message)
+	}
+
+	expected[0].ExpressionsByRule = map[string][]string{}
+	expected[3].ExpressionsByRule = map[string][]string{}
+	assert.Equal(expected[:2], gftg.ProcessBatch(objs[:2], nil, []string{"Field"}, 2), "exclude paths")
+}
+
+func TestProcessChannel(t *testing.T) {
+	assert := assert.New(t)
+	gftg := newBatchTestGroupFinder(assert)
+
+	numObjs := 50
+	for _, preserveOrder := range []bool{true, false} {
+		message := fmt.Sprintf("preserve order %v", preserveOrder)
+		objs := make(chan interface{})
+		go func() {
+			for i := 0; i < numObjs; i++ {
+				if i%2 == 0 {
+					objs <- batchTestObj{Field: "foo"}
+				} else {
+					objs <- batchTestObj{Field: "bad"}
+				}
+			}
+			close(objs)
+		}()
+
+		count := 0
+		for res := range gftg.ProcessChannel(context.Background(), objs, nil, nil, 3, preserveOrder) {
+			if preserveOrder {
+				assert.Equal(count, res.Index, message)
+			}
+			if res.Index%2 == 0 {
+				assert.Nil(res.Err, message)
+				assert.Equal(map[string][]string{"rule1": {`"tag1:Field"`}}, res.ExpressionsByRule, message)
+			} else {
+				assert.Equal(fmt.Errorf("error on bad"), res.Err, message)
+			}
+			count++
+		}
+		assert.Equal(numObjs, count, message)
+	}
+}
+
+func TestProcessChannelCancel(t *testing.T) {
+	assert := assert.New(t)
+	gftg := newBatchTestGroupFinder(assert)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	objs := make(chan interface{})
+	go func() {
+		for {
+			select {
+			case objs <- batchTestObj{Field: "foo"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := gftg.ProcessChannel(ctx, objs, nil, nil, 3, true)
+	res := <-results
+	assert.Equal(0, res.Index)
+	assert.Nil(res.Err)
+	cancel()
+	for range results {
+	}
+}
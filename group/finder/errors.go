@@ -0,0 +1,47 @@
+package finder
+
+import "fmt"
+
+// Stages of the processing of a document that are reported by PartialResultError.
+const (
+	TaggingStage = "tagging"
+	RulesStage   = "rules"
+)
+
+// PartialResultError is returned when the processing of a document is stopped by its
+// context. Stage is the processing stage that was not completed. On the tagging stage
+// Field is the path of the field that was being tagged and Processed is how many fields
+// were tagged before the stop. On the rules stage Processed is how many of the Total
+// rules expressions were evaluated before the stop. Err is the error that stopped the processing.
+type PartialResultError struct {
+	Stage     string
+	Field     string
+	Processed int
+	Total     int
+	Err       error
+}
+
+// Error implements the error interface
+func (pre *PartialResultError) Error() string {
+	if pre.Stage == TaggingStage {
+		return fmt.Sprintf(
+			"processing stopped at %s stage on field '%s' after tagging %d fields: %v",
+			pre.Stage,
+			pre.Field,
+			pre.Processed,
+			pre.Err,
+		)
+	}
+	return fmt.Sprintf(
+		"processing stopped at %s stage after evaluating %d of %d expressions: %v",
+		pre.Stage,
+		pre.Processed,
+		pre.Total,
+		pre.Err,
+	)
+}
+
+// Unwrap returns the error that stopped the processing
+func (pre *PartialResultError) Unwrap() error {
+	return pre.Err
+}
@@ -1,19 +1,24 @@
 package finder
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
+	"sync"
 
 	"github.com/pedroegsilva/gofindthem/finder"
 	"github.com/pedroegsilva/gofindthem/group/dsl"
 )
 
-// GroupFinder stores all values needed for the rules
+// GroupFinder stores all values needed for the rules.
+// It is safe to use a GroupFinder from multiple goroutines.
 type GroupFinder struct {
+	mu                          sync.RWMutex
 	findthem                    *finder.Finder
 	expressionWrapperByExprName map[string][]ExpressionWrapper
 	fields                      map[string]struct{}
 	tags                        map[string]struct{}
+	version                     uint64
 }
 
 // ExpressionWrapper store the parsed expression and the raw expressions
@@ -41,6 +46,14 @@ func NewFinderWithRules(findthem *finder.Finder, rulesByName map[string][]string
 
 // AddRule adds the given expressions with the rule name to the tagger.
 func (rf *GroupFinder) AddRule(ruleName string, expressions []string) error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	rf.version++
+	return rf.addRule(ruleName, expressions)
+}
+
+// addRule implements AddRule
+func (rf *GroupFinder) addRule(ruleName string, expressions []string) error {
 	for _, rawExpr := range expressions {
 		p := dsl.NewParser(strings.NewReader(rawExpr))
 		exp, err := p.Parse()
@@ -64,8 +77,16 @@ func (rf *GroupFinder) AddRule(ruleName string, expressions []string) error {
 
 // AddRules adds the given expressions with the rule names (key of the map) to the tagger.
 func (rf *GroupFinder) AddRules(rulesByName map[string][]string) error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	rf.version++
+	return rf.addRules(rulesByName)
+}
+
+// addRules implements AddRules
+func (rf *GroupFinder) addRules(rulesByName map[string][]string) error {
 	for key, exprs := range rulesByName {
-		err := rf.AddRule(key, exprs)
+		err := rf.addRule(key, exprs)
 		if err != nil {
 			return err
 		}
@@ -73,8 +94,48 @@ func (rf *GroupFinder) AddRules(rulesByName map[string][]string) error {
 	return nil
 }
 
+// ReplaceRules replaces the finder and all the rules of the group finder with the given
+// ones. The rules are parsed and the engines of findthem are built before the swap,
+// so the data that is being processed finishes with the previous finder and rules.
+// Returns the version of the new set of rules. If any rule is malformed or an
+// engine fails to build, returns an error and the group finder is left unchanged.
+func (rf *GroupFinder) ReplaceRules(
+	findthem *finder.Finder,
+	rulesByName map[string][]string,
+) (version uint64, err error) {
+	newRf := NewFinder(findthem)
+	err = newRf.addRules(rulesByName)
+	if err != nil {
+		return
+	}
+
+	err = findthem.ForceBuild()
+	if err != nil {
+		return
+	}
+
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	rf.version++
+	rf.findthem = newRf.findthem
+	rf.expressionWrapperByExprName = newRf.expressionWrapperByExprName
+	rf.fields = newRf.fields
+	rf.tags = newRf.tags
+	return rf.version, nil
+}
+
+// Version returns the version of the current set of rules.
+// The version is incremented every time the set of rules changes.
+func (rf *GroupFinder) Version() uint64 {
+	rf.mu.RLock()
+	defer rf.mu.RUnlock()
+	return rf.version
+}
+
 // GetFieldNames returns all the unique fields that can be found on all the expressions.
 func (rf *GroupFinder) GetFieldNames() (fields []string) {
+	rf.mu.RLock()
+	defer rf.mu.RUnlock()
 	for field := range rf.fields {
 		fields = append(fields, field)
 	}
@@ -103,9 +164,22 @@ func (rf *GroupFinder) TagObject(
 	data interface{},
 	includePaths []string,
 	excludePaths []string,
+) (matchedExpByFieldByTag map[string]map[string]map[string]struct{}, err error) {
+	rf.mu.RLock()
+	defer rf.mu.RUnlock()
+	return rf.tagObject(context.Background(), data, includePaths, excludePaths)
+}
+
+// tagObject implements TagObject
+func (rf *GroupFinder) tagObject(
+	ctx context.Context,
+	data interface{},
+	includePaths []string,
+	excludePaths []string,
 ) (matchedExpByFieldByTag map[string]map[string]map[string]struct{}, err error) {
 	matchedExpByFieldByTag = make(map[string]map[string]map[string]struct{})
-	err = rf.getRulesInfo(data, "", includePaths, excludePaths, matchedExpByFieldByTag)
+	processedFields := 0
+	err = rf.getRulesInfo(ctx, data, "", includePaths, excludePaths, matchedExpByFieldByTag, &processedFields)
 	return
 }
 
@@ -130,10 +204,36 @@ func (rf *GroupFinder) TagText(
 // EvaluateRules evaluate all rules with the given fields by tag.
 func (rf *GroupFinder) EvaluateRules(
 	matchedExpByFieldByTag map[string]map[string]map[string]struct{},
+) (expressionsByRule map[string][]string, err error) {
+	rf.mu.RLock()
+	defer rf.mu.RUnlock()
+	return rf.evaluateRules(context.Background(), matchedExpByFieldByTag)
+}
+
+// evaluateRules implements EvaluateRules. If the context is done the rules
+// that were already evaluated are returned with a *PartialResultError.
+func (rf *GroupFinder) evaluateRules(
+	ctx context.Context,
+	matchedExpByFieldByTag map[string]map[string]map[string]struct{},
 ) (expressionsByRule map[string][]string, err error) {
 	expressionsByRule = make(map[string][]string)
+	total := 0
+	for _, exprWrappers := range rf.expressionWrapperByExprName {
+		total += len(exprWrappers)
+	}
+
+	processed := 0
 	for name, exprWrappers := range rf.expressionWrapperByExprName {
 		for _, ew := range exprWrappers {
+			if ctx.Err() != nil {
+				return expressionsByRule, &PartialResultError{
+					Stage:     RulesStage,
+					Processed: processed,
+					Total:     total,
+					Err:       ctx.Err(),
+				}
+			}
+			processed++
 			eval, err := ew.Expression.Solve(matchedExpByFieldByTag)
 			if err != nil {
 				return nil, err
@@ -151,18 +251,31 @@ func (rf *GroupFinder) EvaluateRules(
 // if empty array or nil is passed to 'includePaths' it will consider all fields as taggable.
 // excludePaths can be used to specify what fields will be skipped on the tagging, if
 // there is a conflict on a specific field the excludePath has precedence over the include paths.
-// Empty array or nil can be used to not exclude any fields
+// Empty array or nil can be used to not exclude any fields.
 func (rf *GroupFinder) ProcessJson(
 	rawJson string,
 	includePaths []string,
 	excludePaths []string,
 ) (expressionsByRule map[string][]string, err error) {
-	matchedExpByFieldByTag, err := rf.TagJson(rawJson, includePaths, excludePaths)
+	expressionsByRule, _, err = rf.ProcessJsonContext(context.Background(), rawJson, includePaths, excludePaths)
+	return
+}
+
+// ProcessJsonContext works as ProcessJson but stops the processing when the context is done.
+// See ProcessObjectContext.
+func (rf *GroupFinder) ProcessJsonContext(
+	ctx context.Context,
+	rawJson string,
+	includePaths []string,
+	excludePaths []string,
+) (expressionsByRule map[string][]string, version uint64, err error) {
+	var genericObj interface{}
+	err = json.Unmarshal([]byte(rawJson), &genericObj)
 	if err != nil {
-		return nil, err
+		return
 	}
 
-	return rf.EvaluateRules(matchedExpByFieldByTag)
+	return rf.ProcessObjectContext(ctx, genericObj, includePaths, excludePaths)
 }
 
 // ProcessObject extract all tags and evaluate all rules for the given data of type interface.
@@ -170,27 +283,53 @@ func (rf *GroupFinder) ProcessJson(
 // if empty array or nil is passed to 'includePaths' it will consider all fields as taggable.
 // excludePaths can be used to specify what fields will be skipped on the tagging, if
 // there is a conflict on a specific field the excludePath has precedence over the include paths.
-// Empty array or nil can be used to not exclude any fields
+// Empty array or nil can be used to not exclude any fields.
 func (rf *GroupFinder) ProcessObject(
 	obj interface{},
 	includePaths []string,
 	excludePaths []string,
 ) (expressionsByRule map[string][]string, err error) {
-	matchedExpByFieldByTag, err := rf.TagObject(obj, includePaths, excludePaths)
+	expressionsByRule, _, err = rf.ProcessObjectContext(context.Background(), obj, includePaths, excludePaths)
+	return
+}
+
+// ProcessObjectContext works as ProcessObject but stops the processing when the context is done.
+// The context is checked between the fields that are tagged, inside the processing of each field
+// and between the evaluation of the rules expressions. If the processing is stopped a
+// *PartialResultError is returned. When the tagging is stopped no rule is evaluated, since the
+// missing tags could change the result of the rules, and when the evaluation of the rules is
+// stopped the rules that were already evaluated as true are returned.
+// The version of the set of rules that was used to process the data is returned with the rules.
+func (rf *GroupFinder) ProcessObjectContext(
+	ctx context.Context,
+	obj interface{},
+	includePaths []string,
+	excludePaths []string,
+) (expressionsByRule map[string][]string, version uint64, err error) {
+	rf.mu.RLock()
+	defer rf.mu.RUnlock()
+	version = rf.version
+	matchedExpByFieldByTag, err := rf.tagObject(ctx, obj, includePaths, excludePaths)
 	if err != nil {
-		return nil, err
+		return nil, version, err
 	}
 
-	return rf.EvaluateRules(matchedExpByFieldByTag)
+	expressionsByRule, err = rf.evaluateRules(ctx, matchedExpByFieldByTag)
+	return
 }
 
 // ProcessText extract all tags and evaluate all rules for the given string.
 func (rf *GroupFinder) ProcessText(
 	data string,
 ) (expressionsByRule map[string][]string, err error) {
-	matchedExpByFieldByTag, err := rf.TagObject(data, nil, nil)
-	if err != nil {
-		return nil, err
-	}
-	return rf.EvaluateRules(matchedExpByFieldByTag)
+	return rf.ProcessObject(data, nil, nil)
+}
+
+// ProcessTextContext works as ProcessText but stops the processing when the context is done.
+// See ProcessObjectContext.
+func (rf *GroupFinder) ProcessTextContext(
+	ctx context.Context,
+	data string,
+) (expressionsByRule map[string][]string, version uint64, err error) {
+	return rf.ProcessObjectContext(ctx, data, nil, nil)
 }
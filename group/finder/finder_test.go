@@ -1,7 +1,10 @@
 package finder
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 
 	gofindthem "github.com/pedroegsilva/gofindthem/finder"
@@ -64,6 +67,7 @@ func TestNewFinderWithRules(t *testing.T) {
 			},
 			groupFinder: &GroupFinder{
 				findthem: gft,
+				version:  1,
 				expressionWrapperByExprName: map[string][]ExpressionWrapper{
 					"rule1": {
 						{
@@ -118,6 +122,7 @@ func TestNewFinderWithRules(t *testing.T) {
 			},
 			groupFinder: &GroupFinder{
 				findthem:                    gft,
+				version:                     1,
 				expressionWrapperByExprName: map[string][]ExpressionWrapper{},
 				fields:                      map[string]struct{}{},
 				tags:                        map[string]struct{}{},
@@ -152,6 +157,7 @@ func TestAddRule(t *testing.T) {
 			},
 			groupFinder: &GroupFinder{
 				findthem: gft,
+				version:  1,
 				expressionWrapperByExprName: map[string][]ExpressionWrapper{
 					"rule1": {
 						{
@@ -188,6 +194,7 @@ func TestAddRule(t *testing.T) {
 			},
 			groupFinder: &GroupFinder{
 				findthem:                    gft,
+				version:                     1,
 				expressionWrapperByExprName: map[string][]ExpressionWrapper{},
 				fields:                      map[string]struct{}{},
 				tags:                        map[string]struct{}{},
@@ -227,6 +234,7 @@ func TestAddRules(t *testing.T) {
 			},
 			groupFinder: &GroupFinder{
 				findthem: gft,
+				version:  1,
 				expressionWrapperByExprName: map[string][]ExpressionWrapper{
 					"rule1": {
 						{
@@ -280,6 +288,7 @@ func TestAddRules(t *testing.T) {
 			},
 			groupFinder: &GroupFinder{
 				findthem:                    gft,
+				version:                     1,
 				expressionWrapperByExprName: map[string][]ExpressionWrapper{},
 				fields:                      map[string]struct{}{},
 				tags:                        map[string]struct{}{},
@@ -500,3 +509,116 @@ func TestEvaluateRules(t *testing.T) {
 		assert.Equal(tc.expectedExpressionsByRule, extractorInfoByTaggerName, tc.message+" result")
 	}
 }
+
+func TestReplaceRules(t *testing.T) {
+	assert := assert.New(t)
+	oldGft, err := gofindthem.NewFinderWithExpressions(
+		&gofindthem.CloudflareForkEngine{},
+		&gofindthem.EmptyRgxEngine{},
+		false,
+		map[string][]string{"oldTag": {`"old"`}},
+	)
+	assert.Nil(err)
+	gftg, err := NewFinderWithRules(oldGft, map[string][]string{"oldRule": {`"oldTag"`}})
+	assert.Nil(err)
+	assert.Equal(uint64(1), gftg.Version())
+
+	newGft, err := gofindthem.NewFinderWithExpressions(
+		&gofindthem.CloudflareForkEngine{},
+		&gofindthem.EmptyRgxEngine{},
+		false,
+		map[string][]string{"newTag": {`"new"`}},
+	)
+	assert.Nil(err)
+
+	_, err = gftg.ReplaceRules(newGft, map[string][]string{"newRule": {`"newTag`}})
+	assert.NotNil(err, "malformed rule")
+	assert.Equal(uint64(1), gftg.Version(), "group finder unchanged after error")
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				res, version, err := gftg.ProcessJsonContext(context.Background(), `{"field": "old new"}`, nil, nil)
+				assert.Nil(err)
+				assert.Len(res, 1)
+				if _, ok := res["oldRule"]; ok {
+					assert.Equal(uint64(1), version)
+				} else {
+					assert.Equal(map[string][]string{"newRule": {`"newTag"`}}, res)
+					assert.Equal(uint64(2), version)
+				}
+			}
+		}()
+	}
+
+	version, err := gftg.ReplaceRules(newGft, map[string][]string{"newRule": {`"newTag"`}})
+	close(stop)
+	wg.Wait()
+	assert.Nil(err)
+	assert.Equal(uint64(2), version)
+
+	res, version, err := gftg.ProcessTextContext(context.Background(), "old new")
+	assert.Nil(err)
+	assert.Equal(map[string][]string{"newRule": {`"newTag"`}}, res)
+	assert.Equal(uint64(2), version)
+
+	noRgxGft, err := gofindthem.NewFinderWithExpressions(
+		&gofindthem.CloudflareForkEngine{},
+		nil,
+		false,
+		map[string][]string{"newTag": {`"new"`}},
+	)
+	assert.Nil(err)
+	version, err = gftg.ReplaceRules(noRgxGft, map[string][]string{"newRule": {`"newTag"`}})
+	assert.Nil(err, "a finder without regexes needs no regex engine")
+	assert.Equal(uint64(3), version)
+}
+
+func TestProcessObjectContext(t *testing.T) {
+	assert := assert.New(t)
+	gft, err := gofindthem.NewFinderWithExpressions(
+		&gofindthem.CloudflareForkEngine{},
+		&gofindthem.EmptyRgxEngine{},
+		false,
+		map[string][]string{"tag1": {`"a"`}, "tag2": {`"b"`}},
+	)
+	assert.Nil(err)
+	gftg, err := NewFinderWithRules(gft, map[string][]string{"rule1": {`"tag1"`, `"tag2"`}})
+	assert.Nil(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, version, err := gftg.ProcessJsonContext(ctx, `{"field": "a b"}`, nil, nil)
+	assert.Nil(res)
+	assert.Equal(uint64(1), version)
+	var partialErr *PartialResultError
+	if assert.True(errors.As(err, &partialErr)) {
+		assert.Equal(TaggingStage, partialErr.Stage)
+		assert.Equal("field", partialErr.Field)
+		assert.Equal(0, partialErr.Processed)
+		assert.True(errors.Is(err, context.Canceled))
+	}
+
+	res, err = gftg.evaluateRules(ctx, map[string]map[string]map[string]struct{}{
+		"tag1": {"field": {`"a"`: {}}},
+	})
+	assert.Equal(map[string][]string{}, res)
+	if assert.True(errors.As(err, &partialErr)) {
+		assert.Equal(RulesStage, partialErr.Stage)
+		assert.Equal(0, partialErr.Processed)
+		assert.Equal(2, partialErr.Total)
+	}
+
+	res, _, err = gftg.ProcessObjectContext(context.Background(), map[string]string{"field": "a b"}, nil, nil)
+	assert.Nil(err)
+	assert.Equal(map[string][]string{"rule1": {`"tag1"`, `"tag2"`}}, res)
+}
@@ -1,17 +1,23 @@
 package finder
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+
+	"github.com/pedroegsilva/gofindthem/finder"
 )
 
 func (rf *GroupFinder) getRulesInfo(
+	ctx context.Context,
 	data interface{},
 	fieldName string,
 	includePaths []string,
 	excludePaths []string,
 	matchedExpByFieldByByTag map[string]map[string]map[string]struct{},
+	processedFields *int,
 ) (err error) {
 	t := reflect.TypeOf(data)
 
@@ -22,10 +28,18 @@ func (rf *GroupFinder) getRulesInfo(
 		if !isValidateFieldPath(fieldName, includePaths, excludePaths) {
 			return
 		}
-		expRes, err := rf.findthem.ProcessText(val.String())
+		if ctx.Err() != nil {
+			return &PartialResultError{Stage: TaggingStage, Field: fieldName, Processed: *processedFields, Err: ctx.Err()}
+		}
+		expRes, err := rf.findthem.ProcessTextContext(ctx, val.String())
 		if err != nil {
+			var partialErr *finder.PartialResultError
+			if errors.As(err, &partialErr) {
+				return &PartialResultError{Stage: TaggingStage, Field: fieldName, Processed: *processedFields, Err: err}
+			}
 			return err
 		}
+		*processedFields++
 
 		for _, er := range expRes {
 			if _, ok := matchedExpByFieldByByTag[er.Tag]; !ok {
@@ -49,7 +63,7 @@ func (rf *GroupFinder) getRulesInfo(
 			if !val.Field(i).CanInterface() {
 				continue
 			}
-			err := rf.getRulesInfo(val.Field(i).Interface(), fn, includePaths, excludePaths, matchedExpByFieldByByTag)
+			err := rf.getRulesInfo(ctx, val.Field(i).Interface(), fn, includePaths, excludePaths, matchedExpByFieldByByTag, processedFields)
 			if err != nil {
 				return err
 			}
@@ -71,7 +85,7 @@ func (rf *GroupFinder) getRulesInfo(
 			if !v.CanInterface() {
 				continue
 			}
-			err := rf.getRulesInfo(v.Interface(), fn, includePaths, excludePaths, matchedExpByFieldByByTag)
+			err := rf.getRulesInfo(ctx, v.Interface(), fn, includePaths, excludePaths, matchedExpByFieldByByTag, processedFields)
 			if err != nil {
 				return err
 			}
@@ -86,7 +100,7 @@ func (rf *GroupFinder) getRulesInfo(
 			if !val.Index(i).CanInterface() {
 				continue
 			}
-			err := rf.getRulesInfo(val.Index(i).Interface(), fn, includePaths, excludePaths, matchedExpByFieldByByTag)
+			err := rf.getRulesInfo(ctx, val.Index(i).Interface(), fn, includePaths, excludePaths, matchedExpByFieldByByTag, processedFields)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,123 @@
+// Package batch holds the pool of workers shared by the batch
+// processing of the finder and of the group finder.
+package batch
+
+import (
+	"context"
+	"sync"
+)
+
+// pendingPerWorker is the number of items per worker that may be waiting to be sent,
+// so the results that wait for the previous ones on the ordered mode are bounded.
+const pendingPerWorker = 2
+
+// Result holds the value returned by the process function for an item and
+// the index of the item, that is the order in which it was received.
+type Result struct {
+	Index int
+	Value interface{}
+}
+
+// Process processes the items returned by next using the given number of workers, that call
+// process for each item, and sends their results on the returned channel. If preserveOrder is
+// set the results are sent in the same order as the items were received, otherwise they are
+// sent as soon as they are ready. next must return false when there are no more items or when
+// the context is done. The returned channel is closed after all the items are processed, or
+// when the context is done, stopping the workers and discarding the results not sent yet.
+// If workers is lower than 1, a single worker is used.
+func Process(
+	ctx context.Context,
+	workers int,
+	preserveOrder bool,
+	next func(ctx context.Context) (item interface{}, ok bool),
+	process func(item interface{}) interface{},
+) <-chan Result {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// a slot is taken for each item and released when its result is sent
+	slots := make(chan struct{}, pendingPerWorker*workers)
+	jobs := make(chan Result)
+	go func() {
+		defer close(jobs)
+		for index := 0; ; index++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			item, ok := next(ctx)
+			if !ok {
+				return
+			}
+			select {
+			case jobs <- Result{Index: index, Value: item}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan Result, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				select {
+				case results <- Result{Index: job.Index, Value: process(job.Value)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	sent := make(chan Result)
+	go func() {
+		defer close(sent)
+		send := func(res Result) bool {
+			select {
+			case sent <- res:
+				<-slots
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		pending := make(map[int]Result)
+		nextIndex := 0
+		for res := range results {
+			if !preserveOrder {
+				if !send(res) {
+					return
+				}
+				continue
+			}
+			pending[res.Index] = res
+			for {
+				nextRes, ok := pending[nextIndex]
+				if !ok {
+					break
+				}
+				delete(pending, nextIndex)
+				if !send(nextRes) {
+					return
+				}
+				nextIndex++
+			}
+		}
+	}()
+	return sent
+}
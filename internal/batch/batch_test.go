@@ -0,0 +1,85 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// counter returns a next function that returns the numbers from 0 to n-1
+// and the number of items it returned.
+func counter(n int) (func(ctx context.Context) (interface{}, bool), func() int) {
+	received := make(chan int, n+1)
+	i := 0
+	next := func(ctx context.Context) (interface{}, bool) {
+		if i == n {
+			return nil, false
+		}
+		i++
+		received <- i
+		return i - 1, true
+	}
+	return next, func() int { return len(received) }
+}
+
+func TestProcess(t *testing.T) {
+	assert := assert.New(t)
+	double := func(item interface{}) interface{} {
+		if item.(int)%3 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		return item.(int) * 2
+	}
+
+	for _, workers := range []int{-1, 0, 1, 4} {
+		for _, preserveOrder := range []bool{true, false} {
+			message := fmt.Sprintf("%d workers, preserve order %v", workers, preserveOrder)
+			next, _ := counter(50)
+			seen := make(map[int]struct{})
+			count := 0
+			for res := range Process(context.Background(), workers, preserveOrder, next, double) {
+				if preserveOrder {
+					assert.Equal(count, res.Index, message)
+				}
+				assert.Equal(res.Index*2, res.Value, message)
+				seen[res.Index] = struct{}{}
+				count++
+			}
+			assert.Len(seen, 50, message)
+			assert.Equal(50, count, message)
+		}
+	}
+}
+
+func TestProcessCancel(t *testing.T) {
+	assert := assert.New(t)
+	identity := func(item interface{}) interface{} { return item }
+
+	for _, preserveOrder := range []bool{true, false} {
+		message := fmt.Sprintf("preserve order %v", preserveOrder)
+		ctx, cancel := context.WithCancel(context.Background())
+		next, received := counter(1000)
+		results := Process(ctx, 2, preserveOrder, next, identity)
+		<-results
+
+		// the items are not received while the results are not read
+		time.Sleep(10 * time.Millisecond)
+		assert.LessOrEqual(received(), 1+pendingPerWorker*2, message)
+
+		cancel()
+		for range results {
+		}
+		assert.Less(received(), 1000, message)
+	}
+
+	// next is not called after the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	next, received := counter(10)
+	for range Process(ctx, 2, true, next, identity) {
+	}
+	assert.Equal(0, received())
+}